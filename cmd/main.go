@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,15 +25,80 @@ func detectFormat(filepath string) string {
 	return ""
 }
 
+// stringSliceFlag 可重复指定的字符串参数
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, " ")
+}
+
+func (s *stringSliceFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
 func main() {
+	var chromeFlags, headerFlags stringSliceFlag
+	flag.Var(&headerFlags, "H", "自定义请求头，格式 \"Name: value\"，可重复指定（HTTP 抓取和 Chrome 渲染都生效）")
+	flag.Var(&chromeFlags, "chrome-flag", "额外的 Chrome 启动参数，格式 name 或 name=value，可重复指定（仅本地浏览器）")
+
 	var (
-		urlList      = flag.String("l", "", "URL 列表：文件路径（.txt）或逗号分隔的 URL 字符串（必选）")
+		urlList      = flag.String("l", "", "URL 列表：文件路径、- 表示标准输入，或逗号分隔的 URL 字符串（必选）")
+		inputFormat  = flag.String("input-format", "", "输入格式：txt、jsonl、csv、nmap、httpx，为空时按扩展名或内容自动识别")
+		urlField     = flag.String("url-field", "", "JSON Lines 中 URL 所在的字段，默认依次尝试 url、host、input")
+		csvColumn    = flag.String("csv-column", "", "CSV 中 URL 所在的列（列名或从 1 开始的序号），默认 url 或 host 列，都没有时用第一列")
+		ports        = flag.String("ports", "", "没有写端口的主机、IP 段和 CIDR 按这些端口展开，例如 80,443,8000-8100，为空时使用协议默认端口")
+		schemeMode   = flag.String("scheme", internal.SchemeModeBoth, "没有写 scheme 的输入如何探测协议：both（http 和 https 都探测）、https-first、http-first")
+		dropParams   = flag.String("drop-params", "", "规范化 URL 时额外删除的 query 参数，逗号分隔，以 * 结尾表示前缀匹配，例如 sessionid,ref_*")
+		keepFragment = flag.Bool("keep-fragment", false, "规范化 URL 时保留 #fragment（hash 路由的单页应用需要）")
 		output       = flag.String("o", "", "输出文件路径（必选，支持 .json 或 .csv 扩展名）")
 		threads      = flag.Int("t", 20, "并发数：同时处理的 URL 数（包含抓取和渲染）")
 		httpTimeout  = flag.Duration("http-timeout", 10*time.Second, "HTTP 请求超时")
 		pageTimeout  = flag.Duration("page-timeout", 20*time.Second, "单个页面 headless 渲染超时")
 		batchSize    = flag.Int("batch-size", 1000, "批处理大小")
-		simThreshold = flag.Float64("sim-threshold", 0.85, "相似度阈值（仅用于 meta，实际判定使用严格规则）")
+		simThreshold = flag.Float64("sim-threshold", 0.85, "已废弃：不参与重复判定，只原样记录在报告 meta 的 sim_threshold；判定阈值请用 -content-sim 等参数或配置文件")
+		crossHost    = flag.Bool("cross-host", false, "跨 host 聚类：内容相同的镜像站、多 IP 部署、测试环境副本等归为一类")
+		lshBands     = flag.Int("lsh-bands", 0, "LSH 分段数：汉明距离小于该值的页面对保证被比较，0 表示按预筛选阈值自动选择")
+		rulesPath    = flag.String("rules", "", "规则文件路径（YAML），可增加规则、替换或禁用同 id 的内置规则")
+		disableRules = flag.String("disable-rules", "", "禁用的规则 ID，逗号分隔，例如 E3,L1")
+		noRender     = flag.Bool("no-render", false, "HTTP-only 模式：不启动 headless Chrome，只用文本和 DOM 结构判定（视觉、行为维度不可用）")
+		chromeURL    = flag.String("chrome-url", "", "远程 Chrome 的 DevTools 地址（ws://host:port/devtools/browser/... 或 http://host:port），为空时启动本地浏览器")
+		chromeWS     = flag.String("chrome-ws", "", "同 -chrome-url")
+		chromePath   = flag.String("chrome-path", "", "本地 Chrome 可执行文件路径，为空时自动查找")
+		proxy        = flag.String("proxy", "", "上游代理，支持 http://、https://、socks5://，可带 user:pass@，多个用逗号分隔并按请求轮询")
+		proxyList    = flag.String("proxy-list", "", "代理列表文件，每行一个，与 -proxy 合并后轮询")
+		vhost        = flag.String("vhost", "", "虚拟主机扫描：候选主机名，逗号分隔；对每个 IP 输入分别以这些主机名作为 Host 头和 TLS SNI 请求，不能与 -proxy 同时使用")
+		vhostList    = flag.String("vhost-list", "", "候选主机名列表文件，每行一个，与 -vhost 合并")
+		cookieFile   = flag.String("cookies", "", "cookie 文件（Netscape cookies.txt 或 JSON），HTTP 抓取和 Chrome 渲染共用")
+		userAgent    = flag.String("ua", "", "User-Agent，优先于 -ua-profile")
+		uaProfile    = flag.String("ua-profile", "", "内置 UA 配置："+strings.Join(internal.UAProfileNames(), ", ")+"（默认 "+internal.DefaultUAProfile+"）")
+		hostConc     = flag.Int("host-concurrency", internal.DefaultHostConcurrency, "每个 host 的最大并发（抓取和渲染合计），0 表示不限制")
+		hostRPS      = flag.Float64("host-rps", 0, "每个 host 每秒最多发起的请求数，0 表示不限制")
+		hostJitter   = flag.Float64("host-jitter", internal.DefaultHostJitter, "请求间隔的随机抖动比例（0-1），配合 -host-rps 使用")
+		maxBackoff   = flag.Duration("max-backoff", internal.DefaultMaxBackoff, "遇到 429/503/WAF 拦截时按 host 指数退避的最大时间，0 表示不退避")
+		retryTries   = flag.Int("retry-attempts", internal.DefaultRetryAttempts, "抓取和渲染最多尝试次数（含第一次），1 表示不重试")
+		retryDelay   = flag.String("retry-delay", internal.DefaultRetryDelay, "第一次重试前的等待时间，之后每次翻倍")
+		retryMaxWait = flag.String("retry-max-delay", internal.DefaultRetryMaxDelay, "重试最大等待时间")
+		retryStatus  = flag.String("retry-status", formatStatusList(internal.DefaultRetryConfig().RetryStatus), "可重试的状态码，逗号分隔")
+		retryErrors  = flag.String("retry-errors", strings.Join(internal.DefaultRetryConfig().RetryErrors, ","), "可重试的错误分类，逗号分隔（"+strings.Join(internal.ErrorClassNames(), ", ")+"）")
+		recyclePages = flag.Int("recycle-pages", 1000, "每个浏览器实例渲染多少页后重启，0 表示不按页数回收")
+		maxChromeRSS = flag.Int("max-chrome-rss", 2048, "本地 Chrome 进程树 RSS 上限（MB），超过后重启浏览器，0 表示不检查")
+		noFavicon    = flag.Bool("no-favicon", false, "不下载 favicon（默认每个站点下载一次，计算 mmh3/MD5/pHash）")
+		soft404      = flag.Int("soft404-probes", 0, "软 404 检测：每个 origin 请求的随机不存在路径数（1-3），0 表示关闭")
+		seedSitemap  = flag.Int("sitemap", 0, "robots.txt/sitemap 发现：请求每个 origin 的 robots.txt 和 sitemap，每个 origin 最多加入的 URL 数，0 表示关闭")
+		configPath   = flag.String("config", "", "配置文件路径（.yaml/.yml/.json），用于设置相似度阈值和权重")
+
+		// 相似度阈值（优先级：命令行 > 配置文件 > 默认值）
+		contentSim       = flag.Float64("content-sim", internal.ContentSimThreshold, "文本相似度阈值（规则1）")
+		structureSim     = flag.Float64("structure-sim", internal.StructureSimThreshold, "结构相似度阈值（规则1）")
+		visualSim        = flag.Float64("visual-sim", internal.VisualSimThreshold, "视觉相似度阈值（规则1）")
+		visualHighSim    = flag.Float64("visual-high-sim", internal.VisualHighSimThreshold, "视觉极高相似度阈值（规则2兜底）")
+		quickSimHashDist = flag.Int("quick-simhash-dist", internal.QuickSimHashMaxDist, "SimHash 预筛选最大汉明距离")
+		textSimHashDist  = flag.Int("text-simhash-dist", internal.TextSimHashMaxDist, "文本类（JSON/XML/纯文本）SimHash 最大汉明距离")
+		imagePHashDist   = flag.Int("image-phash-dist", internal.ImagePHashMaxDist, "图片 pHash 最大汉明距离")
+		headerSim        = flag.Float64("header-sim", internal.HeaderSimThreshold, "响应头相似度下限，低于该值不判定为重复，0 表示不检查")
+		weights          = flag.String("weights", "", "总相似度权重，格式 content,structure,visual,behavior[,header]（默认 "+formatWeights(internal.DefaultThresholds().Weights)+"，省略 header 时为 0）")
+		outputHeaders    = flag.Bool("output-headers", false, "JSON 报告中输出每个 URL 规范化后的响应头")
 	)
 
 	flag.Parse()
@@ -57,6 +123,174 @@ func main() {
 		os.Exit(1)
 	}
 
+	// 加载配置文件
+	cfg := internal.DefaultConfig()
+	if *configPath != "" {
+		loaded, err := internal.LoadConfig(*configPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(1)
+		}
+		cfg = loaded
+	}
+
+	// 命令行显式指定的阈值覆盖配置文件
+	thresholds := cfg.Thresholds
+	var flagErr error
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "content-sim":
+			thresholds.ContentSim = *contentSim
+		case "structure-sim":
+			thresholds.StructureSim = *structureSim
+		case "visual-sim":
+			thresholds.VisualSim = *visualSim
+		case "visual-high-sim":
+			thresholds.VisualHighSim = *visualHighSim
+		case "quick-simhash-dist":
+			thresholds.QuickSimHashMaxDist = *quickSimHashDist
+		case "text-simhash-dist":
+			thresholds.TextSimHashMaxDist = *textSimHashDist
+		case "image-phash-dist":
+			thresholds.ImagePHashMaxDist = *imagePHashDist
+		case "header-sim":
+			thresholds.HeaderSim = *headerSim
+		case "sim-threshold":
+			fmt.Fprintf(os.Stderr, "警告: -sim-threshold 已废弃，不参与重复判定，请改用 -content-sim 等阈值参数或配置文件\n")
+		case "weights":
+			w, err := parseWeights(*weights)
+			if err != nil {
+				flagErr = err
+				return
+			}
+			thresholds.Weights = w
+		}
+	})
+	if flagErr == nil {
+		flagErr = thresholds.Validate()
+	}
+	if flagErr != nil {
+		fmt.Fprintf(os.Stderr, "错误: 阈值参数不合法: %v\n", flagErr)
+		os.Exit(1)
+	}
+
+	// 命令行显式指定的重试参数覆盖配置文件
+	retry := cfg.Retry
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "retry-attempts":
+			retry.MaxAttempts = *retryTries
+		case "retry-delay":
+			retry.BaseDelay = *retryDelay
+		case "retry-max-delay":
+			retry.MaxDelay = *retryMaxWait
+		case "retry-status":
+			codes, err := parseStatusList(*retryStatus)
+			if err != nil {
+				flagErr = err
+				return
+			}
+			retry.RetryStatus = codes
+		case "retry-errors":
+			retry.RetryErrors = splitList(*retryErrors)
+		}
+	})
+	if flagErr == nil {
+		_, flagErr = internal.NewRetryPolicy(retry)
+	}
+	if flagErr != nil {
+		fmt.Fprintf(os.Stderr, "错误: 重试参数不合法: %v\n", flagErr)
+		os.Exit(1)
+	}
+
+	// 加载规则：内置规则 + 用户规则文件
+	var disabledRuleIDs []string
+	if *disableRules != "" {
+		disabledRuleIDs = strings.Split(*disableRules, ",")
+	}
+	rules, err := internal.LoadRules(*rulesPath, disabledRuleIDs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+
+	// 请求配置：命令行覆盖配置文件，-H 与配置文件中的 headers 合并
+	request := cfg.Request
+	headers := make(map[string]string, len(request.Headers)+len(headerFlags))
+	for name, value := range request.Headers {
+		headers[name] = value
+	}
+	for _, h := range headerFlags {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			fmt.Fprintf(os.Stderr, "错误: 请求头格式应为 \"Name: value\": %s\n", h)
+			os.Exit(1)
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	request.Headers = headers
+	if *cookieFile != "" {
+		request.CookieFile = *cookieFile
+	}
+	if *userAgent != "" {
+		request.UserAgent = *userAgent
+	}
+	if *uaProfile != "" {
+		request.UAProfile = *uaProfile
+	}
+
+	// 代理：-proxy 和 -proxy-list 合并
+	var proxies []string
+	if *proxy != "" {
+		proxies = append(proxies, strings.Split(*proxy, ",")...)
+	}
+	if *proxyList != "" {
+		list, err := internal.LoadProxyList(*proxyList)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(1)
+		}
+		proxies = append(proxies, list...)
+	}
+
+	// 虚拟主机：-vhost 和 -vhost-list 合并
+	vhosts := splitList(*vhost)
+	if *vhostList != "" {
+		list, err := internal.LoadVHostList(*vhostList)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(1)
+		}
+		vhosts = append(vhosts, list...)
+	}
+
+	// 展开端口和协议探测方式
+	var portList []int
+	if *ports != "" {
+		portList, err = internal.ParsePorts(*ports)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if !internal.ValidSchemeMode(*schemeMode) {
+		fmt.Fprintf(os.Stderr, "错误: -scheme 只能是 both、https-first 或 http-first: %s\n", *schemeMode)
+		os.Exit(1)
+	}
+
+	// URL 规范化：配置文件打底，命令行参数追加
+	canonical := cfg.Canonicalize
+	canonical.DropParams = append(canonical.DropParams, splitList(*dropParams)...)
+	if *keepFragment {
+		canonical.KeepFragment = true
+	}
+
+	// -chrome-ws 是 -chrome-url 的别名
+	remoteChrome := *chromeURL
+	if remoteChrome == "" {
+		remoteChrome = *chromeWS
+	}
+
 	// 避免用户传 0 或负数
 	concurrency := *threads
 	if concurrency <= 0 {
@@ -65,14 +299,51 @@ func main() {
 
 	// 构建选项
 	opts := internal.Options{
-		URLs:           []string{*urlList},
-		Parallel:       concurrency,    // HTTP 抓取并发
-		RenderParallel: concurrency,    // 渲染并发
+		URLs: []string{*urlList},
+		Input: internal.InputConfig{
+			Format:    *inputFormat,
+			URLField:  *urlField,
+			CSVColumn: *csvColumn,
+
+			Ports:      portList,
+			SchemeMode: *schemeMode,
+
+			Canonical: canonical,
+		},
+		Parallel:       concurrency, // HTTP 抓取并发
+		RenderParallel: concurrency, // 渲染并发
 		HTTPTimeout:    *httpTimeout,
 		PerPageTimeout: *pageTimeout,
 		BatchSize:      *batchSize,
 		SimThreshold:   *simThreshold,
 		OutputFormat:   format,
+		LSHBands:       *lshBands,
+		CrossHost:      *crossHost,
+		Thresholds:     thresholds,
+		Rules:          rules,
+
+		SoftNotFoundProbes: *soft404,
+		SeedPerOrigin:      *seedSitemap,
+		NoRender:           *noRender,
+
+		ChromeURL:   remoteChrome,
+		ChromePath:  *chromePath,
+		ChromeFlags: chromeFlags,
+
+		RecyclePages: *recyclePages,
+		MaxChromeRSS: *maxChromeRSS,
+		Proxies:      proxies,
+		VHosts:       vhosts,
+		Request:      request,
+		Retry:        retry,
+
+		HostConcurrency: *hostConc,
+		HostRPS:         *hostRPS,
+		HostJitter:      *hostJitter,
+		MaxBackoff:      *maxBackoff,
+
+		OutputHeaders: *outputHeaders,
+		NoFavicon:     *noFavicon,
 	}
 
 	// 运行
@@ -107,3 +378,71 @@ func writeReport(report *internal.FullReport, filepath, format string) error {
 	return fmt.Errorf("不支持的格式: %s", format)
 }
 
+// formatWeights 按 -weights 的格式输出权重
+func formatWeights(w internal.SimWeights) string {
+	values := []float64{w.Content, w.Structure, w.Visual, w.Behavior, w.Header}
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseWeights 解析权重参数
+// 格式：content,structure,visual,behavior[,header]，省略 header 时为 0
+func parseWeights(s string) (internal.SimWeights, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 && len(parts) != 5 {
+		return internal.SimWeights{}, fmt.Errorf("权重必须是 4 或 5 个逗号分隔的数字: %s", s)
+	}
+
+	values := make([]float64, 5)
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return internal.SimWeights{}, fmt.Errorf("无效的权重 %q: %w", part, err)
+		}
+		values[i] = v
+	}
+
+	return internal.SimWeights{
+		Content:   values[0],
+		Structure: values[1],
+		Visual:    values[2],
+		Behavior:  values[3],
+		Header:    values[4],
+	}, nil
+}
+
+// formatStatusList 按 -retry-status 的格式输出状态码
+func formatStatusList(codes []int) string {
+	parts := make([]string, len(codes))
+	for i, code := range codes {
+		parts[i] = strconv.Itoa(code)
+	}
+	return strings.Join(parts, ",")
+}
+
+// parseStatusList 解析逗号分隔的状态码列表
+func parseStatusList(s string) ([]int, error) {
+	var codes []int
+	for _, part := range splitList(s) {
+		code, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("状态码格式错误: %s", part)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// splitList 按逗号分隔并去掉空白和空项
+func splitList(s string) []string {
+	var items []string
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			items = append(items, part)
+		}
+	}
+	return items
+}
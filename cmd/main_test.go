@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/0cat/websiteSimilar/internal"
+)
+
+func TestParseWeights(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    internal.SimWeights
+		wantErr bool
+	}{
+		{"0.4,0.25,0.25,0.1", internal.SimWeights{Content: 0.4, Structure: 0.25, Visual: 0.25, Behavior: 0.1}, false},
+		{"0.4, 0.2, 0.2, 0.1, 0.1", internal.SimWeights{Content: 0.4, Structure: 0.2, Visual: 0.2, Behavior: 0.1, Header: 0.1}, false},
+		{"1,2,3", internal.SimWeights{}, true},
+		{"1,2,x,4", internal.SimWeights{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseWeights(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseWeights(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseWeights(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFormatWeightsRoundTrip(t *testing.T) {
+	defaults := internal.DefaultThresholds().Weights
+	got, err := parseWeights(formatWeights(defaults))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != defaults {
+		t.Errorf("formatWeights 与 parseWeights 不一致: %+v != %+v", got, defaults)
+	}
+}
@@ -0,0 +1,191 @@
+package internal
+
+import (
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// CanonicalConfig URL 规范化配置
+// 零值表示开启全部规范化步骤，各字段用于关闭某一步或增加要删除的参数
+type CanonicalConfig struct {
+	KeepDefaultPort    bool     `yaml:"keep_default_port" json:"keep_default_port"`       // 保留 http 的 :80 和 https 的 :443
+	KeepFragment       bool     `yaml:"keep_fragment" json:"keep_fragment"`               // 保留 #fragment（hash 路由的单页应用需要）
+	KeepQueryOrder     bool     `yaml:"keep_query_order" json:"keep_query_order"`         // 不按参数名排序 query
+	KeepUnicodeHost    bool     `yaml:"keep_unicode_host" json:"keep_unicode_host"`       // 国际化域名不转换为 punycode
+	KeepTrackingParams bool     `yaml:"keep_tracking_params" json:"keep_tracking_params"` // 不删除内置的跟踪参数（utm_*、spm 等）
+	DropParams         []string `yaml:"drop_params" json:"drop_params"`                   // 额外删除的 query 参数，不区分大小写，以 * 结尾表示前缀匹配
+}
+
+// trackingParams 内置的跟踪参数黑名单，以 * 结尾表示前缀匹配
+var trackingParams = []string{
+	"utm_*",
+	"spm", "spm_id_from", "scm",
+	"fbclid", "gclid", "dclid", "gclsrc", "msclkid", "yclid", "igshid", "twclid", "ttclid",
+	"mc_cid", "mc_eid", "_hsenc", "_hsmi", "mkt_tok",
+	"vd_source", "share_source", "share_medium", "share_plat",
+}
+
+// defaultPorts 各协议的默认端口
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// canonicalizeURL 按配置规范化解析后的 URL（原地修改）
+// host 转小写、去掉末尾的点并转换为 punycode，去掉默认端口和 fragment，统一百分号编码并清理 path，
+// 删除黑名单参数并按参数名排序，空 path 统一为 "/"
+func canonicalizeURL(u *url.URL, cfg CanonicalConfig) {
+	u.Scheme = strings.ToLower(u.Scheme)
+
+	host, port := u.Hostname(), u.Port()
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if !cfg.KeepUnicodeHost {
+		if ascii, err := idna.Lookup.ToASCII(host); err == nil {
+			host = ascii
+		}
+	}
+	if !cfg.KeepDefaultPort && port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	u.Host = host
+
+	if !cfg.KeepFragment {
+		u.Fragment = ""
+		u.RawFragment = ""
+	}
+
+	// 统一百分号编码（非保留字符解码，其余转义用大写十六进制），并去掉重复的 / 和 .、.. 段
+	escaped := cleanURLPath(normalizeEscapes(u.EscapedPath()))
+	if p, err := url.PathUnescape(escaped); err == nil {
+		u.Path = p
+		u.RawPath = escaped
+	}
+	if u.Path == "" && u.Opaque == "" {
+		u.Path = "/"
+		u.RawPath = ""
+	}
+
+	u.RawQuery = canonicalQuery(u.RawQuery, cfg)
+	u.ForceQuery = false
+}
+
+// canonicalQuery 删除黑名单参数，按参数名排序（同名参数保持原有顺序）
+func canonicalQuery(rawQuery string, cfg CanonicalConfig) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	var drop []string
+	if !cfg.KeepTrackingParams {
+		drop = append(drop, trackingParams...)
+	}
+	drop = append(drop, cfg.DropParams...)
+
+	var params []string
+	for _, param := range strings.FieldsFunc(rawQuery, func(r rune) bool { return r == '&' || r == ';' }) {
+		name, _, _ := strings.Cut(param, "=")
+		if decoded, err := url.QueryUnescape(name); err == nil {
+			name = decoded
+		}
+		if matchParam(name, drop) {
+			continue
+		}
+		params = append(params, normalizeEscapes(param))
+	}
+
+	if !cfg.KeepQueryOrder {
+		sort.SliceStable(params, func(i, j int) bool {
+			ni, _, _ := strings.Cut(params[i], "=")
+			nj, _, _ := strings.Cut(params[j], "=")
+			return ni < nj
+		})
+	}
+	return strings.Join(params, "&")
+}
+
+// matchParam 判断参数名是否在列表中（不区分大小写，以 * 结尾表示前缀匹配）
+func matchParam(name string, patterns []string) bool {
+	name = strings.ToLower(name)
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == p {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeEscapes 统一百分号编码：非保留字符（字母、数字、-._~）的转义解码，其余转义改为大写十六进制
+func normalizeEscapes(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			c := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if isUnreserved(c) {
+				b.WriteByte(c)
+			} else {
+				b.WriteByte('%')
+				b.WriteString(strings.ToUpper(s[i+1 : i+3]))
+			}
+			i += 2
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// isHex 判断是否为十六进制字符
+func isHex(c byte) bool {
+	return ('0' <= c && c <= '9') || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
+
+// unhex 十六进制字符转数值
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// isUnreserved 判断是否为 RFC 3986 的非保留字符
+func isUnreserved(c byte) bool {
+	return ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// cleanURLPath 合并重复的 /，去掉 . 和 .. 段，保留末尾的 /
+func cleanURLPath(p string) string {
+	if p == "" || p == "/" {
+		return p
+	}
+	trailing := strings.HasSuffix(p, "/")
+	p = path.Clean("/" + p)
+	if trailing && p != "/" {
+		p += "/"
+	}
+	return p
+}
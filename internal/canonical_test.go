@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestCanonicalizeURL(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		cfg  CanonicalConfig
+		want string
+	}{
+		{"host 小写并去掉末尾的点", "HTTP://WWW.Example.COM./", CanonicalConfig{}, "http://www.example.com/"},
+		{"去掉默认端口", "https://a.com:443/x", CanonicalConfig{}, "https://a.com/x"},
+		{"非默认端口保留", "https://a.com:80/x", CanonicalConfig{}, "https://a.com:80/x"},
+		{"保留默认端口", "http://a.com:80/x", CanonicalConfig{KeepDefaultPort: true}, "http://a.com:80/x"},
+		{"空 path", "http://a.com", CanonicalConfig{}, "http://a.com/"},
+		{"去掉 fragment", "http://a.com/p#top", CanonicalConfig{}, "http://a.com/p"},
+		{"保留 fragment", "http://a.com/#/route", CanonicalConfig{KeepFragment: true}, "http://a.com/#/route"},
+		{"国际化域名", "http://例子.测试/", CanonicalConfig{}, "http://xn--fsqu00a.xn--0zwm56d/"},
+		{"IPv6", "http://[2001:DB8::1]:80/", CanonicalConfig{}, "http://[2001:db8::1]/"},
+		{"清理 path", "http://a.com//a/./b/../c/", CanonicalConfig{}, "http://a.com/a/c/"},
+		{"统一百分号编码", "http://a.com/%7euser/%e4%b8%ad", CanonicalConfig{}, "http://a.com/~user/%E4%B8%AD"},
+		{"编码的斜杠不解码", "http://a.com/a%2fb", CanonicalConfig{}, "http://a.com/a%2Fb"},
+		{"query 排序并删除跟踪参数", "http://a.com/?b=2&utm_source=x&a=1&fbclid=y", CanonicalConfig{}, "http://a.com/?a=1&b=2"},
+		{"同名参数保持顺序", "http://a.com/?b=2&a=3&a=1", CanonicalConfig{}, "http://a.com/?a=3&a=1&b=2"},
+		{"保留 query 顺序", "http://a.com/?b=2&a=1", CanonicalConfig{KeepQueryOrder: true}, "http://a.com/?b=2&a=1"},
+		{"保留跟踪参数", "http://a.com/?utm_source=x", CanonicalConfig{KeepTrackingParams: true}, "http://a.com/?utm_source=x"},
+		{"额外删除的参数", "http://a.com/?SessionID=1&ref_a=2&id=3", CanonicalConfig{DropParams: []string{"sessionid", "ref_*"}}, "http://a.com/?id=3"},
+		{"空 query", "http://a.com/p?", CanonicalConfig{}, "http://a.com/p"},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.in)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		canonicalizeURL(u, tt.cfg)
+		if got := u.String(); got != tt.want {
+			t.Errorf("%s: canonicalizeURL(%q) = %q, want %q", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalizeURLKeepUnicodeHost(t *testing.T) {
+	u, err := url.Parse("http://例子.测试./")
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonicalizeURL(u, CanonicalConfig{KeepUnicodeHost: true})
+	if u.Host != "例子.测试" {
+		t.Errorf("Host = %q, want 例子.测试", u.Host)
+	}
+}
+
+func TestNormalizeURLWithoutScheme(t *testing.T) {
+	got, err := normalizeURL("  Example.com:80/a ", CanonicalConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "http://example.com/a"; got != want {
+		t.Errorf("normalizeURL = %q, want %q", got, want)
+	}
+	if _, err := normalizeURL(" ", CanonicalConfig{}); err == nil {
+		t.Error("空 URL 应该报错")
+	}
+}
+
+func TestCleanURLPath(t *testing.T) {
+	tests := map[string]string{
+		"":            "",
+		"/":           "/",
+		"/a//b":       "/a/b",
+		"/a/./b/":     "/a/b/",
+		"/a/../../b":  "/b",
+		"/a/b/..":     "/a",
+		"/a/b/../":    "/a/",
+		"a/b":         "/a/b",
+		"//":          "/",
+		"/%2E%2E/x//": "/%2E%2E/x/",
+	}
+	for in, want := range tests {
+		if got := cleanURLPath(in); got != want {
+			t.Errorf("cleanURLPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNormalizeEscapes(t *testing.T) {
+	tests := map[string]string{
+		"abc":         "abc",
+		"%41%2d%7E":   "A-~",
+		"%2f%3a":      "%2F%3A",
+		"%zz%4":       "%zz%4",
+		"a%20b%e4%b8": "a%20b%E4%B8",
+	}
+	for in, want := range tests {
+		if got := normalizeEscapes(in); got != want {
+			t.Errorf("normalizeEscapes(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// maxDistinctCertSANs SAN 超过该数量的证书视为 CDN、托管平台签发给大量客户的共享证书
+const maxDistinctCertSANs = 10
+
+// CertInfo TLS 叶子证书信息
+// 抓取时不校验证书（InsecureSkipVerify），自签名、过期和主机名不匹配的证书也会记录
+type CertInfo struct {
+	SHA256    string    // 证书 DER 的 SHA-256 指纹（小写十六进制）
+	SubjectCN string    // 使用者 CN
+	SANs      []string  // 使用者可选名称（DNS 名称和 IP）
+	Issuer    string    // 颁发者 DN
+	NotBefore time.Time // 有效期开始
+	NotAfter  time.Time // 有效期结束
+}
+
+// newCertInfo 从最终响应的 TLS 连接状态取出叶子证书，非 HTTPS 时返回 nil
+func newCertInfo(state *tls.ConnectionState) *CertInfo {
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return nil
+	}
+
+	leaf := state.PeerCertificates[0]
+	sum := sha256.Sum256(leaf.Raw)
+	info := &CertInfo{
+		SHA256:    hex.EncodeToString(sum[:]),
+		SubjectCN: leaf.Subject.CommonName,
+		Issuer:    leaf.Issuer.String(),
+		NotBefore: leaf.NotBefore,
+		NotAfter:  leaf.NotAfter,
+	}
+	info.SANs = append(info.SANs, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		info.SANs = append(info.SANs, ip.String())
+	}
+	return info
+}
+
+// Shared 是否为通配符证书或 SAN 很多的共享证书
+// 这类证书由 CDN、托管平台或同一公司的大量站点共用，共用它不能说明是同一套系统
+func (c *CertInfo) Shared() bool {
+	if len(c.SANs) > maxDistinctCertSANs || strings.HasPrefix(c.SubjectCN, "*.") {
+		return true
+	}
+	for _, san := range c.SANs {
+		if strings.HasPrefix(san, "*.") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestNewCertInfo(t *testing.T) {
+	if newCertInfo(nil) != nil || newCertInfo(&tls.ConnectionState{}) != nil {
+		t.Error("没有证书时应返回 nil")
+	}
+
+	leaf := &x509.Certificate{
+		Raw:         []byte("der"),
+		DNSNames:    []string{"a.example.com"},
+		IPAddresses: []net.IP{net.ParseIP("10.0.0.1")},
+	}
+	leaf.Subject.CommonName = "a.example.com"
+	info := newCertInfo(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}})
+	if info == nil || len(info.SHA256) != 64 || info.SubjectCN != "a.example.com" {
+		t.Fatalf("证书信息 = %+v", info)
+	}
+	if len(info.SANs) != 2 || info.SANs[1] != "10.0.0.1" {
+		t.Errorf("SANs = %v", info.SANs)
+	}
+}
+
+func TestCertInfoShared(t *testing.T) {
+	many := make([]string, maxDistinctCertSANs+1)
+	for i := range many {
+		many[i] = fmt.Sprintf("customer%d.example", i)
+	}
+
+	tests := []struct {
+		name string
+		cert CertInfo
+		want bool
+	}{
+		{"单域名", CertInfo{SubjectCN: "a.com", SANs: []string{"a.com", "www.a.com"}}, false},
+		{"IP 证书", CertInfo{SubjectCN: "10.0.0.1"}, false},
+		{"通配符 CN", CertInfo{SubjectCN: "*.a.com"}, true},
+		{"通配符 SAN", CertInfo{SubjectCN: "a.com", SANs: []string{"a.com", "*.a.com"}}, true},
+		{"CDN 多 SAN 证书", CertInfo{SubjectCN: "sni.cdn.example", SANs: many}, true},
+		{"SAN 数量在上限内", CertInfo{SANs: many[:maxDistinctCertSANs]}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.cert.Shared(); got != tt.want {
+			t.Errorf("%s: Shared = %v，期望 %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package internal
+
+import (
+	"bytes"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/transform"
+)
+
+// 字符集来源
+const (
+	CharsetSourceBOM    = "bom"    // 字节序标记
+	CharsetSourceHeader = "header" // Content-Type 响应头
+	CharsetSourceMeta   = "meta"   // HTML <meta charset> / http-equiv
+	CharsetSourceSniff  = "sniff"  // 按字节内容推测
+)
+
+// 字符集推测相关常量
+const (
+	charsetSniffLength = 64 * 1024 // 只用响应开头推测字符集
+	metaPrescanLength  = 1024      // 只在 HTML 前 1024 字节中查找 meta 声明（与 HTML 规范的预扫描一致）
+	defaultCharset     = "windows-1252"
+)
+
+// sniffCandidates 推测字符集时尝试的编码，按优先级排列
+// 同样能无错解码时，CJK 字符占比高的优先；占比相同时靠前的优先
+var sniffCandidates = []struct {
+	name string
+	enc  encoding.Encoding
+}{
+	{"gbk", simplifiedchinese.GBK},
+	{"big5", traditionalchinese.Big5},
+	{"shift_jis", japanese.ShiftJIS},
+	{"euc-jp", japanese.EUCJP},
+	{"euc-kr", korean.EUCKR},
+}
+
+// DetectCharset 检测响应的字符集，返回规范化的字符集名称和来源
+// 优先级：BOM > Content-Type 响应头 > HTML meta（前 1024 字节）> 字节内容推测
+func DetectCharset(body []byte, contentType string) (string, string) {
+	if len(body) == 0 {
+		return "", ""
+	}
+
+	if name := bomCharset(body); name != "" {
+		return name, CharsetSourceBOM
+	}
+
+	if name := headerCharset(contentType); name != "" {
+		return name, CharsetSourceHeader
+	}
+
+	if name := metaCharset(body); name != "" {
+		return name, CharsetSourceMeta
+	}
+
+	return sniffCharset(body), CharsetSourceSniff
+}
+
+// headerCharset 从 Content-Type 响应头中取字符集，没有或无法识别时返回空
+func headerCharset(contentType string) string {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return lookupCharset(params["charset"])
+}
+
+// metaCharset 在 HTML 前 1024 字节中查找 <meta charset> 或 http-equiv="Content-Type" 声明的字符集
+// 没有声明或无法识别时返回空；按 HTML 规范，meta 中声明的 UTF-16 视为 UTF-8
+func metaCharset(body []byte) string {
+	if len(body) > metaPrescanLength {
+		body = body[:metaPrescanLength]
+	}
+
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			if string(name) != "meta" || !hasAttr {
+				continue
+			}
+
+			var declared, httpEquiv, content string
+			for hasAttr {
+				var key, val []byte
+				key, val, hasAttr = z.TagAttr()
+				switch string(key) {
+				case "charset":
+					declared = string(val)
+				case "http-equiv":
+					httpEquiv = strings.ToLower(string(val))
+				case "content":
+					content = string(val)
+				}
+			}
+			if declared == "" && httpEquiv == "content-type" {
+				if _, params, err := mime.ParseMediaType(content); err == nil {
+					declared = params["charset"]
+				}
+			}
+
+			if name := lookupCharset(declared); name != "" {
+				if strings.HasPrefix(name, "utf-16") {
+					return "utf-8"
+				}
+				return name
+			}
+		}
+	}
+}
+
+// lookupCharset 把字符集标签规范化（gb2312、x-gbk 等别名统一为 gbk），无法识别时返回空
+func lookupCharset(label string) string {
+	enc, name := charset.Lookup(strings.TrimSpace(label))
+	if enc == nil {
+		return ""
+	}
+	return canonicalCharsetName(enc, name)
+}
+
+// bomCharset 根据 BOM 判断字符集
+func bomCharset(body []byte) string {
+	switch {
+	case bytes.HasPrefix(body, []byte{0xEF, 0xBB, 0xBF}):
+		return "utf-8"
+	case bytes.HasPrefix(body, []byte{0xFE, 0xFF}):
+		return "utf-16be"
+	case bytes.HasPrefix(body, []byte{0xFF, 0xFE}):
+		return "utf-16le"
+	}
+	return ""
+}
+
+// canonicalCharsetName 字符集的规范名称（gb2312、x-gbk 等别名统一为 gbk）
+func canonicalCharsetName(enc encoding.Encoding, fallback string) string {
+	if name, err := htmlindex.Name(enc); err == nil {
+		return name
+	}
+	return fallback
+}
+
+// sniffCharset 按字节内容推测字符集
+// 合法 UTF-8 直接认定为 UTF-8；否则依次尝试常见 CJK 编码，选择能完整解码且 CJK 字符占比最高的
+func sniffCharset(body []byte) string {
+	sample := body
+	truncated := len(sample) > charsetSniffLength
+	if truncated {
+		sample = sample[:charsetSniffLength]
+	}
+
+	if utf8.Valid(sample) || (truncated && utf8.Valid(trimIncompleteRune(sample))) {
+		return "utf-8"
+	}
+
+	best := ""
+	bestScore := 0.0
+	for _, c := range sniffCandidates {
+		decoded, _, err := transform.Bytes(c.enc.NewDecoder(), sample)
+		if truncated {
+			// 截断处不完整的多字节字符会解码为一个替换字符
+			decoded = bytes.TrimSuffix(decoded, []byte("\uFFFD"))
+		}
+		if err != nil || bytes.ContainsRune(decoded, utf8.RuneError) {
+			continue
+		}
+		if score := cjkRatio(decoded); score > bestScore {
+			best, bestScore = c.name, score
+		}
+	}
+
+	if best == "" {
+		return defaultCharset
+	}
+	return best
+}
+
+// trimIncompleteRune 去掉截断处不完整的 UTF-8 字符
+func trimIncompleteRune(b []byte) []byte {
+	for i := 1; i <= utf8.UTFMax && i <= len(b); i++ {
+		if utf8.RuneStart(b[len(b)-i]) {
+			if !utf8.FullRune(b[len(b)-i:]) {
+				return b[:len(b)-i]
+			}
+			break
+		}
+	}
+	return b
+}
+
+// cjkRatio 非 ASCII 字符中 CJK 字符（汉字、假名、谚文）的占比
+// 用错编码解码时通常会得到大量生僻符号，占比明显偏低
+func cjkRatio(decoded []byte) float64 {
+	nonASCII, cjk := 0, 0
+	for _, r := range string(decoded) {
+		if r < utf8.RuneSelf {
+			continue
+		}
+		nonASCII++
+		if isCJKRune(r) {
+			cjk++
+		}
+	}
+	if nonASCII == 0 {
+		return 0
+	}
+	return float64(cjk) / float64(nonASCII)
+}
+
+// ToUTF8 把响应内容按字符集转换为 UTF-8，并去掉 UTF-8 BOM
+// 字符集未知或转换失败时原样返回
+func ToUTF8(body []byte, charsetName string) []byte {
+	if len(body) == 0 {
+		return body
+	}
+
+	switch charsetName {
+	case "", "utf-8":
+		return bytes.TrimPrefix(body, []byte{0xEF, 0xBB, 0xBF})
+	}
+
+	enc, err := htmlindex.Get(charsetName)
+	if err != nil {
+		return body
+	}
+	decoded, _, err := transform.Bytes(enc.NewDecoder(), body)
+	if err != nil {
+		return body
+	}
+	return bytes.TrimPrefix(decoded, []byte("\uFEFF"))
+}
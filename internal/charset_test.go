@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/transform"
+)
+
+// encodeTestText 把 UTF-8 文本编码为指定编码
+func encodeTestText(t *testing.T, s string, enc encoding.Encoding) []byte {
+	t.Helper()
+	b, _, err := transform.Bytes(enc.NewEncoder(), []byte(s))
+	if err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+	return b
+}
+
+func TestDetectCharset(t *testing.T) {
+	gbkBody := encodeTestText(t, "<html><body>欢迎访问我们的网站，这里有最新的产品和服务信息。</body></html>", simplifiedchinese.GBK)
+
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		wantName    string
+		wantSource  string
+	}{
+		{"空内容", nil, "text/html", "", ""},
+		{"UTF-8 BOM", []byte("\xEF\xBB\xBF<html></html>"), "text/html; charset=gbk", "utf-8", CharsetSourceBOM},
+		{"响应头", []byte("<html></html>"), "text/html; charset=GB2312", "gbk", CharsetSourceHeader},
+		{"响应头优先于 meta", []byte(`<meta charset="big5">`), "text/html; charset=utf-8", "utf-8", CharsetSourceHeader},
+		{"响应头字符集无法识别时看 meta", []byte(`<meta charset="big5">`), "text/html; charset=bogus", "big5", CharsetSourceMeta},
+		{"meta charset", []byte(`<html><head><meta charset="gb2312"></head></html>`), "text/html", "gbk", CharsetSourceMeta},
+		{"meta http-equiv", []byte(`<meta http-equiv="Content-Type" content="text/html; charset=Shift_JIS">`), "", "shift_jis", CharsetSourceMeta},
+		{"meta 声明 windows-1252", []byte(`<meta charset="windows-1252"><p>caf\xe9</p>`), "", "windows-1252", CharsetSourceMeta},
+		{"meta 声明 UTF-16 视为 UTF-8", []byte(`<meta charset="utf-16">`), "", "utf-8", CharsetSourceMeta},
+		{"meta 超出前 1024 字节", []byte(strings.Repeat(" ", 1100) + `<meta charset="big5">`), "", "utf-8", CharsetSourceSniff},
+		{"没有声明的 ASCII", []byte("<html><body>hello</body></html>"), "text/html", "utf-8", CharsetSourceSniff},
+		{"没有声明的 UTF-8", []byte("<html><body>你好</body></html>"), "", "utf-8", CharsetSourceSniff},
+		{"没有声明的 GBK", gbkBody, "text/html", "gbk", CharsetSourceSniff},
+	}
+	for _, tt := range tests {
+		name, source := DetectCharset(tt.body, tt.contentType)
+		if name != tt.wantName || source != tt.wantSource {
+			t.Errorf("%s: DetectCharset = %q, %q，期望 %q, %q", tt.name, name, source, tt.wantName, tt.wantSource)
+		}
+	}
+}
+
+func TestSniffCharset(t *testing.T) {
+	tests := []struct {
+		name string
+		body []byte
+		want string
+	}{
+		{"GBK", encodeTestText(t, "简体中文网站的首页内容，包括新闻、公告和联系方式。", simplifiedchinese.GBK), "gbk"},
+		{"Big5", encodeTestText(t, "繁體中文網站的首頁內容，包括新聞、公告與聯絡方式。", traditionalchinese.Big5), "big5"},
+		{"无法解码", []byte{0x80, 0xFF, 0xFE, 0x81}, defaultCharset},
+	}
+	for _, tt := range tests {
+		if got := sniffCharset(tt.body); got != tt.want {
+			t.Errorf("%s: sniffCharset = %q，期望 %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSniffCharsetTruncatedTail(t *testing.T) {
+	// 截断处落在多字节字符中间时仍应识别出字符集
+	utf8Body := bytes.Repeat([]byte("中"), charsetSniffLength/3+1)
+	if got := sniffCharset(utf8Body); got != "utf-8" {
+		t.Errorf("UTF-8: sniffCharset = %q，期望 utf-8", got)
+	}
+	gbkBody := append([]byte("a"), encodeTestText(t, strings.Repeat("中文", charsetSniffLength/4+1), simplifiedchinese.GBK)...)
+	if got := sniffCharset(gbkBody); got != "gbk" {
+		t.Errorf("GBK: sniffCharset = %q，期望 gbk", got)
+	}
+}
+
+func TestTrimIncompleteRune(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"abc", "abc"},
+		{"a中", "a中"},
+		{"a中"[:3], "a"},
+		{"a中"[:2], "a"},
+		{"😀"[:3], ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := string(trimIncompleteRune([]byte(tt.in))); got != tt.want {
+			t.Errorf("trimIncompleteRune(%q) = %q，期望 %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestToUTF8(t *testing.T) {
+	want := "中文字"
+	tests := []struct {
+		name    string
+		body    []byte
+		charset string
+	}{
+		{"UTF-8 去掉 BOM", []byte("\xEF\xBB\xBF" + want), "utf-8"},
+		{"GBK", encodeTestText(t, want, simplifiedchinese.GBK), "gbk"},
+		{"Big5", encodeTestText(t, want, traditionalchinese.Big5), "big5"},
+	}
+	for _, tt := range tests {
+		if got := string(ToUTF8(tt.body, tt.charset)); got != want {
+			t.Errorf("%s: ToUTF8 = %q，期望 %q", tt.name, got, want)
+		}
+	}
+
+	raw := []byte{0x80, 0x81}
+	if got := ToUTF8(raw, "no-such-charset"); !bytes.Equal(got, raw) {
+		t.Error("未知字符集应原样返回")
+	}
+}
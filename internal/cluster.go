@@ -1,15 +1,13 @@
 package internal
 
 import (
-	"crypto/md5"
 	"fmt"
-	"net/url"
 	"sort"
 )
 
 // quickSimHashCheck 预筛选
 // 根据内容类型使用不同的快速筛选策略
-func quickSimHashCheck(a, b *PageFeatures) bool {
+func quickSimHashCheck(a, b *PageFeatures, t Thresholds) bool {
 	if a == nil || b == nil {
 		return false
 	}
@@ -23,7 +21,7 @@ func quickSimHashCheck(a, b *PageFeatures) bool {
 	case ContentCategoryHTML, ContentCategoryText:
 		// HTML 和文本类：使用 SimHash 预筛选
 		simHashDist := hammingDistance64(a.TextSimHash, b.TextSimHash)
-		if simHashDist > QuickSimHashMaxDist {
+		if simHashDist > t.QuickSimHashMaxDist {
 			return false
 		}
 		// 长度差异超过 50% 也跳过
@@ -42,7 +40,7 @@ func quickSimHashCheck(a, b *PageFeatures) bool {
 			return false
 		}
 		pHashDist := hammingDistance64(a.PHash, b.PHash)
-		return pHashDist <= ImagePHashMaxDist+5 // 预筛选稍微宽松一点
+		return pHashDist <= t.ImagePHashMaxDist+ImagePHashQuickSlack // 预筛选稍微宽松一点
 
 	case ContentCategoryBinary:
 		// 二进制：长度相同才可能匹配
@@ -112,165 +110,140 @@ func (uf *UnionFind) GetClusters() map[int][]int {
 }
 
 // Cluster 对页面进行聚类
-// 先用 host + SimHash 高16位 + 文本长度分桶，减少比较次数
-// 然后对每个桶内用并查集聚类
-func Cluster(pages []*PageWithFeatures) map[string]*ClusterGroup {
-	// 生成粗桶分组
-	buckets := make(map[string][]*PageWithFeatures)
-
+// 先用 LSH 分段索引找出候选对（任意一段指纹相同），减少比较次数
+// 然后对每个候选对做预筛选 + 详细比较，相似的用并查集合并
+func Cluster(pages []*PageWithFeatures, opts Options) (map[string]*ClusterGroup, ClusterStats) {
+	// 只有提取到特征的页面参与聚类
+	eligible := make([]*PageWithFeatures, 0, len(pages))
 	for _, page := range pages {
-		if page.Features == nil {
-			continue
+		if page.Features != nil {
+			eligible = append(eligible, page)
 		}
-		bucketKey := generateBucketKey(page)
-		buckets[bucketKey] = append(buckets[bucketKey], page)
 	}
 
-	// 对每个桶内进行聚类
-	allClusters := make(map[string]*ClusterGroup)
-	clusterIDCounter := 1
-
-	for _, bucketPages := range buckets {
-		if len(bucketPages) < 2 {
-			// 单个页面不聚类
-			continue
-		}
+	// 建立 LSH 索引
+	index := NewLSHIndex(opts.LSHBands, opts.CrossHost, opts.Thresholds)
+	for i, page := range eligible {
+		index.Add(i, page)
+	}
 
-		// 创建并查集
-		uf := NewUnionFind(len(bucketPages))
+	stats := ClusterStats{
+		SimHashBands:  index.SimHashBands(),
+		PHashBands:    index.PHashBands(),
+		CappedBuckets: index.CappedBuckets(),
+	}
 
-		// 先选一个 canonical 页面（优先 200 状态码、文本最长、ID 最小）
-		canonicalPage := selectCanonical(bucketPages)
-		if canonicalPage == nil {
-			continue
+	// 对候选对逐一比较，相似的合并
+	// 记录每个页面第一次被判定重复时命中的分支（按页面 ID）
+	uf := NewUnionFind(len(eligible))
+	reasons := make(map[int]DuplicateReason)
+	index.ForEachCandidate(func(i, j int) {
+		// 已经在同一个集合里，不用再比
+		if uf.Find(i) == uf.Find(j) {
+			return
 		}
-
-		// 找到 canonical 在 bucketPages 中的索引
-		canonicalIdx := 0
-		for i, p := range bucketPages {
-			if p.ID == canonicalPage.ID {
-				canonicalIdx = i
-				break
-			}
+		stats.CandidatePairs++
+		// SimHash 预筛选
+		if !quickSimHashCheck(eligible[i].Features, eligible[j].Features, opts.Thresholds) {
+			return
 		}
-
-		canonical := bucketPages[canonicalIdx]
-
-		// canonical-centered 策略：其他页面只和 canonical 比较
-		// 这样可以减少链式误差，避免 A 和 B 相似、B 和 C 相似，但 A 和 C 不相似的情况
-		for i := 0; i < len(bucketPages); i++ {
-			if i == canonicalIdx {
-				continue
-			}
-			// SimHash 预筛选
-			if !quickSimHashCheck(canonical.Features, bucketPages[i].Features) {
-				continue
-			}
-			// 详细比较
-			if IsDuplicate(canonical.Features, bucketPages[i].Features) {
-				uf.Union(canonicalIdx, i)
-			}
-		}
-
-		// 对于没和 canonical 合并的页面，它们之间再比较一次
-		// 这是为了处理 canonical 选择不当的情况
-		for i := 0; i < len(bucketPages); i++ {
-			if i == canonicalIdx || uf.Find(i) == uf.Find(canonicalIdx) {
-				continue // 跳过 canonical 和已合并的页面
-			}
-			for j := i + 1; j < len(bucketPages); j++ {
-				if j == canonicalIdx || uf.Find(j) == uf.Find(canonicalIdx) {
-					continue
-				}
-				// SimHash 预筛选
-				if !quickSimHashCheck(bucketPages[i].Features, bucketPages[j].Features) {
-					continue
-				}
-				if IsDuplicate(bucketPages[i].Features, bucketPages[j].Features) {
-					uf.Union(i, j)
+		// 详细比较
+		if reason := MatchDuplicate(eligible[i].Features, eligible[j].Features, opts.Thresholds); reason != DuplicateReasonNone {
+			uf.Union(i, j)
+			for _, p := range []*PageWithFeatures{eligible[i], eligible[j]} {
+				if _, ok := reasons[p.ID]; !ok {
+					reasons[p.ID] = reason
 				}
 			}
 		}
+	})
 
-		// 获取聚类结果
-		clusters := uf.GetClusters()
-		for _, members := range clusters {
-			if len(members) < 2 {
-				continue // 单个页面不创建 cluster
-			}
+	// 获取聚类结果，按最小成员 ID 排序，保证 cluster ID 稳定
+	var groups [][]*PageWithFeatures
+	for _, members := range uf.GetClusters() {
+		if len(members) < 2 {
+			continue // 单个页面不创建 cluster
+		}
+		clusterPages := make([]*PageWithFeatures, len(members))
+		for idx, memberIdx := range members {
+			clusterPages[idx] = eligible[memberIdx]
+		}
+		groups = append(groups, clusterPages)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return minPageID(groups[i]) < minPageID(groups[j])
+	})
 
-			// 创建 ClusterGroup
-			clusterID := fmt.Sprintf("cluster-%05d", clusterIDCounter)
-			clusterIDCounter++
+	allClusters := make(map[string]*ClusterGroup)
+	for i, clusterPages := range groups {
+		clusterID := fmt.Sprintf("cluster-%05d", i+1)
 
-			clusterPages := make([]*PageWithFeatures, len(members))
-			for idx, memberIdx := range members {
-				clusterPages[idx] = bucketPages[memberIdx]
-			}
+		// 选择 canonical
+		canonical := selectCanonical(clusterPages)
 
-			// 选择 canonical
-			canonical := selectCanonical(clusterPages)
+		memberReasons := make(map[int]DuplicateReason, len(clusterPages))
+		for _, p := range clusterPages {
+			memberReasons[p.ID] = reasons[p.ID]
+		}
 
-			allClusters[clusterID] = &ClusterGroup{
-				ClusterID: clusterID,
-				Canonical: canonical,
-				Members:   clusterPages,
-			}
+		allClusters[clusterID] = &ClusterGroup{
+			ClusterID:    clusterID,
+			Canonical:    canonical,
+			Members:      clusterPages,
+			MatchReasons: memberReasons,
 		}
 	}
 
-	return allClusters
+	return allClusters, stats
 }
 
 // ClusterGroup 聚类组
 type ClusterGroup struct {
-	ClusterID string
-	Canonical *PageWithFeatures
-	Members   []*PageWithFeatures
+	ClusterID    string
+	Canonical    *PageWithFeatures
+	Members      []*PageWithFeatures
+	MatchReasons map[int]DuplicateReason // 页面 ID -> 聚类时第一次被判定重复命中的分支
 }
 
-// generateBucketKey 生成粗桶 key
-// 根据内容类型使用不同的分桶策略
-func generateBucketKey(page *PageWithFeatures) string {
-	// 提取 host
-	u, err := url.Parse(page.FinalURL)
-	if err != nil {
-		u, _ = url.Parse(page.NormalizedURL)
-	}
-	host := ""
-	if u != nil {
-		host = u.Host
-	}
-
-	category := page.Features.Category
-	var key string
-
-	switch category {
-	case ContentCategoryHTML, ContentCategoryText:
-		// HTML 和文本类：host + 内容类型 + SimHash 高16位 + 文本长度分桶
-		top16Bits := (page.Features.TextSimHash >> 48) & 0xFFFF
-		lengthBucket := page.Features.TextLength / 1000
-		key = fmt.Sprintf("%s|%s|%d|%d", host, category, top16Bits, lengthBucket)
-
-	case ContentCategoryImage:
-		// 图片：host + 内容类型 + pHash 高16位 + 尺寸分桶
-		top16Bits := (page.Features.PHash >> 48) & 0xFFFF
-		// 按图片尺寸分桶（宽度/100 * 高度/100）
-		sizeBucket := (page.Features.ScreenshotW / 100) * (page.Features.ScreenshotH / 100)
-		key = fmt.Sprintf("%s|%s|%d|%d", host, category, top16Bits, sizeBucket)
-
-	case ContentCategoryBinary:
-		// 二进制：host + 内容类型 + 文件大小（精确匹配需要）
-		key = fmt.Sprintf("%s|%s|%d", host, category, page.Features.TextLength)
+// ClusterStats 内容聚类统计信息
+type ClusterStats struct {
+	CandidatePairs int // 实际比较的候选对数量（已在同一集合、跳过比较的不计）
+	SimHashBands   int // SimHash 分段数
+	PHashBands     int // pHash 分段数
+	CappedBuckets  int // 成员超过 MaxLSHBucketSize、只比较了第一个成员的 LSH 桶数
+}
 
-	default:
-		// 默认
-		key = fmt.Sprintf("%s|%s|%d", host, category, page.Features.TextLength)
+// clusterOrigins 返回一组页面涉及的所有 origin（去重、排序）
+func clusterOrigins(pages []*PageWithFeatures) []string {
+	seen := make(map[string]struct{})
+	origins := make([]string, 0)
+	for _, p := range pages {
+		origin := OriginKey(p.FinalURL)
+		if origin == "" {
+			origin = OriginKey(p.NormalizedURL)
+		}
+		if origin == "" {
+			continue
+		}
+		if _, ok := seen[origin]; ok {
+			continue
+		}
+		seen[origin] = struct{}{}
+		origins = append(origins, origin)
 	}
+	sort.Strings(origins)
+	return origins
+}
 
-	// 组合后 MD5 缩短
-	hash := md5.Sum([]byte(key))
-	return fmt.Sprintf("%x", hash)
+// minPageID 返回一组页面中最小的 ID
+func minPageID(pages []*PageWithFeatures) int {
+	minID := pages[0].ID
+	for _, p := range pages[1:] {
+		if p.ID < minID {
+			minID = p.ID
+		}
+	}
+	return minID
 }
 
 // selectCanonical 选择 canonical 页面
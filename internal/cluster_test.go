@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// binaryTestPage 构造二进制页面（按大小 + MD5 精确匹配）
+func binaryTestPage(id int, rawURL string, size int, hash uint64) *PageWithFeatures {
+	return &PageWithFeatures{
+		FetchResult: FetchResult{
+			URLItem:    URLItem{ID: id, NormalizedURL: rawURL},
+			FinalURL:   rawURL,
+			StatusCode: 200,
+		},
+		Features: &PageFeatures{Category: ContentCategoryBinary, TextLength: size, TextSimHash: hash},
+	}
+}
+
+func TestClusterCrossHost(t *testing.T) {
+	newPages := func() []*PageWithFeatures {
+		return []*PageWithFeatures{
+			binaryTestPage(1, "https://a.example.com/app.zip", 1024, 0xABCD),
+			binaryTestPage(2, "https://b.example.com/app.zip", 1024, 0xABCD),
+			binaryTestPage(3, "https://b.example.com/other.zip", 2048, 0x1234),
+		}
+	}
+
+	tests := []struct {
+		crossHost bool
+		clusters  int
+	}{
+		{false, 0},
+		{true, 1},
+	}
+	for _, tt := range tests {
+		opts := Options{CrossHost: tt.crossHost, Thresholds: DefaultThresholds()}
+		clusters, _ := Cluster(newPages(), opts)
+		if len(clusters) != tt.clusters {
+			t.Fatalf("crossHost=%v cluster 数 = %d, want %d", tt.crossHost, len(clusters), tt.clusters)
+		}
+		if !tt.crossHost {
+			continue
+		}
+
+		group := clusters["cluster-00001"]
+		if group == nil || len(group.Members) != 2 {
+			t.Fatalf("cluster-00001 = %+v", group)
+		}
+		want := []string{"https://a.example.com:443", "https://b.example.com:443"}
+		if got := clusterOrigins(group.Members); !reflect.DeepEqual(got, want) {
+			t.Errorf("clusterOrigins = %v, want %v", got, want)
+		}
+		if group.MatchReasons[1] != DuplicateReasonBinaryExact || group.MatchReasons[2] != DuplicateReasonBinaryExact {
+			t.Errorf("MatchReasons = %v", group.MatchReasons)
+		}
+	}
+}
+
+func TestClusterStableIDs(t *testing.T) {
+	var pages []*PageWithFeatures
+	for i := 0; i < 6; i++ {
+		// 1、3、5 和 2、4、6 各为一组
+		pages = append(pages, binaryTestPage(i+1, fmt.Sprintf("https://a.com/%d", i+1), 100, uint64(i%2)))
+	}
+	clusters, stats := Cluster(pages, Options{Thresholds: DefaultThresholds()})
+	if len(clusters) != 2 {
+		t.Fatalf("cluster 数 = %d, want 2", len(clusters))
+	}
+	if minPageID(clusters["cluster-00001"].Members) != 1 || minPageID(clusters["cluster-00002"].Members) != 2 {
+		t.Errorf("cluster ID 应按最小成员 ID 排序")
+	}
+	// 每组 3 个页面，合并只需要比较 2 次，其余候选对已在同一集合
+	if stats.CandidatePairs != 4 {
+		t.Errorf("CandidatePairs = %d, want 4", stats.CandidatePairs)
+	}
+}
@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config 配置文件内容
+// 支持 YAML（.yaml/.yml）和 JSON（.json）两种格式
+type Config struct {
+	Thresholds Thresholds    `yaml:"thresholds" json:"thresholds"`
+	Request    RequestConfig `yaml:"request" json:"request"`
+	Retry      RetryConfig   `yaml:"retry" json:"retry"`
+
+	Canonicalize CanonicalConfig `yaml:"canonicalize" json:"canonicalize"` // URL 规范化，零值表示开启全部规范化步骤
+}
+
+// RequestConfig 请求配置，HTTP 抓取和 Chrome 渲染共用
+type RequestConfig struct {
+	Headers    map[string]string `yaml:"headers" json:"headers"`         // 自定义请求头
+	CookieFile string            `yaml:"cookie_file" json:"cookie_file"` // cookie 文件（Netscape 或 JSON），相对路径相对于配置文件所在目录
+	UserAgent  string            `yaml:"user_agent" json:"user_agent"`   // User-Agent，优先于 ua_profile
+	UAProfile  string            `yaml:"ua_profile" json:"ua_profile"`   // 内置 UA 配置名，默认 chrome-windows
+}
+
+// Thresholds 相似度判定阈值和权重
+// 驱动 IsDuplicate、quickSimHashCheck 和 CalculateSimilarities
+type Thresholds struct {
+	ContentSim          float64    `yaml:"content_sim" json:"content_sim"`                       // 文本相似度阈值，规则1用
+	StructureSim        float64    `yaml:"structure_sim" json:"structure_sim"`                   // 结构相似度阈值，规则1用
+	VisualSim           float64    `yaml:"visual_sim" json:"visual_sim"`                         // 视觉相似度阈值，规则1用
+	VisualHighSim       float64    `yaml:"visual_high_sim" json:"visual_high_sim"`               // 视觉极高相似度阈值，规则2兜底用
+	QuickSimHashMaxDist int        `yaml:"quick_simhash_max_dist" json:"quick_simhash_max_dist"` // SimHash 预筛选最大汉明距离
+	TextSimHashMaxDist  int        `yaml:"text_simhash_max_dist" json:"text_simhash_max_dist"`   // 文本类 SimHash 最大汉明距离
+	ImagePHashMaxDist   int        `yaml:"image_phash_max_dist" json:"image_phash_max_dist"`     // 图片 pHash 最大汉明距离
+	HeaderSim           float64    `yaml:"header_sim" json:"header_sim"`                         // 响应头相似度下限，低于该值不判定为重复，0 表示不检查
+	Weights             SimWeights `yaml:"weights" json:"weights"`                               // 总相似度权重（仅用于展示）
+}
+
+// SimWeights 总相似度各维度权重
+type SimWeights struct {
+	Content   float64 `yaml:"content" json:"content"`
+	Structure float64 `yaml:"structure" json:"structure"`
+	Visual    float64 `yaml:"visual" json:"visual"`
+	Behavior  float64 `yaml:"behavior" json:"behavior"`
+	Header    float64 `yaml:"header" json:"header"`
+}
+
+// DefaultThresholds 返回默认阈值（与 similarity.go 中的常量一致）
+func DefaultThresholds() Thresholds {
+	return Thresholds{
+		ContentSim:          ContentSimThreshold,
+		StructureSim:        StructureSimThreshold,
+		VisualSim:           VisualSimThreshold,
+		VisualHighSim:       VisualHighSimThreshold,
+		QuickSimHashMaxDist: QuickSimHashMaxDist,
+		TextSimHashMaxDist:  TextSimHashMaxDist,
+		ImagePHashMaxDist:   ImagePHashMaxDist,
+		HeaderSim:           HeaderSimThreshold,
+		Weights: SimWeights{
+			Content:   0.4,
+			Structure: 0.25,
+			Visual:    0.25,
+			Behavior:  0.10,
+			Header:    0, // 默认不参与加权，与加入响应头之前的总相似度一致
+		},
+	}
+}
+
+// Validate 检查阈值是否合法
+func (t Thresholds) Validate() error {
+	sims := map[string]float64{
+		"content_sim":     t.ContentSim,
+		"structure_sim":   t.StructureSim,
+		"visual_sim":      t.VisualSim,
+		"visual_high_sim": t.VisualHighSim,
+		"header_sim":      t.HeaderSim,
+	}
+	for name, v := range sims {
+		if v < 0 || v > 1 {
+			return fmt.Errorf("%s 必须在 [0, 1] 范围内: %v", name, v)
+		}
+	}
+
+	dists := map[string]int{
+		"quick_simhash_max_dist": t.QuickSimHashMaxDist,
+		"text_simhash_max_dist":  t.TextSimHashMaxDist,
+		"image_phash_max_dist":   t.ImagePHashMaxDist,
+	}
+	for name, v := range dists {
+		if v < 0 || v > 64 {
+			return fmt.Errorf("%s 必须在 [0, 64] 范围内: %d", name, v)
+		}
+	}
+
+	w := t.Weights
+	if w.Content < 0 || w.Structure < 0 || w.Visual < 0 || w.Behavior < 0 || w.Header < 0 {
+		return fmt.Errorf("权重不能为负数: %+v", w)
+	}
+	if w.Content+w.Structure+w.Visual+w.Behavior+w.Header == 0 {
+		return fmt.Errorf("权重之和不能为 0")
+	}
+
+	return nil
+}
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() *Config {
+	return &Config{
+		Thresholds: DefaultThresholds(),
+		Retry:      DefaultRetryConfig(),
+	}
+}
+
+// LoadConfig 加载配置文件
+// 根据扩展名判断格式：.json 按 JSON 解析，其他按 YAML 解析
+// 先用默认配置打底，配置文件里没有写的字段保持默认值
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取配置文件 %s: %w", path, err)
+	}
+
+	cfg := DefaultConfig()
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		err = json.Unmarshal(data, cfg)
+	} else {
+		err = yaml.Unmarshal(data, cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析配置文件 %s 失败: %w", path, err)
+	}
+
+	if err := cfg.Thresholds.Validate(); err != nil {
+		return nil, fmt.Errorf("配置文件 %s 阈值不合法: %w", path, err)
+	}
+
+	if _, err := NewRetryPolicy(cfg.Retry); err != nil {
+		return nil, fmt.Errorf("配置文件 %s 重试配置不合法: %w", path, err)
+	}
+
+	if cfg.Request.CookieFile != "" && !filepath.IsAbs(cfg.Request.CookieFile) {
+		cfg.Request.CookieFile = filepath.Join(filepath.Dir(path), cfg.Request.CookieFile)
+	}
+
+	return cfg, nil
+}
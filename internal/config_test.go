@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigKeepsDefaults(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.yaml": "thresholds:\n  content_sim: 0.9\n  weights:\n    content: 0.5\n",
+		"config.json": `{"thresholds": {"content_sim": 0.9, "weights": {"content": 0.5}}}`,
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		want := DefaultThresholds()
+		want.ContentSim = 0.9
+		want.Weights.Content = 0.5
+		if cfg.Thresholds != want {
+			t.Errorf("%s: Thresholds = %+v, want %+v", name, cfg.Thresholds, want)
+		}
+		if cfg.Retry.MaxAttempts != DefaultRetryConfig().MaxAttempts {
+			t.Errorf("%s: 没写的 retry 段应保持默认值", name)
+		}
+	}
+}
+
+func TestLoadConfigRejectsInvalidThresholds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("thresholds:\n  content_sim: 1.5\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("content_sim 超出 [0, 1] 应该报错")
+	}
+}
+
+func TestThresholdsValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Thresholds)
+		ok     bool
+	}{
+		{"默认值", func(*Thresholds) {}, true},
+		{"相似度小于 0", func(t *Thresholds) { t.StructureSim = -0.1 }, false},
+		{"距离超过 64", func(t *Thresholds) { t.QuickSimHashMaxDist = 65 }, false},
+		{"权重为负", func(t *Thresholds) { t.Weights.Visual = -1 }, false},
+		{"权重之和为 0", func(t *Thresholds) { t.Weights = SimWeights{} }, false},
+	}
+
+	for _, tt := range tests {
+		th := DefaultThresholds()
+		tt.modify(&th)
+		if err := th.Validate(); (err == nil) != tt.ok {
+			t.Errorf("%s: Validate() = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cookie 从 cookie 文件加载的 cookie
+// HTTP 抓取和 Chrome 使用同一份 cookie，匹配规则与浏览器一致
+type Cookie struct {
+	Name     string
+	Value    string
+	Domain   string // 不带前导点
+	HostOnly bool   // true 只发给 Domain 本身，false 同时发给子域名
+	Path     string
+	Secure   bool
+	HTTPOnly bool
+	Expires  time.Time // 零值表示会话 cookie
+}
+
+// jsonCookie 浏览器插件（EditThisCookie、Cookie-Editor）和 Playwright 导出的 cookie 格式
+type jsonCookie struct {
+	Name           string   `json:"name"`
+	Value          string   `json:"value"`
+	Domain         string   `json:"domain"`
+	HostOnly       *bool    `json:"hostOnly"`
+	Path           string   `json:"path"`
+	Secure         bool     `json:"secure"`
+	HTTPOnly       bool     `json:"httpOnly"`
+	ExpirationDate *float64 `json:"expirationDate"`
+	Expires        *float64 `json:"expires"`
+}
+
+// LoadCookieFile 加载 cookie 文件
+// 支持 Netscape cookies.txt（curl、wget、浏览器插件导出）和 JSON（cookie 数组，或 Playwright 的 {"cookies": [...]}）
+func LoadCookieFile(path string) ([]Cookie, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取 cookie 文件 %s: %w", path, err)
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		cookies, err := parseJSONCookies(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("解析 cookie 文件 %s 失败: %w", path, err)
+		}
+		return cookies, nil
+	}
+
+	cookies, err := parseNetscapeCookies(data)
+	if err != nil {
+		return nil, fmt.Errorf("解析 cookie 文件 %s 失败: %w", path, err)
+	}
+	return cookies, nil
+}
+
+// parseNetscapeCookies 解析 Netscape cookies.txt
+// 每行 7 个字段（tab 分隔）：domain  include_subdomains  path  secure  expires  name  value
+// 以 #HttpOnly_ 开头的行是 HttpOnly cookie，其他 # 开头的行是注释
+func parseNetscapeCookies(data []byte) ([]Cookie, error) {
+	var cookies []Cookie
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		httpOnly := false
+		if strings.HasPrefix(line, "#HttpOnly_") {
+			httpOnly = true
+			line = strings.TrimPrefix(line, "#HttpOnly_")
+		} else if strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 7 {
+			return nil, fmt.Errorf("第 %d 行字段数不足 7 个", lineNo)
+		}
+
+		domain := fields[0]
+		c := Cookie{
+			Name:     fields[5],
+			Value:    strings.Join(fields[6:], "\t"),
+			Domain:   strings.TrimPrefix(strings.ToLower(domain), "."),
+			HostOnly: !strings.EqualFold(fields[1], "TRUE") && !strings.HasPrefix(domain, "."),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HTTPOnly: httpOnly,
+		}
+
+		if expires, err := strconv.ParseInt(fields[4], 10, 64); err == nil && expires > 0 {
+			c.Expires = time.Unix(expires, 0)
+		}
+
+		cookies = append(cookies, normalizeCookie(c))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cookies, nil
+}
+
+// parseJSONCookies 解析 JSON 格式的 cookie
+func parseJSONCookies(data []byte) ([]Cookie, error) {
+	var list []jsonCookie
+	if data[0] == '{' {
+		var wrapper struct {
+			Cookies []jsonCookie `json:"cookies"`
+		}
+		if err := json.Unmarshal(data, &wrapper); err != nil {
+			return nil, err
+		}
+		list = wrapper.Cookies
+	} else if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+
+	cookies := make([]Cookie, 0, len(list))
+	for i, jc := range list {
+		if jc.Name == "" || jc.Domain == "" {
+			return nil, fmt.Errorf("第 %d 个 cookie 缺少 name 或 domain", i+1)
+		}
+
+		c := Cookie{
+			Name:     jc.Name,
+			Value:    jc.Value,
+			Domain:   strings.TrimPrefix(strings.ToLower(jc.Domain), "."),
+			HostOnly: !strings.HasPrefix(jc.Domain, "."),
+			Path:     jc.Path,
+			Secure:   jc.Secure,
+			HTTPOnly: jc.HTTPOnly,
+		}
+		if jc.HostOnly != nil {
+			c.HostOnly = *jc.HostOnly
+		}
+
+		// expirationDate（插件导出）或 expires（Playwright，-1 表示会话 cookie），单位秒
+		expires := jc.ExpirationDate
+		if expires == nil {
+			expires = jc.Expires
+		}
+		if expires != nil && *expires > 0 {
+			c.Expires = time.Unix(int64(*expires), 0)
+		}
+
+		cookies = append(cookies, normalizeCookie(c))
+	}
+	return cookies, nil
+}
+
+// normalizeCookie 补全默认值
+func normalizeCookie(c Cookie) Cookie {
+	if c.Path == "" || !strings.HasPrefix(c.Path, "/") {
+		c.Path = "/"
+	}
+	return c
+}
+
+// Matches 判断 cookie 是否应该发给该 URL（域名、路径、secure、过期时间）
+func (c Cookie) Matches(u *url.URL, now time.Time) bool {
+	if !c.Expires.IsZero() && c.Expires.Before(now) {
+		return false
+	}
+	if c.Secure && u.Scheme != "https" {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if c.HostOnly {
+		if host != c.Domain {
+			return false
+		}
+	} else if host != c.Domain && !strings.HasSuffix(host, "."+c.Domain) {
+		return false
+	}
+
+	return cookiePathMatch(u.EscapedPath(), c.Path)
+}
+
+// cookiePathMatch RFC 6265 5.1.4 路径匹配
+func cookiePathMatch(reqPath, cookiePath string) bool {
+	if reqPath == "" {
+		reqPath = "/"
+	}
+	if reqPath == cookiePath {
+		return true
+	}
+	if !strings.HasPrefix(reqPath, cookiePath) {
+		return false
+	}
+	return strings.HasSuffix(cookiePath, "/") || reqPath[len(cookiePath)] == '/'
+}
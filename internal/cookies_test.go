@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadCookieFileNetscape(t *testing.T) {
+	data := "# Netscape HTTP Cookie File\n" +
+		".example.com\tTRUE\t/\tFALSE\t0\tsid\tabc\n" +
+		"#HttpOnly_www.example.com\tFALSE\t/app\tTRUE\t2000000000\ttoken\tx\ty\n" +
+		"\n"
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cookies, err := LoadCookieFile(path)
+	if err != nil {
+		t.Fatalf("加载 cookie 失败: %v", err)
+	}
+	if len(cookies) != 2 {
+		t.Fatalf("cookie 数量 = %d，期望 2", len(cookies))
+	}
+
+	sid := cookies[0]
+	if sid.Name != "sid" || sid.Domain != "example.com" || sid.HostOnly || !sid.Expires.IsZero() || sid.HTTPOnly {
+		t.Errorf("sid = %+v", sid)
+	}
+	token := cookies[1]
+	if token.Value != "x\ty" || !token.HostOnly || !token.Secure || !token.HTTPOnly || token.Path != "/app" || token.Expires.Unix() != 2000000000 {
+		t.Errorf("token = %+v", token)
+	}
+}
+
+func TestLoadCookieFileJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"插件导出", `[{"name":"sid","value":"abc","domain":".example.com","hostOnly":false,"path":"/","secure":true,"expirationDate":2000000000.5}]`},
+		{"Playwright", `{"cookies":[{"name":"sid","value":"abc","domain":".example.com","path":"/","secure":true,"expires":2000000000}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cookies.json")
+			if err := os.WriteFile(path, []byte(tt.data), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cookies, err := LoadCookieFile(path)
+			if err != nil {
+				t.Fatalf("加载 cookie 失败: %v", err)
+			}
+			if len(cookies) != 1 {
+				t.Fatalf("cookie 数量 = %d，期望 1", len(cookies))
+			}
+			c := cookies[0]
+			if c.Name != "sid" || c.Domain != "example.com" || c.HostOnly || !c.Secure || c.Expires.Unix() != 2000000000 {
+				t.Errorf("cookie = %+v", c)
+			}
+		})
+	}
+}
+
+func TestLoadCookieFileInvalid(t *testing.T) {
+	tests := map[string]string{
+		"字段不足":      "example.com\tTRUE\t/\n",
+		"缺少 domain": `[{"name":"sid","value":"abc"}]`,
+		"JSON 错误":   `[{"name":`,
+	}
+	for name, data := range tests {
+		path := filepath.Join(t.TempDir(), "cookies")
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadCookieFile(path); err == nil {
+			t.Errorf("%s: 应返回错误", name)
+		}
+	}
+}
+
+func TestCookieMatches(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	domain := Cookie{Name: "a", Domain: "example.com", Path: "/"}
+	host := Cookie{Name: "b", Domain: "example.com", HostOnly: true, Path: "/app"}
+	secure := Cookie{Name: "c", Domain: "example.com", Path: "/", Secure: true}
+	expired := Cookie{Name: "d", Domain: "example.com", Path: "/", Expires: now.Add(-time.Hour)}
+
+	tests := []struct {
+		name   string
+		cookie Cookie
+		url    string
+		want   bool
+	}{
+		{"域 cookie 发给子域名", domain, "http://www.example.com/x", true},
+		{"域 cookie 不发给相似域名", domain, "http://badexample.com/", false},
+		{"host-only 不发给子域名", host, "http://www.example.com/app", false},
+		{"host-only 路径匹配", host, "http://example.com/app/page", true},
+		{"路径前缀必须在 / 处分隔", host, "http://example.com/apple", false},
+		{"路径完全相同", host, "http://example.com/app", true},
+		{"secure 只发给 https", secure, "http://example.com/", false},
+		{"secure https", secure, "https://example.com/", true},
+		{"已过期", expired, "http://example.com/", false},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if got := tt.cookie.Matches(u, now); got != tt.want {
+			t.Errorf("%s: Matches(%s) = %v，期望 %v", tt.name, tt.url, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package internal
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 目标展开和协议探测相关常量
+const (
+	MaxExpandedHosts = 65536 // 单个 IP 段/CIDR 最多展开的地址数
+
+	SchemeModeBoth       = "both"        // http 和 https 都探测，保留所有有响应的
+	SchemeModeHTTPSFirst = "https-first" // 先探测 https，不通时再探测 http
+	SchemeModeHTTPFirst  = "http-first"  // 先探测 http，不通时再探测 https
+)
+
+// ParsePorts 解析端口列表，支持逗号分隔和范围，例如 80,443,8000-8010
+func ParsePorts(s string) ([]int, error) {
+	var ports []int
+	seen := make(map[int]bool)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		start, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil {
+			return nil, fmt.Errorf("端口格式错误: %s", part)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil {
+				return nil, fmt.Errorf("端口格式错误: %s", part)
+			}
+		}
+		if start < 1 || end > 65535 || start > end {
+			return nil, fmt.Errorf("端口超出范围: %s", part)
+		}
+		for p := start; p <= end; p++ {
+			if !seen[p] {
+				seen[p] = true
+				ports = append(ports, p)
+			}
+		}
+	}
+	return ports, nil
+}
+
+// ValidSchemeMode 判断协议探测方式是否合法（空值等同于 both）
+func ValidSchemeMode(mode string) bool {
+	switch mode {
+	case "", SchemeModeBoth, SchemeModeHTTPSFirst, SchemeModeHTTPFirst:
+		return true
+	}
+	return false
+}
+
+// expandTarget 把不带 scheme 的输入展开为候选地址（host[:port][/path]，不带 scheme）
+// 支持主机名、host:port、IPv4 段（1.2.3.4-1.2.3.20 或 1.2.3.4-20）和 CIDR；
+// 输入没有端口时按 ports 中的每个端口各生成一个，ports 为空时不加端口（由协议决定默认端口）
+func expandTarget(raw string, ports []int) ([]string, error) {
+	raw = strings.TrimSpace(raw)
+
+	var hosts []string
+	port, path := "", ""
+	if prefix, err := netip.ParsePrefix(raw); err == nil {
+		addrs, err := expandPrefix(prefix)
+		if err != nil {
+			return nil, err
+		}
+		hosts = addrs
+	} else {
+		hostPort := raw
+		if i := strings.IndexAny(raw, "/?#"); i >= 0 {
+			hostPort, path = raw[:i], raw[i:]
+		}
+		host := hostPort
+		if h, p, err := net.SplitHostPort(hostPort); err == nil {
+			host, port = h, p
+		} else {
+			host = strings.Trim(host, "[]")
+		}
+		if host == "" {
+			return nil, fmt.Errorf("缺少主机: %s", raw)
+		}
+
+		if lo, hi, ok := strings.Cut(host, "-"); ok && net.ParseIP(lo) != nil {
+			addrs, err := expandIPRange(lo, hi)
+			if err != nil {
+				return nil, err
+			}
+			hosts = addrs
+		} else {
+			hosts = []string{host}
+		}
+	}
+
+	var portList []string
+	switch {
+	case port != "":
+		portList = []string{port}
+	case len(ports) > 0:
+		for _, p := range ports {
+			portList = append(portList, strconv.Itoa(p))
+		}
+	default:
+		portList = []string{""}
+	}
+
+	targets := make([]string, 0, len(hosts)*len(portList))
+	for _, h := range hosts {
+		for _, p := range portList {
+			if p != "" {
+				targets = append(targets, net.JoinHostPort(h, p)+path)
+			} else if strings.Contains(h, ":") {
+				targets = append(targets, "["+h+"]"+path)
+			} else {
+				targets = append(targets, h+path)
+			}
+		}
+	}
+	return targets, nil
+}
+
+// expandPrefix 展开 CIDR，IPv4 /30 及更大的网段去掉网络地址和广播地址
+func expandPrefix(prefix netip.Prefix) ([]string, error) {
+	prefix = prefix.Masked()
+	hostBits := prefix.Addr().BitLen() - prefix.Bits()
+	if hostBits > 16 {
+		return nil, fmt.Errorf("CIDR %s 超过 %d 个地址", prefix, MaxExpandedHosts)
+	}
+
+	total := 1 << hostBits
+	addrs := make([]string, 0, total)
+	addr := prefix.Addr()
+	for i := 0; i < total; i++ {
+		skip := prefix.Addr().Is4() && hostBits >= 2 && (i == 0 || i == total-1)
+		if !skip {
+			addrs = append(addrs, addr.String())
+		}
+		addr = addr.Next()
+	}
+	return addrs, nil
+}
+
+// expandIPRange 展开 IPv4 段，结束地址可以只写最后一段
+func expandIPRange(lo, hi string) ([]string, error) {
+	start := net.ParseIP(lo).To4()
+	if start == nil {
+		return nil, fmt.Errorf("只支持 IPv4 地址段: %s-%s", lo, hi)
+	}
+	var end net.IP
+	if n, err := strconv.Atoi(hi); err == nil && n >= 0 && n <= 255 {
+		end = append(net.IP{}, start...)
+		end[3] = byte(n)
+	} else if end = net.ParseIP(hi).To4(); end == nil {
+		return nil, fmt.Errorf("地址段格式错误: %s-%s", lo, hi)
+	}
+
+	from, to := binary.BigEndian.Uint32(start), binary.BigEndian.Uint32(end)
+	if from > to {
+		return nil, fmt.Errorf("地址段起始大于结束: %s-%s", lo, hi)
+	}
+	if to-from >= MaxExpandedHosts {
+		return nil, fmt.Errorf("地址段 %s-%s 超过 %d 个地址", lo, hi, MaxExpandedHosts)
+	}
+
+	addrs := make([]string, 0, to-from+1)
+	ip := make(net.IP, 4)
+	for v := from; ; v++ {
+		binary.BigEndian.PutUint32(ip, v)
+		addrs = append(addrs, ip.String())
+		if v == to {
+			break
+		}
+	}
+	return addrs, nil
+}
+
+// dedupeExpanded 合并展开后地址相同的 URL，保留第一个，其余的原始输入记录到 Aliases
+// 只合并展开得到的（需要探测协议的）URL，显式写出的 URL 不受影响；返回被合并的数量
+func dedupeExpanded(items []URLItem) ([]URLItem, int) {
+	return dedupeItems(items, true)
+}
+
+// DedupeURLs 抓取前按规范化后的 URL 去重，保留第一个，其余的原始输入记录到 Aliases，返回被合并的数量
+func DedupeURLs(items []URLItem) ([]URLItem, int) {
+	return dedupeItems(items, false)
+}
+
+// dedupeItems 按 NormalizedURL 合并，expandedOnly 时只合并需要探测协议的 URL
+func dedupeItems(items []URLItem, expandedOnly bool) ([]URLItem, int) {
+	first := make(map[string]int)
+	kept := items[:0]
+	dups := 0
+	for _, item := range items {
+		if expandedOnly && !item.needsScheme {
+			kept = append(kept, item)
+			continue
+		}
+		if i, ok := first[item.NormalizedURL]; ok {
+			kept[i].Aliases = appendAlias(kept[i].Aliases, kept[i].RawURL, item.RawURL)
+			for _, alias := range item.Aliases {
+				kept[i].Aliases = appendAlias(kept[i].Aliases, kept[i].RawURL, alias)
+			}
+			dups++
+			continue
+		}
+		first[item.NormalizedURL] = len(kept)
+		kept = append(kept, item)
+	}
+	return kept, dups
+}
+
+// appendAlias 记录合并进来的原始输入，与自身或已记录的相同时不重复记录
+func appendAlias(aliases []string, self, alias string) []string {
+	if alias == self {
+		return aliases
+	}
+	for _, a := range aliases {
+		if a == alias {
+			return aliases
+		}
+	}
+	return append(aliases, alias)
+}
+
+// ProbeSchemes 为展开得到的 URL 探测协议，只保留有响应的协议
+// mode 为 both 时 http 和 https 都保留（有响应的话），https-first/http-first 时优先的协议有响应就不再探测另一个；
+// 显式写出 scheme 的 URL 原样保留；确定协议后按 canon 重新规范化（去掉默认端口）。
+// 返回保留的 URL，以及两种协议都无响应的地址（作为抓取失败的结果写入报告，不再重复请求）
+func ProbeSchemes(ctx context.Context, fetcher *Fetcher, items []URLItem, mode string, canon CanonicalConfig, parallel int) ([]URLItem, []FetchResult) {
+	if mode == "" {
+		mode = SchemeModeBoth
+	}
+	if parallel <= 0 {
+		parallel = 1
+	}
+
+	results := make([][]URLItem, len(items))
+	failures := make([]*FetchResult, len(items))
+	pending := 0
+	sem := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+
+	for i, item := range items {
+		if !item.needsScheme {
+			results[i] = []URLItem{item}
+			continue
+		}
+		pending++
+
+		wg.Add(1)
+		go func(idx int, it URLItem) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			var errs []string
+			var lastErr error
+			probe := func(scheme string) (URLItem, bool) {
+				candidate := it
+				candidate.NormalizedURL = withScheme(it.NormalizedURL, scheme)
+				candidate.needsScheme = false
+				if err := fetcher.Probe(ctx, candidate.NormalizedURL); err != nil {
+					errs = append(errs, fmt.Sprintf("%s: %v", scheme, err))
+					lastErr = err
+					return candidate, false
+				}
+				if normalized, err := normalizeURL(candidate.NormalizedURL, canon); err == nil {
+					candidate.NormalizedURL = normalized
+				}
+				return candidate, true
+			}
+
+			order := []string{"http", "https"}
+			if mode == SchemeModeHTTPSFirst {
+				order = []string{"https", "http"}
+			}
+			for _, scheme := range order {
+				if candidate, ok := probe(scheme); ok {
+					results[idx] = append(results[idx], candidate)
+					if mode != SchemeModeBoth {
+						break
+					}
+				}
+			}
+
+			if len(results[idx]) == 0 {
+				failed := it
+				failed.NormalizedURL = withScheme(it.NormalizedURL, order[0])
+				failed.needsScheme = false
+				failures[idx] = &FetchResult{
+					URLItem:       failed,
+					RedirectChain: []string{failed.NormalizedURL},
+					RedirectHops:  []RedirectHop{{URL: failed.NormalizedURL, Type: HopTypeStart}},
+					Error:         "协议探测失败: " + strings.Join(errs, "; "),
+					ErrorClass:    ClassifyError(lastErr),
+				}
+			}
+		}(i, item)
+	}
+	wg.Wait()
+
+	kept := make([]URLItem, 0, len(items))
+	var unreachable []FetchResult
+	for i, r := range results {
+		if failures[i] != nil {
+			unreachable = append(unreachable, *failures[i])
+			continue
+		}
+		kept = append(kept, r...)
+	}
+	if pending > 0 {
+		GetLogger().Info("协议探测：%d 个地址，得到 %d 个有响应的 URL，%d 个地址无响应（记为抓取失败）", pending, len(kept)-(len(items)-pending), len(unreachable))
+	}
+	return kept, unreachable
+}
+
+// withScheme 替换 URL 的 scheme
+func withScheme(rawURL, scheme string) string {
+	rest := rawURL
+	if i := strings.Index(rawURL, "://"); i >= 0 {
+		rest = rawURL[i+3:]
+	}
+	return scheme + "://" + rest
+}
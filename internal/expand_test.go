@@ -0,0 +1,169 @@
+package internal
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []int
+		wantErr bool
+	}{
+		{"80,443", []int{80, 443}, false},
+		{" 8000-8003 , 80,8001", []int{8000, 8001, 8002, 8003, 80}, false},
+		{"", nil, false},
+		{"0", nil, true},
+		{"65536", nil, true},
+		{"90-80", nil, true},
+		{"http", nil, true},
+		{"80-x", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePorts(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePorts(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParsePorts(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestExpandTarget(t *testing.T) {
+	tests := []struct {
+		in      string
+		ports   []int
+		want    []string
+		wantErr bool
+	}{
+		{"example.com", nil, []string{"example.com"}, false},
+		{"example.com/admin?x=1", []int{80, 8080}, []string{"example.com:80/admin?x=1", "example.com:8080/admin?x=1"}, false},
+		{"example.com:8443", []int{80}, []string{"example.com:8443"}, false},
+		{"10.0.0.1-3", nil, []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, false},
+		{"10.0.0.254-10.0.1.1", []int{443}, []string{"10.0.0.254:443", "10.0.0.255:443", "10.0.1.0:443", "10.0.1.1:443"}, false},
+		{"192.168.1.0/30", nil, []string{"192.168.1.1", "192.168.1.2"}, false},
+		{"192.168.1.7/32", []int{80}, []string{"192.168.1.7:80"}, false},
+		{"2001:db8::/127", nil, []string{"[2001:db8::]", "[2001:db8::1]"}, false},
+		{"[2001:db8::1]:8080", nil, []string{"[2001:db8::1]:8080"}, false},
+		{"10.0.0.5-1", nil, nil, true},
+		{"10.0.0.0/8", nil, nil, true},
+		{":80", nil, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := expandTarget(tt.in, tt.ports)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("expandTarget(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expandTarget(%q, %v) = %v, want %v", tt.in, tt.ports, got, tt.want)
+		}
+	}
+}
+
+func TestDedupeExpanded(t *testing.T) {
+	items := []URLItem{
+		{RawURL: "10.0.0.0/30", NormalizedURL: "http://10.0.0.1", needsScheme: true},
+		{RawURL: "https://a.com", NormalizedURL: "https://a.com/"},
+		{RawURL: "10.0.0.1", NormalizedURL: "http://10.0.0.1", needsScheme: true},
+		{RawURL: "https://a.com/", NormalizedURL: "https://a.com/"},
+		{RawURL: "10.0.0.1-2", NormalizedURL: "http://10.0.0.1", needsScheme: true, Aliases: []string{"10.0.0.1"}},
+	}
+	kept, dups := dedupeExpanded(items)
+	if dups != 2 || len(kept) != 3 {
+		t.Fatalf("合并 %d 个，剩余 %d 个: %+v", dups, len(kept), kept)
+	}
+	// 显式写出的 URL 不参与展开去重
+	if kept[1].RawURL != "https://a.com" || kept[2].RawURL != "https://a.com/" {
+		t.Errorf("显式 URL 不应被合并: %+v", kept)
+	}
+	if want := []string{"10.0.0.1", "10.0.0.1-2"}; !reflect.DeepEqual(kept[0].Aliases, want) {
+		t.Errorf("Aliases = %v, want %v", kept[0].Aliases, want)
+	}
+
+	kept, dups = DedupeURLs(kept)
+	if dups != 1 || len(kept) != 2 || !reflect.DeepEqual(kept[1].Aliases, []string{"https://a.com/"}) {
+		t.Errorf("DedupeURLs 合并 %d 个: %+v", dups, kept)
+	}
+}
+
+func TestProbeSchemes(t *testing.T) {
+	var mu sync.Mutex
+	methods := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods[r.Method]++
+		mu.Unlock()
+		w.Write([]byte("<html>ok</html>"))
+	}))
+	defer server.Close()
+
+	// 拿一个没人监听的端口
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedAddr := ln.Addr().String()
+	ln.Close()
+
+	live := strings.TrimPrefix(server.URL, "http://")
+	items := []URLItem{
+		{RawURL: live, NormalizedURL: "http://" + live, needsScheme: true},
+		{RawURL: "https://explicit.invalid", NormalizedURL: "https://explicit.invalid/"},
+		{RawURL: closedAddr, NormalizedURL: "http://" + closedAddr, needsScheme: true},
+	}
+
+	fetcher := NewFetcher(FetcherConfig{Timeout: 5 * time.Second, MaxRedirects: 5})
+	kept, unreachable := ProbeSchemes(context.Background(), fetcher, items, SchemeModeBoth, CanonicalConfig{}, 4)
+
+	if len(kept) != 2 || kept[0].NormalizedURL != "http://"+live+"/" || kept[0].needsScheme || kept[1].RawURL != "https://explicit.invalid" {
+		t.Errorf("保留的 URL = %+v", kept)
+	}
+	if len(unreachable) != 1 {
+		t.Fatalf("无响应的地址应作为失败结果返回: %+v", unreachable)
+	}
+	fr := unreachable[0]
+	if fr.RawURL != closedAddr || fr.NormalizedURL != "http://"+closedAddr || fr.ErrorClass == ErrorClassNone ||
+		!strings.Contains(fr.Error, "http: ") || !strings.Contains(fr.Error, "https: ") {
+		t.Errorf("失败结果 = %+v", fr)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if methods[http.MethodGet] != 0 || methods[http.MethodHead] == 0 {
+		t.Errorf("探测应只发 HEAD: %v", methods)
+	}
+}
+
+func TestProbePlainHTTPOnHTTPSPort(t *testing.T) {
+	var mu sync.Mutex
+	var methods []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		methods = append(methods, r.Method)
+		mu.Unlock()
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("<html><body>The plain HTTP request was sent to HTTPS port</body></html>"))
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher(FetcherConfig{Timeout: 5 * time.Second, MaxRedirects: 5})
+	if err := fetcher.Probe(context.Background(), server.URL); err != errPlainHTTPOnHTTPSPort {
+		t.Errorf("Probe err = %v, want %v", err, errPlainHTTPOnHTTPSPort)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{http.MethodHead, http.MethodGet}; !reflect.DeepEqual(methods, want) {
+		t.Errorf("请求方法 = %v, want %v", methods, want)
+	}
+}
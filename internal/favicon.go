@@ -0,0 +1,435 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math/bits"
+	"net/url"
+	"strings"
+
+	"github.com/corona10/goimagehash"
+	"golang.org/x/net/html"
+)
+
+// favicon 相关常量
+const (
+	faviconLinkSniffLength = 64 * 1024 // 只在 HTML 开头查找 <link rel="icon">
+	faviconDataURL         = "data:"   // 内联（data: URI）favicon 的地址
+)
+
+// FaviconInfo favicon 指纹
+type FaviconInfo struct {
+	URL   string // favicon 地址，内联的记为 "data:"
+	MMH3  int32  // Shodan 兼容的 mmh3 哈希（http.favicon.hash）
+	MD5   string // 原始内容的 MD5
+	PHash uint64 // 感知哈希，无法解码（如 SVG）时为 0
+}
+
+// FaviconResolver favicon 解析和下载
+// 按 favicon 地址缓存下载结果，同一个 origin 的页面只下载一次
+type FaviconResolver struct {
+	fetcher  *Fetcher
+	parallel int
+	cache    map[string]*FaviconInfo // favicon 地址（虚拟主机扫描时带目标 IP）-> 指纹，下载失败为 nil
+}
+
+// NewFaviconResolver 创建 favicon 解析器
+func NewFaviconResolver(fetcher *Fetcher, parallel int) *FaviconResolver {
+	return &FaviconResolver{
+		fetcher:  fetcher,
+		parallel: parallel,
+		cache:    make(map[string]*FaviconInfo),
+	}
+}
+
+// Resolve 为一批抓取结果解析并下载 favicon，写入 fr.Favicon，返回得到 favicon 的结果数
+// HTML 页面优先使用 <link rel="icon">，其他情况使用 origin 的 /favicon.ico；需要在释放原始内容之前调用
+func (r *FaviconResolver) Resolve(ctx context.Context, results []FetchResult) int {
+	iconURLs := make([]string, len(results))
+	pending := make(map[string]bool)
+	var items []URLItem
+
+	for i := range results {
+		fr := &results[i]
+		if fr.Error != "" || fr.FinalURL == "" {
+			continue
+		}
+
+		iconURL, inline := faviconLocation(fr)
+		if inline != nil {
+			fr.Favicon = newFaviconInfo(faviconDataURL, inline)
+			continue
+		}
+		if iconURL == "" {
+			continue
+		}
+
+		// 虚拟主机扫描时同一个 favicon 地址在不同 IP 上分别下载
+		key := fr.targetScope(iconURL)
+		iconURLs[i] = key
+		if _, cached := r.cache[key]; cached || pending[key] {
+			continue
+		}
+		pending[key] = true
+		item := URLItem{RawURL: iconURL, NormalizedURL: iconURL}
+		if u, err := url.Parse(iconURL); err == nil && strings.EqualFold(u.Hostname(), fr.VHost) {
+			item.TargetIP, item.VHost = fr.TargetIP, fr.VHost
+		}
+		items = append(items, item)
+	}
+
+	if len(items) > 0 {
+		GetLogger().Info("下载 favicon：%d 个", len(items))
+		for _, res := range r.fetcher.FetchBatch(ctx, items, r.parallel) {
+			r.cache[res.targetScope(res.NormalizedURL)] = faviconFromResult(res)
+		}
+	}
+
+	count := 0
+	for i := range results {
+		if iconURLs[i] == "" {
+			continue
+		}
+		results[i].Favicon = r.cache[iconURLs[i]]
+		if results[i].Favicon != nil {
+			count++
+		}
+	}
+	return count
+}
+
+// faviconLocation 确定页面的 favicon 地址；内联 favicon 直接返回解码后的内容
+func faviconLocation(fr *FetchResult) (string, []byte) {
+	base, err := url.Parse(fr.FinalURL)
+	if err != nil || base.Host == "" {
+		return "", nil
+	}
+
+	if fr.ContentCategory == ContentCategoryHTML && len(fr.RawHTML) > 0 {
+		if href := findIconLink(fr.RawHTML); href != "" {
+			if strings.HasPrefix(strings.ToLower(href), faviconDataURL) {
+				if data := decodeDataURL(href); len(data) > 0 {
+					return "", data
+				}
+			} else if ref, err := url.Parse(href); err == nil {
+				icon := base.ResolveReference(ref)
+				if icon.Scheme == "http" || icon.Scheme == "https" {
+					icon.Fragment = ""
+					return icon.String(), nil
+				}
+			}
+		}
+	}
+
+	return (&url.URL{Scheme: base.Scheme, Host: base.Host, Path: "/favicon.ico"}).String(), nil
+}
+
+// findIconLink 在 HTML 开头查找 favicon 的 <link>
+// rel 包含 icon（icon、shortcut icon）的优先，其次 apple-touch-icon
+func findIconLink(rawHTML []byte) string {
+	if len(rawHTML) > faviconLinkSniffLength {
+		rawHTML = rawHTML[:faviconLinkSniffLength]
+	}
+
+	var icon, touchIcon string
+	z := html.NewTokenizer(bytes.NewReader(rawHTML))
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if icon != "" {
+				return icon
+			}
+			return touchIcon
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "head" && icon != "" {
+				return icon
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			if string(name) == "body" && icon != "" {
+				return icon
+			}
+			if string(name) != "link" || !hasAttr {
+				continue
+			}
+
+			var rel, href string
+			for {
+				key, val, more := z.TagAttr()
+				switch string(key) {
+				case "rel":
+					rel = strings.ToLower(string(val))
+				case "href":
+					href = strings.TrimSpace(string(val))
+				}
+				if !more {
+					break
+				}
+			}
+			if href == "" {
+				continue
+			}
+			for _, token := range strings.Fields(rel) {
+				switch token {
+				case "icon":
+					if icon == "" {
+						icon = href
+					}
+				case "apple-touch-icon", "apple-touch-icon-precomposed":
+					if touchIcon == "" {
+						touchIcon = href
+					}
+				}
+			}
+		}
+	}
+}
+
+// decodeDataURL 解码 base64 的 data: URI，其他形式返回 nil
+func decodeDataURL(s string) []byte {
+	meta, payload, ok := strings.Cut(s[len(faviconDataURL):], ",")
+	if !ok || !strings.HasSuffix(strings.ToLower(meta), ";base64") {
+		return nil
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(payload))
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// faviconFromResult 从下载结果计算 favicon 指纹
+// 非 2xx、返回 HTML（站点把不存在的路径都返回首页）或空内容时视为没有 favicon
+func faviconFromResult(res FetchResult) *FaviconInfo {
+	if res.Error != "" || res.StatusCode < 200 || res.StatusCode >= 300 || len(res.RawBody) == 0 {
+		return nil
+	}
+	switch res.ContentCategory {
+	case ContentCategoryImage, ContentCategoryBinary:
+	case ContentCategoryText:
+		// SVG favicon 按文本类处理
+		if !bytes.Contains(bytes.ToLower(res.RawBody[:min(len(res.RawBody), htmlSniffLength)]), []byte("<svg")) {
+			return nil
+		}
+	default:
+		return nil
+	}
+	// 哈希用未转码的原始字节，与 Shodan 的 http.favicon.hash 一致
+	data := res.RawBody
+	if res.OriginalBody != nil {
+		data = res.OriginalBody
+	}
+	return newFaviconInfo(res.NormalizedURL, data)
+}
+
+// newFaviconInfo 计算 favicon 的 mmh3、MD5 和感知哈希
+func newFaviconInfo(iconURL string, data []byte) *FaviconInfo {
+	sum := md5.Sum(data)
+	info := &FaviconInfo{
+		URL:  iconURL,
+		MMH3: FaviconMMH3(data),
+		MD5:  hex.EncodeToString(sum[:]),
+	}
+	if img, err := decodeFavicon(data); err == nil {
+		if hash, err := goimagehash.PerceptionHash(img); err == nil {
+			info.PHash = hash.GetHash()
+		}
+	}
+	return info
+}
+
+// FaviconMMH3 计算与 Shodan http.favicon.hash 一致的哈希
+// 即 Python 的 mmh3.hash(base64.encodebytes(data))：base64 每 76 个字符换行，末尾也有换行
+func FaviconMMH3(data []byte) int32 {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var b bytes.Buffer
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76])
+		b.WriteByte('\n')
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded)
+	b.WriteByte('\n')
+	return int32(murmur3Sum32(b.Bytes(), 0))
+}
+
+// murmur3Sum32 MurmurHash3 x86 32 位
+func murmur3Sum32(data []byte, seed uint32) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+
+	h := seed
+	nblocks := len(data) / 4
+	for i := 0; i < nblocks; i++ {
+		k := binary.LittleEndian.Uint32(data[i*4:])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	tail := data[nblocks*4:]
+	var k uint32
+	switch len(tail) {
+	case 3:
+		k ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(tail[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(len(data))
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
+
+// FormatFaviconPHash 报告中使用的 favicon 感知哈希（16 位十六进制），没有时为空
+func FormatFaviconPHash(hash uint64) string {
+	if hash == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%016x", hash)
+}
+
+// decodeFavicon 解码 favicon 图片，支持 ICO（内嵌 PNG 或 BMP）、PNG、JPEG、GIF
+func decodeFavicon(data []byte) (image.Image, error) {
+	if len(data) >= 6 && bytes.Equal(data[:4], []byte{0, 0, 1, 0}) {
+		return decodeICO(data)
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// decodeICO 解码 ICO 中尺寸最大的图像
+func decodeICO(data []byte) (image.Image, error) {
+	count := int(binary.LittleEndian.Uint16(data[4:6]))
+	if count == 0 || len(data) < 6+count*16 {
+		return nil, fmt.Errorf("ICO 目录不完整")
+	}
+
+	var best []byte
+	bestArea, bestBPP := -1, -1
+	for i := 0; i < count; i++ {
+		entry := data[6+i*16 : 6+(i+1)*16]
+		w, h := int(entry[0]), int(entry[1])
+		if w == 0 {
+			w = 256
+		}
+		if h == 0 {
+			h = 256
+		}
+		bpp := int(binary.LittleEndian.Uint16(entry[6:8]))
+		size := int(binary.LittleEndian.Uint32(entry[8:12]))
+		offset := int(binary.LittleEndian.Uint32(entry[12:16]))
+		if size <= 0 || offset < 0 || offset+size > len(data) {
+			continue
+		}
+		if area := w * h; area > bestArea || (area == bestArea && bpp > bestBPP) {
+			best, bestArea, bestBPP = data[offset:offset+size], area, bpp
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("ICO 中没有有效的图像")
+	}
+
+	if bytes.HasPrefix(best, []byte("\x89PNG\r\n\x1a\n")) {
+		return png.Decode(bytes.NewReader(best))
+	}
+	return decodeICOBitmap(best)
+}
+
+// decodeICOBitmap 解码 ICO 中的 BMP（没有文件头，高度是 XOR 和 AND 两张位图之和）
+// 支持未压缩的 1/4/8/24/32 位色，忽略 AND 透明掩码
+func decodeICOBitmap(dib []byte) (image.Image, error) {
+	if len(dib) < 40 {
+		return nil, fmt.Errorf("BMP 头不完整")
+	}
+	headerSize := int(binary.LittleEndian.Uint32(dib[0:4]))
+	w := int(int32(binary.LittleEndian.Uint32(dib[4:8])))
+	h := int(int32(binary.LittleEndian.Uint32(dib[8:12]))) / 2
+	bpp := int(binary.LittleEndian.Uint16(dib[14:16]))
+	compression := binary.LittleEndian.Uint32(dib[16:20])
+	colorsUsed := int(binary.LittleEndian.Uint32(dib[32:36]))
+	if w <= 0 || h <= 0 || w > 1024 || h > 1024 || headerSize < 40 || headerSize > len(dib) {
+		return nil, fmt.Errorf("BMP 尺寸不合法: %dx%d", w, h)
+	}
+	if compression != 0 {
+		return nil, fmt.Errorf("不支持压缩的 BMP")
+	}
+
+	pos := headerSize
+	var palette []color.NRGBA
+	switch bpp {
+	case 1, 4, 8:
+		n := colorsUsed
+		if n == 0 {
+			n = 1 << bpp
+		}
+		if pos+n*4 > len(dib) {
+			return nil, fmt.Errorf("BMP 调色板不完整")
+		}
+		palette = make([]color.NRGBA, n)
+		for i := range palette {
+			p := dib[pos+i*4:]
+			palette[i] = color.NRGBA{R: p[2], G: p[1], B: p[0], A: 0xFF}
+		}
+		pos += n * 4
+	case 24, 32:
+	default:
+		return nil, fmt.Errorf("不支持 %d 位色的 BMP", bpp)
+	}
+
+	stride := (w*bpp + 31) / 32 * 4
+	if pos+stride*h > len(dib) {
+		return nil, fmt.Errorf("BMP 像素数据不完整")
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		// BMP 按从下到上存储
+		row := dib[pos+(h-1-y)*stride:]
+		for x := 0; x < w; x++ {
+			var c color.NRGBA
+			switch bpp {
+			case 32:
+				p := row[x*4:]
+				c = color.NRGBA{R: p[2], G: p[1], B: p[0], A: p[3]}
+			case 24:
+				p := row[x*3:]
+				c = color.NRGBA{R: p[2], G: p[1], B: p[0], A: 0xFF}
+			default:
+				bitPos := x * bpp
+				idx := int(row[bitPos/8]>>(8-bpp-bitPos%8)) & (1<<bpp - 1)
+				if idx < len(palette) {
+					c = palette[idx]
+				}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img, nil
+}
@@ -0,0 +1,260 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMurmur3Sum32(t *testing.T) {
+	// 与 Python mmh3.hash 的结果一致
+	tests := []struct {
+		data string
+		want int32
+	}{
+		{"", 0},
+		{"foo", -156908512},
+		{"hello", 613153351},
+		{"The quick brown fox jumps over the lazy dog", 776992547},
+	}
+	for _, tt := range tests {
+		if got := int32(murmur3Sum32([]byte(tt.data), 0)); got != tt.want {
+			t.Errorf("murmur3Sum32(%q) = %d，期望 %d", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestFaviconMMH3(t *testing.T) {
+	// 期望值为 mmh3.hash(base64.encodebytes(data))，与 Shodan http.favicon.hash 的算法相同；
+	// 768 字节的数据 base64 后超过 76 个字符，覆盖换行
+	data := bytes.Repeat(func() []byte {
+		b := make([]byte, 256)
+		for i := range b {
+			b[i] = byte(i)
+		}
+		return b
+	}(), 3)
+	if got := FaviconMMH3(data); got != 1836528006 {
+		t.Errorf("FaviconMMH3 = %d，期望 1836528006", got)
+	}
+	if got := FaviconMMH3([]byte{0, 0, 1, 0}); got != -216455174 {
+		t.Errorf("FaviconMMH3 = %d，期望 -216455174", got)
+	}
+}
+
+// testICOEntry ICO 目录项
+type testICOEntry struct {
+	w, h int
+	bpp  int
+	data []byte
+}
+
+// buildTestICO 构造 ICO 文件
+func buildTestICO(entries []testICOEntry) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, []uint16{0, 1, uint16(len(entries))})
+	offset := 6 + 16*len(entries)
+	for _, e := range entries {
+		b.Write([]byte{byte(e.w), byte(e.h), 0, 0})
+		binary.Write(&b, binary.LittleEndian, []uint16{1, uint16(e.bpp)})
+		binary.Write(&b, binary.LittleEndian, []uint32{uint32(len(e.data)), uint32(offset)})
+		offset += len(e.data)
+	}
+	for _, e := range entries {
+		b.Write(e.data)
+	}
+	return b.Bytes()
+}
+
+// testPNG 纯色 PNG
+func testPNG(t *testing.T, w, h int, c color.Color) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	var b bytes.Buffer
+	if err := png.Encode(&b, img); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// testBMP32 32 位色的 ICO 位图（BITMAPINFOHEADER，高度为两倍，像素从下到上）
+// 上半部分红色，下半部分蓝色
+func testBMP32(w, h int) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, []uint32{40, uint32(w), uint32(h * 2)})
+	binary.Write(&b, binary.LittleEndian, []uint16{1, 32})
+	binary.Write(&b, binary.LittleEndian, []uint32{0, 0, 0, 0, 0, 0})
+	for y := h - 1; y >= 0; y-- {
+		for x := 0; x < w; x++ {
+			if y < h/2 {
+				b.Write([]byte{0, 0, 0xFF, 0xFF}) // BGRA 红
+			} else {
+				b.Write([]byte{0xFF, 0, 0, 0xFF}) // BGRA 蓝
+			}
+		}
+	}
+	// AND 掩码
+	b.Write(make([]byte, (w+31)/32*4*h))
+	return b.Bytes()
+}
+
+func TestDecodeICO(t *testing.T) {
+	red := color.NRGBA{R: 0xFF, A: 0xFF}
+	green := color.NRGBA{G: 0xFF, A: 0xFF}
+
+	t.Run("内嵌 PNG 取尺寸最大的", func(t *testing.T) {
+		ico := buildTestICO([]testICOEntry{
+			{16, 16, 32, testPNG(t, 16, 16, red)},
+			{32, 32, 32, testPNG(t, 32, 32, green)},
+		})
+		img, err := decodeFavicon(ico)
+		if err != nil {
+			t.Fatalf("解码失败: %v", err)
+		}
+		if b := img.Bounds(); b.Dx() != 32 || b.Dy() != 32 {
+			t.Errorf("尺寸 = %v，期望 32x32", b)
+		}
+		if r, g, _, _ := img.At(0, 0).RGBA(); r != 0 || g == 0 {
+			t.Errorf("应选择 32x32 的绿色图像，实际 %v", img.At(0, 0))
+		}
+	})
+
+	t.Run("BMP", func(t *testing.T) {
+		ico := buildTestICO([]testICOEntry{{16, 16, 32, testBMP32(16, 16)}})
+		img, err := decodeFavicon(ico)
+		if err != nil {
+			t.Fatalf("解码失败: %v", err)
+		}
+		if b := img.Bounds(); b.Dx() != 16 || b.Dy() != 16 {
+			t.Errorf("尺寸 = %v，期望 16x16", b)
+		}
+		if got := color.NRGBAModel.Convert(img.At(0, 0)).(color.NRGBA); got != red {
+			t.Errorf("左上角 = %v，期望红色", got)
+		}
+		if got := color.NRGBAModel.Convert(img.At(0, 15)).(color.NRGBA); got != (color.NRGBA{B: 0xFF, A: 0xFF}) {
+			t.Errorf("左下角 = %v，期望蓝色", got)
+		}
+	})
+
+	t.Run("目录不完整", func(t *testing.T) {
+		if _, err := decodeFavicon([]byte{0, 0, 1, 0, 2, 0, 16}); err == nil {
+			t.Error("应返回错误")
+		}
+	})
+
+	t.Run("偏移越界", func(t *testing.T) {
+		ico := buildTestICO([]testICOEntry{{16, 16, 32, testPNG(t, 16, 16, red)}})
+		ico = ico[:len(ico)-10]
+		if _, err := decodeFavicon(ico); err == nil {
+			t.Error("应返回错误")
+		}
+	})
+}
+
+func TestNewFaviconInfo(t *testing.T) {
+	data := testPNG(t, 16, 16, color.NRGBA{R: 0xFF, A: 0xFF})
+	info := newFaviconInfo("https://a.com/favicon.png", data)
+	if info.MMH3 != FaviconMMH3(data) || len(info.MD5) != 32 {
+		t.Errorf("favicon 指纹 = %+v", info)
+	}
+	svg := newFaviconInfo("https://a.com/icon.svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"/>`))
+	if svg.PHash != 0 || FormatFaviconPHash(svg.PHash) != "" {
+		t.Errorf("SVG 没有感知哈希: %+v", svg)
+	}
+}
+
+func TestFindIconLink(t *testing.T) {
+	tests := []struct {
+		name string
+		html string
+		want string
+	}{
+		{"icon", `<html><head><link rel="stylesheet" href="/a.css"><link rel="icon" href="/i.png"></head></html>`, "/i.png"},
+		{"shortcut icon", `<link rel="Shortcut Icon" href=" /s.ico ">`, "/s.ico"},
+		{"icon 优先于 apple-touch-icon", `<link rel="apple-touch-icon" href="/t.png"><link rel="icon" href="/i.png">`, "/i.png"},
+		{"只有 apple-touch-icon", `<link rel="apple-touch-icon-precomposed" href="/t.png">`, "/t.png"},
+		{"没有", `<html><head><title>x</title></head></html>`, ""},
+	}
+	for _, tt := range tests {
+		if got := findIconLink([]byte(tt.html)); got != tt.want {
+			t.Errorf("%s: findIconLink = %q，期望 %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFaviconLocation(t *testing.T) {
+	page := func(u, body string) *FetchResult {
+		return &FetchResult{FinalURL: u, ContentCategory: ContentCategoryHTML, RawHTML: []byte(body)}
+	}
+
+	if u, _ := faviconLocation(page("https://a.com/x/y", `<link rel="icon" href="../img/i.png#v2">`)); u != "https://a.com/img/i.png" {
+		t.Errorf("相对地址 = %q", u)
+	}
+	if u, _ := faviconLocation(page("https://a.com:8443/x", `<p>no icon</p>`)); u != "https://a.com:8443/favicon.ico" {
+		t.Errorf("默认地址 = %q", u)
+	}
+	if u, _ := faviconLocation(page("https://a.com/", `<link rel="icon" href="javascript:void(0)">`)); u != "https://a.com/favicon.ico" {
+		t.Errorf("非 http 地址应使用默认地址，实际 %q", u)
+	}
+	u, data := faviconLocation(page("https://a.com/", `<link rel="icon" href="data:image/png;base64,AAABAA==">`))
+	if u != "" || !bytes.Equal(data, []byte{0, 0, 1, 0}) {
+		t.Errorf("内联 favicon = %q %v", u, data)
+	}
+}
+
+func TestFaviconFromResult(t *testing.T) {
+	png := testPNG(t, 16, 16, color.NRGBA{A: 0xFF})
+	tests := []struct {
+		name string
+		fr   FetchResult
+		want bool
+	}{
+		{"图片", FetchResult{StatusCode: 200, ContentCategory: ContentCategoryImage, RawBody: png}, true},
+		{"SVG", FetchResult{StatusCode: 200, ContentCategory: ContentCategoryText, RawBody: []byte("<svg/>")}, true},
+		{"404", FetchResult{StatusCode: 404, ContentCategory: ContentCategoryImage, RawBody: png}, false},
+		{"首页", FetchResult{StatusCode: 200, ContentCategory: ContentCategoryHTML, RawBody: []byte("<html></html>")}, false},
+		{"纯文本", FetchResult{StatusCode: 200, ContentCategory: ContentCategoryText, RawBody: []byte("not found")}, false},
+	}
+	for _, tt := range tests {
+		if got := faviconFromResult(tt.fr) != nil; got != tt.want {
+			t.Errorf("%s: faviconFromResult != nil = %v，期望 %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFaviconHashUsesOriginalBytes(t *testing.T) {
+	// 带 BOM 的 SVG 按纯文本返回，转码会去掉 BOM，哈希必须按原始字节计算
+	raw := append([]byte{0xEF, 0xBB, 0xBF}, []byte(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 16 16"><circle cx="8" cy="8" r="8"/></svg>`)...)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write(raw)
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher(FetcherConfig{Timeout: 5 * time.Second, MaxRedirects: 5})
+	iconURL := server.URL + "/favicon.svg"
+	res := fetcher.Fetch(context.Background(), URLItem{RawURL: iconURL, NormalizedURL: iconURL})
+	if res.ContentCategory != ContentCategoryText || bytes.Equal(res.RawBody, raw) {
+		t.Fatalf("测试前提不成立：类型 %s，RawBody 应已去掉 BOM", res.ContentCategory)
+	}
+
+	info := faviconFromResult(res)
+	if info == nil {
+		t.Fatal("SVG favicon 应该被识别")
+	}
+	if want := FaviconMMH3(raw); info.MMH3 != want {
+		t.Errorf("MMH3 = %d，期望按原始字节计算的 %d", info.MMH3, want)
+	}
+}
@@ -58,6 +58,12 @@ func extractTextFeatures(features *PageFeatures, htmlContent string) error {
 		return err
 	}
 
+	extractTextFeaturesFromDoc(features, doc)
+	return nil
+}
+
+// extractTextFeaturesFromDoc 从已解析的文档提取文本特征
+func extractTextFeaturesFromDoc(features *PageFeatures, doc *goquery.Document) {
 	// 抽取正文文本
 	bodyText := extractMainText(doc)
 
@@ -69,8 +75,6 @@ func extractTextFeatures(features *PageFeatures, htmlContent string) error {
 
 	// 计算 SimHash
 	features.TextSimHash = computeSimHash(cleaned)
-
-	return nil
 }
 
 // extractMainText 抽取正文文本
@@ -184,11 +188,11 @@ func cleanText(text string) string {
 	re := regexp.MustCompile(`\s+`)
 	text = re.ReplaceAllString(text, " ")
 
-	// 去掉很短的 token（少于 2 个字符）
+	// 去掉很短的 token（少于 2 个字符），中日韩文字单字也有意义，保留
 	words := strings.Fields(text)
 	var filtered []string
 	for _, word := range words {
-		if len(word) >= 2 {
+		if utf8.RuneCountInString(word) >= MinWordTokenLength || containsCJK(word) {
 			filtered = append(filtered, word)
 		}
 	}
@@ -198,8 +202,9 @@ func cleanText(text string) string {
 
 // computeSimHash 计算 64-bit SimHash
 // 对每个 token 计算 hash，然后累加每个 bit 位，最后生成指纹
+// token 由 tokenize 生成（拉丁文按词，中日韩文字按字符 n-gram）
 func computeSimHash(text string) uint64 {
-	tokens := strings.Fields(text)
+	tokens := tokenize(text)
 	if len(tokens) == 0 {
 		return 0
 	}
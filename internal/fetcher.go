@@ -1,10 +1,12 @@
 package internal
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"regexp"
 	"strings"
@@ -12,31 +14,68 @@ import (
 	"time"
 )
 
+// 协议探测相关常量
+const probeSniffLength = 4096 // 探测时读取的响应长度
+
+// plainHTTPOnHTTPSMarkers 明文请求发到 HTTPS 端口时常见服务器返回的错误页特征（小写）
+var plainHTTPOnHTTPSMarkers = []string{
+	"plain http request was sent to https port",         // nginx
+	"speaking plain http to an ssl-enabled server port", // apache
+	"client sent an http request to an https server",    // go
+	"this combination of host and port requires tls",    // tomcat
+}
+
+// FetcherConfig 抓取器配置
+type FetcherConfig struct {
+	Timeout      time.Duration
+	MaxRedirects int
+	Proxies      *ProxyPool      // 上游代理，nil 表示直连
+	Request      *RequestProfile // UA、请求头和 cookie，nil 时使用默认 UA
+	Scheduler    *HostScheduler  // 按 host 限速，nil 表示不限制
+	Retry        *RetryPolicy    // 重试策略，nil 表示不重试
+	VHostMode    bool            // 虚拟主机扫描：同一主机名可能连到不同 IP，不复用连接
+}
+
 // Fetcher HTTP 抓取器
 type Fetcher struct {
 	client       *http.Client
 	maxRedirects int
+	request      *RequestProfile
+	scheduler    *HostScheduler
+	retry        *RetryPolicy
 }
 
 // NewFetcher 创建新的抓取器
-func NewFetcher(timeout time.Duration, maxRedirects int) *Fetcher {
+func NewFetcher(cfg FetcherConfig) *Fetcher {
+	maxRedirects := cfg.MaxRedirects
 	transport := &http.Transport{
 		TLSClientConfig: &tls.Config{
 			InsecureSkipVerify: true, // 忽略 SSL 证书错误
 		},
+		Proxy:             cfg.Proxies.HTTPProxyFunc(),
+		DialContext:       vhostDialContext(&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}),
+		DisableKeepAlives: cfg.VHostMode,
+	}
+
+	request := cfg.Request
+	if request == nil {
+		request = &RequestProfile{UserAgent: UAProfiles[DefaultUAProfile], Headers: make(http.Header)}
 	}
 
 	fetcher := &Fetcher{
 		maxRedirects: maxRedirects,
+		request:      request,
+		scheduler:    cfg.Scheduler,
+		retry:        cfg.Retry,
 	}
 
 	client := &http.Client{
-		Timeout:   timeout,
+		Timeout:   cfg.Timeout,
 		Transport: transport,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			// 限制重定向次数
 			if len(via) >= maxRedirects {
-				return fmt.Errorf("重定向次数超过限制 (%d)", maxRedirects)
+				return fmt.Errorf("%w (%d)", errTooManyRedirects, maxRedirects)
 			}
 			return nil
 		},
@@ -51,16 +90,19 @@ func (f *Fetcher) Fetch(ctx context.Context, item URLItem) FetchResult {
 	result := FetchResult{
 		URLItem:       item,
 		RedirectChain: []string{item.NormalizedURL},
+		RedirectHops:  []RedirectHop{{URL: item.NormalizedURL, Type: HopTypeStart}},
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", item.NormalizedURL, nil)
+	// 虚拟主机 URL 连接到目标 IP
+	req, err := http.NewRequestWithContext(withDialTarget(ctx, item), "GET", item.NormalizedURL, nil)
 	if err != nil {
 		result.Error = fmt.Sprintf("创建请求失败: %v", err)
+		result.ErrorClass = ErrorClassOther
 		return result
 	}
 
-	// 设置 User-Agent
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
+	// 设置 User-Agent、自定义请求头和 cookie
+	f.request.Apply(req)
 
 	// 为本次请求创建独立的重定向链记录（避免并发竞态）
 	redirectChain := make([]string, 0)
@@ -72,13 +114,16 @@ func (f *Fetcher) Fetch(ctx context.Context, item URLItem) FetchResult {
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			// 限制重定向次数
 			if len(via) >= f.maxRedirects {
-				return fmt.Errorf("重定向次数超过限制 (%d)", f.maxRedirects)
+				return fmt.Errorf("%w (%d)", errTooManyRedirects, f.maxRedirects)
 			}
 			// CheckRedirect 会被多次调用，每次调用时：
 			// - via 包含所有之前的请求（包括原始请求）
 			// - req.URL 是下一个跳转目标（Location header 指向的 URL）
 			// 我们只需要记录 req.URL（下一个跳转目标），避免重复
 			redirectChain = append(redirectChain, req.URL.String())
+
+			// 按跳转目标重新匹配 cookie，跳到其他 host 时不带自定义请求头
+			f.request.ApplyRedirect(req, via[0])
 			return nil
 		},
 	}
@@ -86,6 +131,7 @@ func (f *Fetcher) Fetch(ctx context.Context, item URLItem) FetchResult {
 	resp, err := tempClient.Do(req)
 	if err != nil {
 		result.Error = fmt.Sprintf("请求失败: %v", err)
+		result.ErrorClass = ClassifyError(err)
 		return result
 	}
 	defer resp.Body.Close()
@@ -105,24 +151,55 @@ func (f *Fetcher) Fetch(ctx context.Context, item URLItem) FetchResult {
 		}
 	}
 
+	// 除起点外都是 HTTP 重定向
+	result.RedirectHops = result.RedirectHops[:1]
+	for _, hop := range result.RedirectChain[1:] {
+		result.RedirectHops = append(result.RedirectHops, RedirectHop{URL: hop, Type: HopTypeHTTP})
+	}
+
 	// 记录最终状态
 	result.StatusCode = resp.StatusCode
 	result.FinalURL = resp.Request.URL.String()
 	result.ContentType = resp.Header.Get("Content-Type")
+	result.Headers = NormalizeHeaders(resp.Header)
+	result.HeaderHash = HeaderFingerprintHash(HeaderFingerprint(result.Headers))
+	result.Cert = newCertInfo(resp.TLS)
 	result.ContentLength = resp.ContentLength
 
 	// 读取 body（所有类型都读取，以支持非 HTML 内容的相似性检测）
-	limitReader := io.LimitReader(resp.Body, MaxHTMLSize)
+	maxBodySize := int64(MaxHTMLSize)
+	if item.maxBodySize > 0 {
+		maxBodySize = item.maxBodySize
+	}
+	limitReader := io.LimitReader(resp.Body, maxBodySize)
 	body, err := io.ReadAll(limitReader)
 	if err != nil {
 		result.Error = fmt.Sprintf("读取响应体失败: %v", err)
+		result.ErrorClass = ErrorClassRead
+		if class := ClassifyError(err); class == ErrorClassTimeout || class == ErrorClassCancelled {
+			result.ErrorClass = class
+		}
 		return result
 	}
 	result.ContentLength = int64(len(body))
 
-	// 根据 Content-Type 分类处理
+	// 限流检测按请求的 host 计算（与 FetchBatch 中 Acquire 的 host 一致）
+	f.scheduler.Observe(item.connectURL(), resp.StatusCode, resp.Header, body)
+
+	// 根据 Content-Type 和内容嗅探分类处理
 	contentType := strings.ToLower(resp.Header.Get("Content-Type"))
-	result.ContentCategory = categorizeContent(contentType)
+	result.DeclaredCategory = categorizeContent(contentType)
+	result.DetectedCategory = SniffContentCategory(body)
+	result.ContentCategory = reconcileContentCategory(result.DeclaredCategory, result.DetectedCategory, contentType, body)
+
+	// HTML 和文本类内容先转换为 UTF-8，后续标题、指纹、特征和规则都按 UTF-8 处理
+	if result.ContentCategory == ContentCategoryHTML || result.ContentCategory == ContentCategoryText {
+		result.Charset, result.CharsetSource = DetectCharset(body, resp.Header.Get("Content-Type"))
+		if decoded := ToUTF8(body, result.Charset); !bytes.Equal(decoded, body) {
+			result.OriginalBody = body
+			body = decoded
+		}
+	}
 
 	switch result.ContentCategory {
 	case ContentCategoryHTML:
@@ -137,7 +214,70 @@ func (f *Fetcher) Fetch(ctx context.Context, item URLItem) FetchResult {
 	return result
 }
 
+// Probe 探测 URL 是否有 HTTP 响应（不跟随重定向），没有响应时返回原因
+// 先发 HEAD，不让服务器生成完整页面；返回 400 时再用 GET 读取响应开头：
+// 明文请求发到 HTTPS 端口时很多服务器会返回 400，这种情况视为没有响应
+func (f *Fetcher) Probe(ctx context.Context, rawURL string) error {
+	release, err := f.scheduler.Acquire(ctx, rawURL)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	status, _, err := f.probeRequest(ctx, http.MethodHead, rawURL)
+	if err != nil || status != http.StatusBadRequest {
+		return err
+	}
+	status, head, err := f.probeRequest(ctx, http.MethodGet, rawURL)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusBadRequest && isPlainHTTPOnHTTPSPort(head) {
+		return errPlainHTTPOnHTTPSPort
+	}
+	return nil
+}
+
+// probeRequest 发送一次探测请求，返回状态码和最多 probeSniffLength 字节的响应开头
+func (f *Fetcher) probeRequest(ctx context.Context, method, rawURL string) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	f.request.Apply(req)
+
+	client := &http.Client{
+		Timeout:   f.client.Timeout,
+		Transport: f.client.Transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	head, _ := io.ReadAll(io.LimitReader(resp.Body, probeSniffLength))
+	return resp.StatusCode, head, nil
+}
+
+// isPlainHTTPOnHTTPSPort 判断 400 响应是否为"明文请求发到了 HTTPS 端口"
+func isPlainHTTPOnHTTPSPort(body []byte) bool {
+	lower := strings.ToLower(string(body))
+	for _, marker := range plainHTTPOnHTTPSMarkers {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
 // FetchBatch 批量抓取（并发，支持 ctx 取消）
+// 每次尝试先等待 host 的并发名额和速率间隔（配置了调度器时），再占用全局并发名额，
+// 避免同一个 host 的大量 URL 占满全局并发、阻塞其他 host；失败时按重试策略等待后重试，
+// 等待期间不占用任何名额
 func (f *Fetcher) FetchBatch(ctx context.Context, items []URLItem, parallel int) []FetchResult {
 	results := make([]FetchResult, len(items))
 	sem := make(chan struct{}, parallel)
@@ -149,33 +289,41 @@ func (f *Fetcher) FetchBatch(ctx context.Context, items []URLItem, parallel int)
 		case <-ctx.Done():
 			// ctx 已取消，填充剩余结果为空
 			for j := i; j < len(items); j++ {
-				results[j] = FetchResult{
-					URLItem: items[j],
-					Error:   "context cancelled",
-				}
+				results[j] = cancelledFetchResult(items[j])
 			}
+			wg.Wait()
 			return results
 		default:
 		}
 
 		wg.Add(1)
-		sem <- struct{}{}
 		go func(idx int, it URLItem) {
 			defer wg.Done()
-			defer func() { <-sem }()
-
-			// 再次检查 ctx（goroutine 内）
-			select {
-			case <-ctx.Done():
-				results[idx] = FetchResult{
-					URLItem: it,
-					Error:   "context cancelled",
+
+			attempts := f.retry.Do(ctx, func(attempt int) bool {
+				release, err := f.scheduler.Acquire(ctx, it.connectURL())
+				if err != nil {
+					results[idx] = cancelledFetchResult(it)
+					return false
 				}
-				return
-			default:
-			}
+				defer release()
+
+				select {
+				case sem <- struct{}{}:
+				case <-ctx.Done():
+					results[idx] = cancelledFetchResult(it)
+					return false
+				}
+				defer func() { <-sem }()
+
+				results[idx] = f.Fetch(ctx, it)
+				return f.shouldRetry(ctx, &results[idx])
+			})
 
-			results[idx] = f.Fetch(ctx, it)
+			results[idx].Attempts = attempts
+			if results[idx].ErrorClass == ErrorClassNone && f.retry.RetryableStatus(results[idx].StatusCode) {
+				results[idx].ErrorClass = ErrorClassHTTPStatus
+			}
 		}(i, item)
 	}
 
@@ -183,6 +331,26 @@ func (f *Fetcher) FetchBatch(ctx context.Context, items []URLItem, parallel int)
 	return results
 }
 
+// shouldRetry 判断抓取结果是否需要重试
+func (f *Fetcher) shouldRetry(ctx context.Context, result *FetchResult) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if result.Error != "" {
+		return f.retry.RetryableClass(result.ErrorClass)
+	}
+	return f.retry.RetryableStatus(result.StatusCode)
+}
+
+// cancelledFetchResult ctx 取消时的抓取结果
+func cancelledFetchResult(item URLItem) FetchResult {
+	return FetchResult{
+		URLItem:    item,
+		Error:      "context cancelled",
+		ErrorClass: ErrorClassCancelled,
+	}
+}
+
 // isHTML 判断 Content-Type 是否为 HTML
 func isHTML(contentType string) bool {
 	return strings.Contains(strings.ToLower(contentType), "text/html")
@@ -192,27 +360,28 @@ func isHTML(contentType string) bool {
 func categorizeContent(contentType string) ContentCategory {
 	ct := strings.ToLower(contentType)
 
-	// HTML
-	if strings.Contains(ct, "text/html") {
+	// HTML（含 XHTML）
+	if strings.Contains(ct, "text/html") || strings.Contains(ct, "application/xhtml+xml") {
 		return ContentCategoryHTML
 	}
 
-	// 文本类（JSON, XML, 纯文本等）
+	// 图片（在 +xml 之前判断，image/svg+xml 是图片）
+	if strings.Contains(ct, "image/") {
+		return ContentCategoryImage
+	}
+
+	// 文本类（JSON, XML, 纯文本等，含 application/*+json、application/*+xml）
 	if strings.Contains(ct, "application/json") ||
 		strings.Contains(ct, "application/xml") ||
-		strings.Contains(ct, "text/xml") ||
-		strings.Contains(ct, "text/plain") ||
-		strings.Contains(ct, "text/css") ||
-		strings.Contains(ct, "text/javascript") ||
-		strings.Contains(ct, "application/javascript") {
+		strings.Contains(ct, "+json") ||
+		strings.Contains(ct, "+xml") ||
+		strings.Contains(ct, "text/") ||
+		strings.Contains(ct, "application/javascript") ||
+		strings.Contains(ct, "application/x-javascript") ||
+		strings.Contains(ct, "application/ecmascript") {
 		return ContentCategoryText
 	}
 
-	// 图片
-	if strings.Contains(ct, "image/") {
-		return ContentCategoryImage
-	}
-
 	// 其他有内容的响应归为二进制
 	if ct != "" {
 		return ContentCategoryBinary
@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFetchRedirectHops(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			http.Redirect(w, r, "/a", http.StatusMovedPermanently)
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusFound)
+		default:
+			fmt.Fprint(w, "<html><body>b</body></html>")
+		}
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher(FetcherConfig{Timeout: 5 * time.Second, MaxRedirects: 5})
+	start := server.URL + "/"
+	fr := fetcher.FetchBatch(context.Background(), []URLItem{{RawURL: start, NormalizedURL: start}}, 1)[0]
+	if fr.Error != "" {
+		t.Fatalf("抓取失败: %s", fr.Error)
+	}
+
+	want := []RedirectHop{
+		{URL: start, Type: HopTypeStart},
+		{URL: server.URL + "/a", Type: HopTypeHTTP},
+		{URL: server.URL + "/b", Type: HopTypeHTTP},
+	}
+	if !reflect.DeepEqual(fr.RedirectHops, want) {
+		t.Errorf("RedirectHops = %+v，期望 %+v", fr.RedirectHops, want)
+	}
+	if len(fr.RedirectChain) != len(fr.RedirectHops) {
+		t.Errorf("RedirectChain 与 RedirectHops 应一一对应: %v", fr.RedirectChain)
+	}
+	if fr.FinalURL != server.URL+"/b" {
+		t.Errorf("FinalURL = %s", fr.FinalURL)
+	}
+}
+
+func TestFetchRedirectDropsHeadersAcrossHosts(t *testing.T) {
+	seen := make(chan http.Header, 4)
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen <- r.Header.Clone()
+		fmt.Fprint(w, "<html><body>other</body></html>")
+	}))
+	defer other.Close()
+
+	var sameHost http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			http.Redirect(w, r, "/login", http.StatusFound)
+		case "/login":
+			sameHost = r.Header.Clone()
+			http.Redirect(w, r, other.URL+"/landing", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	profile, err := NewRequestProfile(RequestConfig{
+		Headers:   map[string]string{"Authorization": "Bearer secret", "X-Api-Key": "key"},
+		UserAgent: "test-agent",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetcher := NewFetcher(FetcherConfig{Timeout: 5 * time.Second, MaxRedirects: 5, Request: profile})
+	start := server.URL + "/"
+	fr := fetcher.Fetch(context.Background(), URLItem{RawURL: start, NormalizedURL: start})
+	if fr.Error != "" {
+		t.Fatalf("抓取失败: %s", fr.Error)
+	}
+
+	if sameHost.Get("Authorization") != "Bearer secret" || sameHost.Get("X-Api-Key") != "key" {
+		t.Errorf("同一 host 的跳转应带自定义请求头: %v", sameHost)
+	}
+	h := <-seen
+	if h.Get("Authorization") != "" || h.Get("X-Api-Key") != "" {
+		t.Errorf("跳到其他 host 时不应带自定义请求头: %v", h)
+	}
+	if h.Get("User-Agent") != "test-agent" {
+		t.Errorf("跳到其他 host 时应保留 User-Agent: %q", h.Get("User-Agent"))
+	}
+}
@@ -0,0 +1,192 @@
+package internal
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxHeaderValueLength 单个响应头保留的最大长度，超长的 CSP 等截断
+const maxHeaderValueLength = 1024
+
+// volatileHeaders 每次请求都会变化、不能说明后端的响应头（小写）
+var volatileHeaders = map[string]bool{
+	"date":                          true,
+	"expires":                       true,
+	"last-modified":                 true,
+	"etag":                          true,
+	"age":                           true,
+	"content-length":                true,
+	"keep-alive":                    true,
+	"x-request-id":                  true,
+	"x-correlation-id":              true,
+	"x-trace-id":                    true,
+	"traceparent":                   true,
+	"x-b3-traceid":                  true,
+	"x-b3-spanid":                   true,
+	"x-amz-request-id":              true,
+	"x-amz-id-2":                    true,
+	"x-amz-cf-id":                   true,
+	"cf-ray":                        true,
+	"x-timer":                       true,
+	"x-runtime":                     true,
+	"server-timing":                 true,
+	"eagleeye-traceid":              true,
+	"x-swift-savetime":              true,
+	"x-swift-cachetime":             true,
+	"x-cache-lookup":                true,
+	"x-cache-hits":                  true,
+	"x-served-by":                   true,
+	"x-varnish":                     true,
+	"x-nws-log-uuid":                true,
+	"x-ws-request-id":               true,
+	"x-cdn-request-id":              true,
+	"x-response-time":               true,
+	"x-envoy-upstream-service-time": true,
+}
+
+// headerFingerprintNames 参与响应头指纹的头（小写）
+// 只选能说明服务端软件、框架和安全/缓存配置的头，同一个后端返回的这些头通常一致
+var headerFingerprintNames = []string{
+	"server",
+	"x-powered-by",
+	"x-aspnet-version",
+	"x-aspnetmvc-version",
+	"x-generator",
+	"via",
+	"set-cookie",
+	"x-frame-options",
+	"content-security-policy",
+	"x-content-type-options",
+	"x-xss-protection",
+	"strict-transport-security",
+	"referrer-policy",
+	"access-control-allow-origin",
+	"cache-control",
+	"pragma",
+	"vary",
+}
+
+// cspTokenPattern CSP 中每次请求变化的 nonce 和哈希
+var cspTokenPattern = regexp.MustCompile(`'(nonce|sha256|sha384|sha512)-[^']*'`)
+
+// NormalizeHeaders 规范化响应头
+// 头名称转小写，多个值用 ", " 连接；去掉 Date、ETag、请求 ID 等易变头；
+// Set-Cookie 只保留排序去重后的 cookie 名称（值每次不同，名称能说明框架，如 JSESSIONID、PHPSESSID）
+func NormalizeHeaders(header http.Header) map[string]string {
+	if len(header) == 0 {
+		return nil
+	}
+
+	normalized := make(map[string]string, len(header))
+	for name, values := range header {
+		key := strings.ToLower(name)
+		if volatileHeaders[key] || len(values) == 0 {
+			continue
+		}
+
+		var value string
+		if key == "set-cookie" {
+			value = strings.Join(setCookieNames(values), ", ")
+		} else {
+			trimmed := make([]string, 0, len(values))
+			for _, v := range values {
+				if v = strings.TrimSpace(v); v != "" {
+					trimmed = append(trimmed, v)
+				}
+			}
+			value = strings.Join(trimmed, ", ")
+		}
+		if value == "" {
+			continue
+		}
+		if len(value) > maxHeaderValueLength {
+			value = value[:maxHeaderValueLength]
+		}
+		normalized[key] = value
+	}
+	return normalized
+}
+
+// setCookieNames Set-Cookie 中的 cookie 名称（排序去重）
+func setCookieNames(values []string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, v := range values {
+		name, _, _ := strings.Cut(v, "=")
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HeaderFingerprint 从规范化的响应头中取出参与指纹的部分
+// 值转小写，CSP 中的 nonce 和哈希替换为占位符
+func HeaderFingerprint(headers map[string]string) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	fp := make(map[string]string)
+	for _, name := range headerFingerprintNames {
+		value, ok := headers[name]
+		if !ok {
+			continue
+		}
+		value = strings.ToLower(value)
+		if name == "content-security-policy" {
+			value = cspTokenPattern.ReplaceAllString(value, "'$1'")
+		}
+		fp[name] = value
+	}
+	if len(fp) == 0 {
+		return nil
+	}
+	return fp
+}
+
+// HeaderFingerprintHash 响应头指纹的 64 位哈希，没有可用的头时为 0
+func HeaderFingerprintHash(fp map[string]string) uint64 {
+	if len(fp) == 0 {
+		return 0
+	}
+
+	names := make([]string, 0, len(fp))
+	for name := range fp {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name)
+		b.WriteString(": ")
+		b.WriteString(fp[name])
+		b.WriteByte('\n')
+	}
+	return hash64ForRule(b.String())
+}
+
+// FormatHeaderHash 报告中使用的响应头指纹（16 位十六进制），没有指纹时为空
+func FormatHeaderHash(hash uint64) string {
+	if hash == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%016x", hash)
+}
+
+// setHeaderFeatures 把抓取结果的响应头指纹写入页面特征
+func setHeaderFeatures(features *PageFeatures, fr *FetchResult) {
+	if features == nil {
+		return
+	}
+	features.HeaderSet = HeaderFingerprint(fr.Headers)
+	features.HeaderHash = fr.HeaderHash
+}
@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeHeaders(t *testing.T) {
+	header := http.Header{
+		"Server":       {"nginx/1.24.0"},
+		"Date":         {"Mon, 01 Jan 2024 00:00:00 GMT"},
+		"X-Request-Id": {"abc"},
+		"Set-Cookie":   {"PHPSESSID=1; path=/", "lang=zh", "PHPSESSID=2"},
+		"Vary":         {"Accept-Encoding", " Origin "},
+		"X-Empty":      {"  "},
+		"X-Long":       {strings.Repeat("a", maxHeaderValueLength+10)},
+	}
+	got := NormalizeHeaders(header)
+	want := map[string]string{
+		"server":     "nginx/1.24.0",
+		"set-cookie": "PHPSESSID, lang",
+		"vary":       "Accept-Encoding, Origin",
+		"x-long":     strings.Repeat("a", maxHeaderValueLength),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NormalizeHeaders = %v，期望 %v", got, want)
+	}
+	if NormalizeHeaders(nil) != nil {
+		t.Error("没有响应头时应返回 nil")
+	}
+}
+
+func TestHeaderFingerprint(t *testing.T) {
+	headers := map[string]string{
+		"server":                  "NGINX",
+		"content-type":            "text/html",
+		"content-security-policy": "script-src 'nonce-r4nd0m' 'sha256-abc=' 'self'",
+	}
+	got := HeaderFingerprint(headers)
+	want := map[string]string{
+		"server":                  "nginx",
+		"content-security-policy": "script-src 'nonce' 'sha256' 'self'",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("HeaderFingerprint = %v，期望 %v", got, want)
+	}
+	if HeaderFingerprint(map[string]string{"content-type": "text/html"}) != nil {
+		t.Error("没有参与指纹的头时应返回 nil")
+	}
+}
+
+func TestHeaderFingerprintHash(t *testing.T) {
+	a := HeaderFingerprint(NormalizeHeaders(http.Header{
+		"Server":                  {"nginx"},
+		"Content-Security-Policy": {"script-src 'nonce-aaa'"},
+		"Date":                    {"Mon, 01 Jan 2024 00:00:00 GMT"},
+	}))
+	b := HeaderFingerprint(NormalizeHeaders(http.Header{
+		"Server":                  {"nginx"},
+		"Content-Security-Policy": {"script-src 'nonce-bbb'"},
+		"Date":                    {"Tue, 02 Jan 2024 00:00:00 GMT"},
+	}))
+	c := HeaderFingerprint(NormalizeHeaders(http.Header{"Server": {"Apache"}}))
+
+	if HeaderFingerprintHash(a) != HeaderFingerprintHash(b) {
+		t.Error("只有易变头和 CSP nonce 不同的响应头指纹应相同")
+	}
+	if HeaderFingerprintHash(a) == HeaderFingerprintHash(c) {
+		t.Error("不同后端的响应头指纹应不同")
+	}
+	if HeaderFingerprintHash(nil) != 0 || FormatHeaderHash(0) != "" {
+		t.Error("没有指纹时哈希为 0，格式化为空")
+	}
+	if s := FormatHeaderHash(0xabc); s != "0000000000000abc" {
+		t.Errorf("FormatHeaderHash = %q", s)
+	}
+}
+
+func TestSimHeaders(t *testing.T) {
+	a := &PageFeatures{HeaderSet: map[string]string{"server": "nginx", "x-powered-by": "php", "vary": "origin"}}
+	b := &PageFeatures{HeaderSet: map[string]string{"server": "nginx", "x-powered-by": "asp.net"}}
+	// 名称并集 3 个，名称和值都相同的 1 个
+	if got := simHeaders(a, b); got < 0.333 || got > 0.334 {
+		t.Errorf("simHeaders = %v，期望 1/3", got)
+	}
+	same := &PageFeatures{HeaderSet: map[string]string{"server": "x"}, HeaderHash: 7}
+	if got := simHeaders(same, &PageFeatures{HeaderSet: map[string]string{"server": "x"}, HeaderHash: 7}); got != 1 {
+		t.Errorf("指纹相同时 simHeaders = %v，期望 1", got)
+	}
+	if headersAvailable(a, &PageFeatures{}) {
+		t.Error("任意一方没有响应头时该维度不可用")
+	}
+}
+
+func TestDefaultHeaderWeight(t *testing.T) {
+	// 响应头默认不参与总相似度加权，E1 默认不按响应头分组
+	if w := DefaultThresholds().Weights.Header; w != 0 {
+		t.Errorf("默认 header 权重 = %v，期望 0", w)
+	}
+	rules, err := LoadRules("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range rules {
+		if r.ID == "E1" && r.GroupBy != RuleGroupNone {
+			t.Errorf("E1 group_by = %q，期望 none", r.GroupBy)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 输入解析相关常量
+const (
+	maxInputLineSize = 16 * 1024 * 1024 // JSON Lines 单行最大长度（httpx 带响应体时单行很长）
+)
+
+// httpxBulkyFields httpx 输出中体积大、对去重没有意义的字段，不作为元数据保留
+var httpxBulkyFields = []string{"body", "header", "raw_header", "request", "response", "hash", "knowledgebase"}
+
+// inputEntry 解析输入得到的一个 URL 及其元数据
+type inputEntry struct {
+	URL  string
+	Meta map[string]interface{}
+}
+
+// detectInputFormat 识别输入格式：先按扩展名，识别不了（标准输入、无扩展名）时按内容
+func detectInputFormat(input string, data []byte) string {
+	switch strings.ToLower(filepath.Ext(input)) {
+	case ".txt":
+		return InputFormatText
+	case ".jsonl", ".ndjson", ".json":
+		return InputFormatJSONL
+	case ".csv":
+		return InputFormatCSV
+	case ".xml":
+		return InputFormatNmap
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case isJSONInput(trimmed):
+		return InputFormatJSONL
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return InputFormatNmap
+	default:
+		return InputFormatText
+	}
+}
+
+// isJSONInput 内容是否为 JSON 数组或 JSON Lines
+// 只看开头的 [ 或 { 不够（[::1]:8080 这样的 IPv6 地址也以 [ 开头），要能解析成功才算：
+// 数组要整体是合法 JSON，JSON Lines 看第一行是不是 JSON 对象
+func isJSONInput(trimmed []byte) bool {
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		return json.Valid(trimmed)
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		line := trimmed
+		if i := bytes.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i]
+		}
+		var obj map[string]interface{}
+		return json.Unmarshal(bytes.TrimSpace(line), &obj) == nil
+	}
+	return false
+}
+
+// parseInput 按格式解析输入
+func parseInput(data []byte, format string, cfg InputConfig) ([]inputEntry, error) {
+	switch strings.ToLower(format) {
+	case InputFormatText:
+		return parseTextInput(data)
+	case InputFormatJSONL:
+		return parseJSONInput(data, cfg.URLField, nil)
+	case InputFormatHttpx:
+		return parseJSONInput(data, cfg.URLField, httpxBulkyFields)
+	case InputFormatCSV:
+		return parseCSVInput(data, cfg.CSVColumn)
+	case InputFormatNmap:
+		return parseNmapInput(data)
+	default:
+		return nil, fmt.Errorf("不支持的输入格式: %s", format)
+	}
+}
+
+// parseTextInput 每行一个 URL，忽略空行和以 # 开头的注释
+func parseTextInput(data []byte) ([]inputEntry, error) {
+	var entries []inputEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxInputLineSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, inputEntry{URL: line})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取输入时出错: %w", err)
+	}
+	return entries, nil
+}
+
+// parseJSONInput 解析 JSON Lines（也接受 JSON 对象数组）
+// URL 取 urlField 字段，为空时依次尝试 url、host、input（兼容 httpx、subfinder 的输出）；
+// 其余字段作为元数据，drop 中的字段丢弃
+func parseJSONInput(data []byte, urlField string, drop []string) ([]inputEntry, error) {
+	var objects []map[string]interface{}
+
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("[")) {
+		if err := json.Unmarshal(trimmed, &objects); err != nil {
+			return nil, fmt.Errorf("解析 JSON 数组失败: %w", err)
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), maxInputLineSize)
+		lineNo := 0
+		for scanner.Scan() {
+			lineNo++
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 || bytes.HasPrefix(line, []byte("#")) {
+				continue
+			}
+			var obj map[string]interface{}
+			if err := json.Unmarshal(line, &obj); err != nil {
+				return nil, fmt.Errorf("第 %d 行不是 JSON 对象: %w", lineNo, err)
+			}
+			objects = append(objects, obj)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("读取输入时出错: %w", err)
+		}
+	}
+
+	fields := []string{"url", "host", "input"}
+	if urlField != "" {
+		fields = []string{urlField}
+	}
+
+	var entries []inputEntry
+	for _, obj := range objects {
+		field, rawURL := "", ""
+		for _, f := range fields {
+			if v, ok := obj[f].(string); ok && strings.TrimSpace(v) != "" {
+				field, rawURL = f, strings.TrimSpace(v)
+				break
+			}
+		}
+		if rawURL == "" {
+			continue
+		}
+
+		delete(obj, field)
+		for _, f := range drop {
+			delete(obj, f)
+		}
+		entry := inputEntry{URL: rawURL}
+		if len(obj) > 0 {
+			entry.Meta = obj
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// parseCSVInput 解析 CSV，第一行为表头
+// column 为列名（不区分大小写）或从 1 开始的序号，为空时依次尝试 url、host 列，都没有时用第一列；
+// 其余列按表头作为元数据
+func parseCSVInput(data []byte, column string) ([]inputEntry, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取 CSV 表头失败: %w", err)
+	}
+	for i := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(header[i], "\ufeff"))
+	}
+
+	urlCol := -1
+	if column != "" {
+		if n, err := strconv.Atoi(column); err == nil {
+			if n < 1 || n > len(header) {
+				return nil, fmt.Errorf("CSV 列序号超出范围: %d（共 %d 列）", n, len(header))
+			}
+			urlCol = n - 1
+		} else if urlCol = csvColumnIndex(header, column); urlCol < 0 {
+			return nil, fmt.Errorf("CSV 中没有列 %q", column)
+		}
+	} else {
+		urlCol = csvColumnIndex(header, "url")
+		if urlCol < 0 {
+			urlCol = csvColumnIndex(header, "host")
+		}
+		if urlCol < 0 {
+			urlCol = 0
+		}
+	}
+
+	var entries []inputEntry
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取 CSV 失败: %w", err)
+		}
+		if urlCol >= len(record) {
+			continue
+		}
+		rawURL := strings.TrimSpace(record[urlCol])
+		if rawURL == "" || strings.HasPrefix(rawURL, "#") {
+			continue
+		}
+
+		entry := inputEntry{URL: rawURL}
+		for i, value := range record {
+			if i == urlCol || i >= len(header) || header[i] == "" {
+				continue
+			}
+			if entry.Meta == nil {
+				entry.Meta = make(map[string]interface{})
+			}
+			entry.Meta[header[i]] = value
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// csvColumnIndex 按列名查找列，不区分大小写，找不到返回 -1
+func csvColumnIndex(header []string, name string) int {
+	for i, h := range header {
+		if strings.EqualFold(h, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// nmapRun nmap -oX 输出中用到的部分
+type nmapRun struct {
+	Hosts []struct {
+		Addresses []struct {
+			Addr     string `xml:"addr,attr"`
+			AddrType string `xml:"addrtype,attr"`
+		} `xml:"address"`
+		Hostnames []struct {
+			Name string `xml:"name,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"hostnames>hostname"`
+		Ports []struct {
+			Protocol string `xml:"protocol,attr"`
+			PortID   int    `xml:"portid,attr"`
+			State    struct {
+				State string `xml:"state,attr"`
+			} `xml:"state"`
+			Service *struct {
+				Name    string `xml:"name,attr"`
+				Product string `xml:"product,attr"`
+				Version string `xml:"version,attr"`
+				Tunnel  string `xml:"tunnel,attr"`
+			} `xml:"service"`
+		} `xml:"ports>port"`
+	} `xml:"host"`
+}
+
+// parseNmapInput 解析 nmap -oX 输出，每个开放的 Web 端口生成一个 URL
+// 有服务识别结果时只保留 http 类服务（ssl 隧道或 https 服务用 https），没有时保留所有开放的 TCP 端口；
+// host 优先使用扫描时指定的主机名，其次 IP，输出中的 ip、port、service 等作为元数据
+func parseNmapInput(data []byte) ([]inputEntry, error) {
+	var run nmapRun
+	if err := xml.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("解析 nmap XML 失败: %w", err)
+	}
+
+	var entries []inputEntry
+	for _, host := range run.Hosts {
+		ip := ""
+		for _, addr := range host.Addresses {
+			if addr.AddrType == "ipv4" || addr.AddrType == "ipv6" {
+				ip = addr.Addr
+				break
+			}
+		}
+		// 只用扫描时指定的主机名（type="user"），反向解析得到的 PTR 记录通常不对应站点
+		hostname, ptr := "", ""
+		for _, hn := range host.Hostnames {
+			switch hn.Type {
+			case "user":
+				hostname = hn.Name
+			case "PTR":
+				ptr = hn.Name
+			}
+		}
+		target := hostname
+		if target == "" {
+			target = ip
+		}
+		if target == "" {
+			continue
+		}
+		if strings.Contains(target, ":") {
+			target = "[" + target + "]"
+		}
+
+		for _, port := range host.Ports {
+			if port.State.State != "open" || (port.Protocol != "" && port.Protocol != "tcp") {
+				continue
+			}
+
+			scheme := "http"
+			meta := map[string]interface{}{
+				"ip":   ip,
+				"port": port.PortID,
+			}
+			if hostname != "" {
+				meta["hostname"] = hostname
+			}
+			if ptr != "" {
+				meta["ptr"] = ptr
+			}
+			if svc := port.Service; svc != nil && svc.Name != "" {
+				name := strings.ToLower(svc.Name)
+				if !strings.Contains(name, "http") {
+					continue
+				}
+				if svc.Tunnel == "ssl" || strings.Contains(name, "https") {
+					scheme = "https"
+				}
+				meta["service"] = svc.Name
+				if svc.Product != "" {
+					meta["product"] = svc.Product
+				}
+				if svc.Version != "" {
+					meta["version"] = svc.Version
+				}
+			} else if port.PortID == 443 || port.PortID == 8443 {
+				scheme = "https"
+			}
+
+			entries = append(entries, inputEntry{
+				URL:  fmt.Sprintf("%s://%s:%d", scheme, target, port.PortID),
+				Meta: meta,
+			})
+		}
+	}
+	return entries, nil
+}
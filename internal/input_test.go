@@ -0,0 +1,208 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDetectInputFormat(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		data  string
+		want  string
+	}{
+		{"扩展名 jsonl", "a.jsonl", "example.com", InputFormatJSONL},
+		{"扩展名 xml", "scan.xml", "", InputFormatNmap},
+		{"JSON Lines", "-", "{\"url\":\"https://a.com\"}\n{\"url\":\"https://b.com\"}", InputFormatJSONL},
+		{"JSON 数组", "-", " [{\"url\":\"https://a.com\"}]", InputFormatJSONL},
+		{"IPv6 地址不是 JSON", "-", "[::1]:8080\n[2001:db8::1]", InputFormatText},
+		{"花括号开头但不是 JSON", "-", "{a.com}\nb.com", InputFormatText},
+		{"nmap XML", "-", "<?xml version=\"1.0\"?><nmaprun></nmaprun>", InputFormatNmap},
+		{"普通文本", "-", "https://a.com\nb.com", InputFormatText},
+	}
+	for _, tt := range tests {
+		if got := detectInputFormat(tt.input, []byte(tt.data)); got != tt.want {
+			t.Errorf("%s: detectInputFormat = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsInputFile(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "targets")
+	if err := os.WriteFile(existing, []byte("a.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input string
+		want  bool
+	}{
+		{existing, true},
+		{dir, false},
+		{"missing.txt", true},
+		{"https://example.com/sitemap.xml", false},
+		{"http://example.com/list.txt", false},
+		{"example.com", false},
+		{"a.com,b.com", false},
+	}
+	for _, tt := range tests {
+		if got := isInputFile(tt.input); got != tt.want {
+			t.Errorf("isInputFile(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestLoadURLsSchemeURLWithFileExtension(t *testing.T) {
+	items, err := LoadURLs("https://example.com/sitemap.xml", InputConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].NormalizedURL != "https://example.com/sitemap.xml" {
+		t.Errorf("带 scheme 的 URL 应按 URL 处理: %+v", items)
+	}
+}
+
+func TestParseJSONInput(t *testing.T) {
+	data := `{"url":"https://a.com","title":"A"}
+
+# 注释
+{"host":"b.com","port":8080}
+{"input":"c.com"}
+{"other":"x"}
+`
+	entries, err := parseJSONInput([]byte(data), "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []inputEntry{
+		{URL: "https://a.com", Meta: map[string]interface{}{"title": "A"}},
+		{URL: "b.com", Meta: map[string]interface{}{"port": float64(8080)}},
+		{URL: "c.com"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("parseJSONInput = %+v, want %+v", entries, want)
+	}
+
+	// 指定字段
+	entries, err = parseJSONInput([]byte(`[{"url":"https://a.com","target":"https://t.com"}]`), "target", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].URL != "https://t.com" || entries[0].Meta["url"] != "https://a.com" {
+		t.Errorf("指定 URL 字段: %+v", entries)
+	}
+
+	if _, err := parseJSONInput([]byte("{\"url\":\"a.com\"}\nnot json\n"), "", nil); err == nil {
+		t.Error("非 JSON 行应该报错")
+	}
+}
+
+func TestParseHttpxInput(t *testing.T) {
+	data := `{"url":"https://a.com","status_code":200,"title":"A","body":"<html>...</html>","hash":{"body_md5":"x"},"raw_header":"HTTP/1.1 200 OK"}`
+	entries, err := parseInput([]byte(data), InputFormatHttpx, InputConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []inputEntry{{URL: "https://a.com", Meta: map[string]interface{}{"status_code": float64(200), "title": "A"}}}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("httpx 输入 = %+v, want %+v", entries, want)
+	}
+}
+
+func TestParseCSVInput(t *testing.T) {
+	data := "\ufeffName, URL ,Port\nA, https://a.com ,443\nB,,80\n# 注释,#x,1\nC,c.com\n"
+
+	entries, err := parseCSVInput([]byte(data), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []inputEntry{
+		{URL: "https://a.com", Meta: map[string]interface{}{"Name": "A", "Port": "443"}},
+		{URL: "c.com", Meta: map[string]interface{}{"Name": "C"}},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("parseCSVInput = %+v, want %+v", entries, want)
+	}
+
+	// 按序号指定列
+	entries, err = parseCSVInput([]byte("host,ip\na.com,1.1.1.1\n"), "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].URL != "1.1.1.1" || entries[0].Meta["host"] != "a.com" {
+		t.Errorf("按序号指定列: %+v", entries)
+	}
+
+	// 没有 url/host 列时用第一列
+	entries, err = parseCSVInput([]byte("target,note\na.com,x\n"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].URL != "a.com" {
+		t.Errorf("默认第一列: %+v", entries)
+	}
+
+	for _, column := range []string{"3", "missing"} {
+		if _, err := parseCSVInput([]byte("host,ip\na.com,1.1.1.1\n"), column); err == nil {
+			t.Errorf("列 %q 不存在时应该报错", column)
+		}
+	}
+}
+
+func TestParseNmapInput(t *testing.T) {
+	data := `<?xml version="1.0"?>
+<nmaprun>
+  <host>
+    <address addr="10.0.0.1" addrtype="ipv4"/>
+    <address addr="00:11:22:33:44:55" addrtype="mac"/>
+    <hostnames>
+      <hostname name="www.example.com" type="user"/>
+      <hostname name="host-1.isp.net" type="PTR"/>
+    </hostnames>
+    <ports>
+      <port protocol="tcp" portid="80"><state state="open"/><service name="http" product="nginx" version="1.25"/></port>
+      <port protocol="tcp" portid="8443"><state state="open"/><service name="http" tunnel="ssl"/></port>
+      <port protocol="tcp" portid="22"><state state="open"/><service name="ssh"/></port>
+      <port protocol="tcp" portid="81"><state state="closed"/><service name="http"/></port>
+      <port protocol="udp" portid="53"><state state="open"/></port>
+    </ports>
+  </host>
+  <host>
+    <address addr="2001:db8::1" addrtype="ipv6"/>
+    <hostnames><hostname name="ptr.example.net" type="PTR"/></hostnames>
+    <ports>
+      <port protocol="tcp" portid="443"><state state="open"/></port>
+    </ports>
+  </host>
+</nmaprun>`
+
+	entries, err := parseNmapInput([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var urls []string
+	for _, e := range entries {
+		urls = append(urls, e.URL)
+	}
+	want := []string{"http://www.example.com:80", "https://www.example.com:8443", "https://[2001:db8::1]:443"}
+	if !reflect.DeepEqual(urls, want) {
+		t.Fatalf("nmap URL = %v, want %v", urls, want)
+	}
+
+	meta := entries[0].Meta
+	if meta["ip"] != "10.0.0.1" || meta["port"] != 80 || meta["hostname"] != "www.example.com" ||
+		meta["ptr"] != "host-1.isp.net" || meta["service"] != "http" || meta["product"] != "nginx" || meta["version"] != "1.25" {
+		t.Errorf("nmap 元数据 = %+v", meta)
+	}
+	if entries[2].Meta["ptr"] != "ptr.example.net" || entries[2].Meta["hostname"] != nil {
+		t.Errorf("只有 PTR 时应该用 IP: %+v", entries[2].Meta)
+	}
+
+	if _, err := parseNmapInput([]byte("<nmaprun><host>")); err == nil {
+		t.Error("XML 不完整时应该报错")
+	}
+}
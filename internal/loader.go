@@ -1,39 +1,67 @@
 package internal
 
 import (
-	"bufio"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
+// 输入格式
+const (
+	InputFormatAuto  = ""      // 按扩展名或内容自动识别
+	InputFormatText  = "txt"   // 每行一个 URL
+	InputFormatJSONL = "jsonl" // JSON Lines（或 JSON 数组），每个对象一个 URL，其余字段作为元数据
+	InputFormatCSV   = "csv"   // CSV，第一行为表头
+	InputFormatNmap  = "nmap"  // nmap -oX 输出
+	InputFormatHttpx = "httpx" // httpx -json 输出
+)
+
+// InputConfig URL 输入配置
+type InputConfig struct {
+	Format    string // 输入格式，为空时自动识别
+	URLField  string // JSON Lines 中 URL 所在的字段，为空时依次尝试 url、host、input
+	CSVColumn string // CSV 中 URL 所在的列（列名或从 1 开始的序号），为空时依次尝试 url、host 列，都没有时用第一列
+
+	Ports      []int  // 没有写端口的主机、IP 段和 CIDR 按这些端口展开，为空时使用协议默认端口
+	SchemeMode string // 没有写 scheme 的输入如何探测协议：both（默认）、https-first、http-first
+
+	Canonical CanonicalConfig // URL 规范化配置
+}
+
+// stdinInput 表示从标准输入读取
+const stdinInput = "-"
+
 // LoadURLs 加载 URL 列表
-// 如果输入以 .txt 结尾，视为文件路径，按行读取
-// 否则视为逗号分隔的 URL 字符串
-func LoadURLs(input string) ([]URLItem, error) {
-	var rawURLs []string
-
-	if strings.HasSuffix(input, ".txt") {
-		// 从文件读取
-		file, err := os.Open(input)
-		if err != nil {
-			return nil, fmt.Errorf("无法打开文件 %s: %w", input, err)
-		}
-		defer file.Close()
-
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			line := strings.TrimSpace(scanner.Text())
-			// 忽略空行和以 # 开头的注释
-			if line == "" || strings.HasPrefix(line, "#") {
-				continue
+// input 为 "-" 时从标准输入读取；是已存在的文件，或不带 scheme 且以 .txt/.jsonl/.json/.csv/.xml 结尾时按文件读取，
+// 否则视为逗号分隔的 URL 字符串。未指定格式时按扩展名识别，识别不了的按内容识别
+func LoadURLs(input string, cfg InputConfig) ([]URLItem, error) {
+	var entries []inputEntry
+
+	if input == stdinInput || isInputFile(input) {
+		var data []byte
+		var err error
+		if input == stdinInput {
+			data, err = io.ReadAll(os.Stdin)
+			if err != nil {
+				return nil, fmt.Errorf("读取标准输入时出错: %w", err)
+			}
+		} else {
+			data, err = os.ReadFile(input)
+			if err != nil {
+				return nil, fmt.Errorf("无法打开文件 %s: %w", input, err)
 			}
-			rawURLs = append(rawURLs, line)
 		}
 
-		if err := scanner.Err(); err != nil {
-			return nil, fmt.Errorf("读取文件时出错: %w", err)
+		format := cfg.Format
+		if format == InputFormatAuto {
+			format = detectInputFormat(input, data)
+		}
+		entries, err = parseInput(data, format, cfg)
+		if err != nil {
+			return nil, fmt.Errorf("解析输入失败 (%s): %w", format, err)
 		}
 	} else {
 		// 逗号分隔
@@ -41,38 +69,78 @@ func LoadURLs(input string) ([]URLItem, error) {
 		for _, part := range parts {
 			part = strings.TrimSpace(part)
 			if part != "" {
-				rawURLs = append(rawURLs, part)
+				entries = append(entries, inputEntry{URL: part})
 			}
 		}
 	}
 
-	// 规范化 URL
-	items := make([]URLItem, 0, len(rawURLs))
-	for i, rawURL := range rawURLs {
-		normalized, err := normalizeURL(rawURL)
-		if err != nil {
-			// 如果规范化失败，仍然保留原始 URL，但记录错误
-			normalized = rawURL
+	// 没有 scheme 的输入展开为候选地址（主机、端口、IP 段、CIDR），协议之后由 ProbeSchemes 探测；
+	// 有 scheme 的直接规范化
+	items := make([]URLItem, 0, len(entries))
+	for _, entry := range entries {
+		targets := []string{entry.URL}
+		needsScheme := !strings.Contains(entry.URL, "://")
+		if needsScheme {
+			expanded, err := expandTarget(entry.URL, cfg.Ports)
+			if err != nil {
+				return nil, fmt.Errorf("展开 %s 失败: %w", entry.URL, err)
+			}
+			targets = expanded
+		}
+
+		// 协议还没确定，先保留端口，探测后再按实际协议去掉默认端口
+		canon := cfg.Canonical
+		if needsScheme {
+			canon.KeepDefaultPort = true
 		}
+		for _, target := range targets {
+			normalized, err := normalizeURL(target, canon)
+			if err != nil {
+				// 如果规范化失败，仍然保留原始 URL，但记录错误
+				normalized = target
+			}
 
-		items = append(items, URLItem{
-			ID:            i + 1,
-			RawURL:        rawURL,
-			NormalizedURL: normalized,
-		})
+			items = append(items, URLItem{
+				RawURL:        entry.URL,
+				NormalizedURL: normalized,
+				Meta:          entry.Meta,
+				Source:        URLSourceSeed,
+				needsScheme:   needsScheme,
+			})
+		}
 	}
 
+	for i := range items {
+		items[i].ID = i + 1
+	}
 	return items, nil
 }
 
-// normalizeURL 规范化 URL
-func normalizeURL(raw string) (string, error) {
+// isInputFile 判断输入是否为文件路径
+// 已存在的文件直接按文件读取；不存在时只有不带 scheme 的输入才按扩展名判断，
+// 避免 https://example.com/sitemap.xml 这样的 URL 被当成文件
+func isInputFile(input string) bool {
+	if info, err := os.Stat(input); err == nil {
+		return info.Mode().IsRegular()
+	}
+	if strings.Contains(input, "://") {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(input)) {
+	case ".txt", ".jsonl", ".ndjson", ".json", ".csv", ".xml":
+		return true
+	}
+	return false
+}
+
+// normalizeURL 规范化 URL，规范化步骤见 canonicalizeURL
+func normalizeURL(raw string, cfg CanonicalConfig) (string, error) {
 	raw = strings.TrimSpace(raw)
 	if raw == "" {
 		return "", fmt.Errorf("URL 为空")
 	}
 
-	// 如果没有 scheme，默认添加 http://
+	// 如果没有 scheme，默认添加 http://（展开的候选地址之后由 ProbeSchemes 探测实际协议）
 	if !strings.Contains(raw, "://") {
 		raw = "http://" + raw
 	}
@@ -83,10 +151,9 @@ func normalizeURL(raw string) (string, error) {
 		return raw, err
 	}
 
-	// 标准化 host（转小写）
-	u.Host = strings.ToLower(u.Host)
+	canonicalizeURL(u, cfg)
 
-	// 重组 URL（去掉多余斜杠等）
+	// 重组 URL
 	normalized := u.String()
 
 	return normalized, nil
@@ -0,0 +1,199 @@
+package internal
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// LSH 分段相关常量
+// 鸽巢原理：64 位指纹切成 b 段，汉明距离 < b 的两个指纹至少有一段完全相同，
+// 所以默认分段数取 "预筛选最大距离 + 1"，保证预筛选能通过的页面对一定会成为候选对
+// （默认阈值下 SimHash 9 段、每段约 7 bit；pHash 16 段、每段 4 bit）
+const (
+	ImagePHashQuickSlack = 5    // 图片预筛选比 ImagePHashMaxDist 宽松的距离
+	MaxLSHBands          = 64   // 最多 64 段（每段 1 bit）
+	MaxLSHBucketSize     = 1000 // 单个分段桶的成员上限，超过时只拿第一个成员和其他成员比较（空文本、TextSimHash 为 0 等页面会落进同一个桶）
+)
+
+// LSHIndex 基于分段（banding）的局部敏感哈希索引
+// 每个页面的指纹被切成若干段，每段作为一个哈希表 key，
+// 任意一段相同的两个页面成为候选对，再交给 quickSimHashCheck / IsDuplicate 详细比较
+type LSHIndex struct {
+	simHashBands int
+	pHashBands   int
+	crossHost    bool             // 为 true 时 key 不包含 host，不同 host 的页面也能成为候选对
+	tables       map[string][]int // band key -> 页面下标（升序）
+	keys         [][]string       // 页面下标 -> 所在的 band key
+}
+
+// NewLSHIndex 创建 LSH 索引
+// bands <= 0 时按阈值 t 中的预筛选距离自动选择分段数；否则 SimHash 和 pHash 都使用指定的分段数
+func NewLSHIndex(bands int, crossHost bool, t Thresholds) *LSHIndex {
+	simHashBands := clampBands(t.QuickSimHashMaxDist + 1)
+	pHashBands := clampBands(t.ImagePHashMaxDist + ImagePHashQuickSlack + 1)
+	if bands > 0 {
+		bands = clampBands(bands)
+		simHashBands, pHashBands = bands, bands
+	}
+
+	return &LSHIndex{
+		simHashBands: simHashBands,
+		pHashBands:   pHashBands,
+		crossHost:    crossHost,
+		tables:       make(map[string][]int),
+	}
+}
+
+// clampBands 把分段数限制在 [1, MaxLSHBands] 内
+func clampBands(bands int) int {
+	if bands < 1 {
+		return 1
+	}
+	if bands > MaxLSHBands {
+		return MaxLSHBands
+	}
+	return bands
+}
+
+// SimHashBands 返回 SimHash 分段数
+// 汉明距离 < 该值的页面对保证被召回，成员超过 MaxLSHBucketSize 的桶除外（见 CappedBuckets）
+func (idx *LSHIndex) SimHashBands() int {
+	return idx.simHashBands
+}
+
+// PHashBands 返回 pHash 分段数
+// 汉明距离 < 该值的图片对保证被召回，成员超过 MaxLSHBucketSize 的桶除外（见 CappedBuckets）
+func (idx *LSHIndex) PHashBands() int {
+	return idx.pHashBands
+}
+
+// Add 把页面加入索引，pos 为页面在调用方切片中的下标
+func (idx *LSHIndex) Add(pos int, page *PageWithFeatures) {
+	if page.Features == nil {
+		return
+	}
+	for pos >= len(idx.keys) {
+		idx.keys = append(idx.keys, nil)
+	}
+	keys := idx.bandKeys(page)
+	idx.keys[pos] = keys
+	for _, key := range keys {
+		members := idx.tables[key]
+		if n := len(members); n > 0 && members[n-1] == pos {
+			continue // 同一页面的两段 key 相同（例如二进制只有一个 key）
+		}
+		idx.tables[key] = append(members, pos)
+	}
+}
+
+// ForEachCandidate 按下标升序逐个产出候选对 (i, j)，i < j，每个候选对只产出一次
+// 按页面逐个收集候选，不一次性生成所有候选对，内存只和单个页面的候选数有关；
+// 成员超过 MaxLSHBucketSize 的桶只产出第一个成员和其他成员组成的候选对
+func (idx *LSHIndex) ForEachCandidate(fn func(i, j int)) {
+	seen := make(map[int]struct{})
+	var candidates []int
+
+	for i, keys := range idx.keys {
+		for _, key := range keys {
+			members := idx.tables[key]
+			if len(members) > MaxLSHBucketSize && members[0] != i {
+				continue
+			}
+			// members 升序，只取下标大于 i 的
+			start := sort.SearchInts(members, i+1)
+			for _, j := range members[start:] {
+				if _, ok := seen[j]; ok {
+					continue
+				}
+				seen[j] = struct{}{}
+				candidates = append(candidates, j)
+			}
+		}
+
+		sort.Ints(candidates)
+		for _, j := range candidates {
+			fn(i, j)
+		}
+		clear(seen)
+		candidates = candidates[:0]
+	}
+}
+
+// CappedBuckets 成员超过 MaxLSHBucketSize 的桶数
+// 这些桶只比较了第一个成员和其他成员，其余成员之间的页面对没有比较，召回保证不成立
+func (idx *LSHIndex) CappedBuckets() int {
+	capped := 0
+	for _, members := range idx.tables {
+		if len(members) > MaxLSHBucketSize {
+			capped++
+		}
+	}
+	return capped
+}
+
+// bandKeys 生成页面在各个分段表中的 key
+// 根据内容类型使用不同的指纹
+func (idx *LSHIndex) bandKeys(page *PageWithFeatures) []string {
+	scope := ""
+	if !idx.crossHost {
+		scope = bucketScope(page)
+	}
+	category := page.Features.Category
+
+	switch category {
+	case ContentCategoryHTML, ContentCategoryText:
+		// HTML 和文本类：SimHash 分段
+		return splitBands(scope, category, page.Features.TextSimHash, idx.simHashBands)
+
+	case ContentCategoryImage:
+		// 图片：pHash 分段
+		return splitBands(scope, category, page.Features.PHash, idx.pHashBands)
+
+	default:
+		// 二进制：必须完全匹配，直接用大小 + MD5 指纹作为唯一的 key
+		return []string{fmt.Sprintf("%s|%s|%d|%x", scope, category, page.Features.TextLength, page.Features.TextSimHash)}
+	}
+}
+
+// splitBands 把 64 位指纹切成 bands 段，生成每段的 key
+// 64 不能整除时前面几段多 1 bit
+func splitBands(scope string, category ContentCategory, hash uint64, bands int) []string {
+	keys := make([]string, 0, bands)
+	width := 64 / bands
+	extra := 64 % bands
+	shift := 0
+
+	for b := 0; b < bands; b++ {
+		w := width
+		if b < extra {
+			w++
+		}
+		var mask uint64 = (1 << uint(w)) - 1
+		if w == 64 {
+			mask = ^uint64(0)
+		}
+		value := (hash >> uint(shift)) & mask
+		keys = append(keys, fmt.Sprintf("%s|%s|%d|%x", scope, category, b, value))
+		shift += w
+	}
+
+	return keys
+}
+
+// bucketScope 返回页面的分桶范围（host），非跨 host 模式下只有同一范围内的页面才会成为候选对
+// 虚拟主机扫描时按实际连接的 IP 分桶，同一 IP 上的各虚拟主机和默认站点互相比较
+func bucketScope(page *PageWithFeatures) string {
+	u, err := url.Parse(page.FinalURL)
+	if err != nil {
+		u, _ = url.Parse(page.NormalizedURL)
+	}
+	if u == nil {
+		return ""
+	}
+	if page.TargetIP != "" && strings.EqualFold(u.Hostname(), page.VHost) {
+		return targetHost(u, page.TargetIP)
+	}
+	return u.Host
+}
@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// lshTestPage 构造只有 SimHash 的 HTML 页面
+func lshTestPage(id int, host string, hash uint64) *PageWithFeatures {
+	return &PageWithFeatures{
+		FetchResult: FetchResult{
+			URLItem:  URLItem{ID: id, NormalizedURL: fmt.Sprintf("http://%s/%d", host, id)},
+			FinalURL: fmt.Sprintf("http://%s/%d", host, id),
+		},
+		Features: &PageFeatures{Category: ContentCategoryHTML, TextSimHash: hash, TextLength: 100},
+	}
+}
+
+// collectCandidates 收集索引产出的所有候选对
+func collectCandidates(idx *LSHIndex) [][2]int {
+	var pairs [][2]int
+	idx.ForEachCandidate(func(i, j int) {
+		pairs = append(pairs, [2]int{i, j})
+	})
+	return pairs
+}
+
+func TestSplitBands(t *testing.T) {
+	keys := splitBands("h", ContentCategoryHTML, 0xFFFFFFFFFFFFFFFF, 9)
+	if len(keys) != 9 {
+		t.Fatalf("分段数 = %d, want 9", len(keys))
+	}
+	// 64 = 9*7 + 1，第一段 8 bit，其余 7 bit
+	if keys[0] != "h|html|0|ff" || keys[1] != "h|html|1|7f" {
+		t.Errorf("分段 key = %q, %q", keys[0], keys[1])
+	}
+
+	keys = splitBands("h", ContentCategoryHTML, 0x123456789ABCDEF0, 1)
+	if len(keys) != 1 || keys[0] != "h|html|0|123456789abcdef0" {
+		t.Errorf("单段 key = %q", keys)
+	}
+}
+
+func TestLSHRecallWithinBands(t *testing.T) {
+	thresholds := DefaultThresholds()
+	idx := NewLSHIndex(0, false, thresholds)
+	bands := idx.SimHashBands()
+
+	// 距离 bands-1 的页面对必须成为候选对（每段各翻转至多 1 bit）
+	base := uint64(0x0F0F0F0F0F0F0F0F)
+	flipped := base
+	for b := 0; b < bands-1; b++ {
+		flipped ^= 1 << uint(b*(64/bands))
+	}
+	if d := hammingDistance64(base, flipped); d != bands-1 {
+		t.Fatalf("构造的距离 = %d, want %d", d, bands-1)
+	}
+
+	idx.Add(0, lshTestPage(1, "a.com", base))
+	idx.Add(1, lshTestPage(2, "a.com", flipped))
+	if got := collectCandidates(idx); !reflect.DeepEqual(got, [][2]int{{0, 1}}) {
+		t.Errorf("候选对 = %v, want [[0 1]]", got)
+	}
+}
+
+func TestLSHHostScope(t *testing.T) {
+	thresholds := DefaultThresholds()
+	for _, crossHost := range []bool{false, true} {
+		idx := NewLSHIndex(0, crossHost, thresholds)
+		idx.Add(0, lshTestPage(1, "a.com", 42))
+		idx.Add(1, lshTestPage(2, "b.com", 42))
+		got := len(collectCandidates(idx))
+		want := 0
+		if crossHost {
+			want = 1
+		}
+		if got != want {
+			t.Errorf("crossHost=%v 候选对数 = %d, want %d", crossHost, got, want)
+		}
+	}
+}
+
+func TestForEachCandidateUniqueAndOrdered(t *testing.T) {
+	idx := NewLSHIndex(0, false, DefaultThresholds())
+	// 完全相同的指纹在每一段都相同，每个候选对也只能产出一次
+	for i := 0; i < 5; i++ {
+		idx.Add(i, lshTestPage(i+1, "a.com", 7))
+	}
+	idx.Add(5, lshTestPage(6, "a.com", ^uint64(7)))
+
+	want := [][2]int{{0, 1}, {0, 2}, {0, 3}, {0, 4}, {1, 2}, {1, 3}, {1, 4}, {2, 3}, {2, 4}, {3, 4}}
+	if got := collectCandidates(idx); !reflect.DeepEqual(got, want) {
+		t.Errorf("候选对 = %v, want %v", got, want)
+	}
+}
+
+func TestForEachCandidateOversizedBucket(t *testing.T) {
+	idx := NewLSHIndex(1, false, DefaultThresholds())
+	n := MaxLSHBucketSize + 10
+	for i := 0; i < n; i++ {
+		idx.Add(i, lshTestPage(i+1, "a.com", 0))
+	}
+
+	pairs := collectCandidates(idx)
+	if len(pairs) != n-1 {
+		t.Fatalf("超大桶候选对数 = %d, want %d", len(pairs), n-1)
+	}
+	for _, p := range pairs {
+		if p[0] != 0 {
+			t.Fatalf("超大桶只应和第一个成员比较，得到 %v", p)
+		}
+	}
+	if got := idx.CappedBuckets(); got != 1 {
+		t.Errorf("CappedBuckets = %d, want 1", got)
+	}
+
+	// 没有超过上限时不计数
+	small := NewLSHIndex(1, false, DefaultThresholds())
+	for i := 0; i < MaxLSHBucketSize; i++ {
+		small.Add(i, lshTestPage(i+1, "a.com", 0))
+	}
+	if got := small.CappedBuckets(); got != 0 {
+		t.Errorf("未超过上限的 CappedBuckets = %d, want 0", got)
+	}
+}
+
+func TestClusterReportsCappedBuckets(t *testing.T) {
+	var pages []*PageWithFeatures
+	for i := 0; i < MaxLSHBucketSize+1; i++ {
+		pages = append(pages, lshTestPage(i+1, "a.com", 0))
+	}
+	pages = append(pages, lshTestPage(MaxLSHBucketSize+2, "b.com", 0))
+
+	_, stats := Cluster(pages, Options{LSHBands: 1, Thresholds: DefaultThresholds()})
+	if stats.CappedBuckets != 1 {
+		t.Errorf("ClusterStats.CappedBuckets = %d, want 1", stats.CappedBuckets)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -13,7 +14,9 @@ func BuildReport(
 	fetchResults []FetchResult,
 	pagesWithFeatures []*PageWithFeatures,
 	contentClusters map[string]*ClusterGroup,
+	clusterStats ClusterStats,
 	ruleAssignments map[int]RuleAssignment,
+	ruleAnnotations map[int][]RuleAnnotation,
 	opts Options,
 ) *FullReport {
 	report := &FullReport{
@@ -24,7 +27,14 @@ func BuildReport(
 			EligibleHTMLURLs:    0,
 			EligibleNonHTMLURLs: 0,
 			TotalClusters:       len(contentClusters),
+			CandidatePairs:      clusterStats.CandidatePairs,
+			SimHashBands:        clusterStats.SimHashBands,
+			PHashBands:          clusterStats.PHashBands,
+			CappedLSHBuckets:    clusterStats.CappedBuckets,
 			SimThreshold:        opts.SimThreshold,
+			CrossHost:           opts.CrossHost,
+			NoRender:            opts.NoRender,
+			Thresholds:          opts.Thresholds,
 			GeneratedAt:         time.Now().Format(time.RFC3339),
 		},
 	}
@@ -57,6 +67,7 @@ func BuildReport(
 			ClusterID:    clusterID,
 			CanonicalURL: canonicalURL,
 			MemberIDs:    memberIDs,
+			Origins:      clusterOrigins(cluster.Members),
 		})
 	}
 
@@ -67,16 +78,49 @@ func BuildReport(
 	// 构建 URL 报告
 	for _, fetchResult := range fetchResults {
 		urlReport := URLReport{
-			ID:            fetchResult.ID,
-			URL:           fetchResult.RawURL,
-			NormalizedURL: fetchResult.NormalizedURL,
-			FinalURL:      fetchResult.FinalURL,
-			RedirectChain: fetchResult.RedirectChain,
-			StatusCode:    fetchResult.StatusCode,
-			ContentLength: fetchResult.ContentLength,
-			ContentType:   fetchResult.ContentType,
-			Error:         fetchResult.Error,
-			Title:         fetchResult.Title,
+			ID:               fetchResult.ID,
+			URL:              fetchResult.RawURL,
+			NormalizedURL:    fetchResult.NormalizedURL,
+			FinalURL:         fetchResult.FinalURL,
+			TargetIP:         fetchResult.TargetIP,
+			VHost:            fetchResult.VHost,
+			Source:           fetchResult.Source,
+			InputMeta:        fetchResult.Meta,
+			InputAliases:     fetchResult.Aliases,
+			RedirectChain:    fetchResult.RedirectChain,
+			StatusCode:       fetchResult.StatusCode,
+			ContentLength:    fetchResult.ContentLength,
+			ContentType:      fetchResult.ContentType,
+			ContentCategory:  string(fetchResult.ContentCategory),
+			DeclaredCategory: string(fetchResult.DeclaredCategory),
+			DetectedCategory: string(fetchResult.DetectedCategory),
+			Charset:          fetchResult.Charset,
+			CharsetSource:    fetchResult.CharsetSource,
+			Error:            fetchResult.Error,
+			ErrorClass:       string(fetchResult.ErrorClass),
+			Attempts:         fetchResult.Attempts,
+			RenderAttempts:   fetchResult.RenderAttempts,
+			RenderError:      fetchResult.RenderError,
+			Title:            fetchResult.Title,
+		}
+		urlReport.HeaderFingerprint = FormatHeaderHash(fetchResult.HeaderHash)
+		urlReport.RedirectHops = fetchResult.RedirectHops
+		if fav := fetchResult.Favicon; fav != nil {
+			urlReport.FaviconURL = fav.URL
+			urlReport.FaviconMMH3 = fmt.Sprintf("%d", fav.MMH3)
+			urlReport.FaviconMD5 = fav.MD5
+			urlReport.FaviconPHash = FormatFaviconPHash(fav.PHash)
+		}
+		if cert := fetchResult.Cert; cert != nil {
+			urlReport.CertSHA256 = cert.SHA256
+			urlReport.CertSubjectCN = cert.SubjectCN
+			urlReport.CertSANs = cert.SANs
+			urlReport.CertIssuer = cert.Issuer
+			urlReport.CertNotBefore = cert.NotBefore.UTC().Format(time.RFC3339)
+			urlReport.CertNotAfter = cert.NotAfter.UTC().Format(time.RFC3339)
+		}
+		if opts.OutputHeaders {
+			urlReport.Headers = fetchResult.Headers
 		}
 
 		assigned := false
@@ -95,29 +139,48 @@ func BuildReport(
 			clusterID, inCluster := clusterByPageID[fetchResult.ID]
 			if inCluster {
 				urlReport.ClusterID = clusterID
+				urlReport.ClusterSource = ClusterSourceContent
 				canonicalID := canonicalByCluster[clusterID]
 				urlReport.IsCanonical = (fetchResult.ID == canonicalID)
 
 				// 计算与 canonical 的相似度
 				if cluster := contentClusters[clusterID]; cluster != nil && cluster.Canonical != nil && cluster.Canonical.Features != nil {
-					contentSim, structSim, visualSim, behaviorSim, totalSim := CalculateSimilarities(
+					// 判定分支：优先用与 canonical 直接比较的结果，
+					// 通过其他成员间接合并进来的，用聚类时记录的分支
+					if !urlReport.IsCanonical {
+						reason := MatchDuplicate(page.Features, cluster.Canonical.Features, opts.Thresholds)
+						if reason == DuplicateReasonNone {
+							reason = cluster.MatchReasons[fetchResult.ID]
+						}
+						urlReport.MatchReason = string(reason)
+					}
+
+					contentSim, structSim, visualSim, behaviorSim, headerSim, totalSim := CalculateSimilarities(
 						page.Features,
 						cluster.Canonical.Features,
+						opts.Thresholds,
 					)
 					urlReport.ContentSim = contentSim
 					urlReport.StructureSim = structSim
 					urlReport.VisualSim = visualSim
 					urlReport.BehaviorSim = behaviorSim
+					urlReport.HeaderSim = headerSim
 					urlReport.SimilarityToCanonical = totalSim
 				}
 				assigned = true
 			}
 		}
 
+		// 标注型规则（如同 favicon 的站点）不影响聚类，单独输出
+		urlReport.RuleAnnotations = ruleAnnotations[fetchResult.ID]
+
 		// 2) 如果没有内容聚类，则看规则聚类
 		if !assigned {
 			if ra, ok := ruleAssignments[fetchResult.ID]; ok {
 				urlReport.ClusterID = ra.ClusterID
+				urlReport.ClusterSource = ClusterSourceRule
+				urlReport.RuleID = ra.RuleID
+				urlReport.RulePriority = ra.Priority
 				urlReport.IsCanonical = ra.IsCanonical
 				// 相似度相关字段保持 0（这些是错误页/无内容页）
 				assigned = true
@@ -168,6 +231,15 @@ func WriteCSV(report *FullReport, filepath string) error {
 		"status_code", "content_length", "content_type", "error", "title",
 		"cluster_id", "is_canonical", "similarity_to_canonical",
 		"content_sim", "structure_sim", "visual_sim", "behavior_sim",
+		"cluster_source", "rule_id", "rule_priority", "match_reason",
+		"error_class", "attempts", "render_attempts", "render_error",
+		"charset", "charset_source",
+		"content_category", "declared_category", "detected_category",
+		"header_sim", "header_fingerprint",
+		"favicon_url", "favicon_mmh3", "favicon_md5", "favicon_phash",
+		"cert_sha256", "cert_subject_cn", "cert_sans", "cert_issuer", "cert_not_before", "cert_not_after",
+		"target_ip", "vhost", "input_meta", "source",
+		"rule_annotations",
 	}
 	if err := writer.Write(headers); err != nil {
 		return fmt.Errorf("写入表头失败: %w", err)
@@ -192,6 +264,36 @@ func WriteCSV(report *FullReport, filepath string) error {
 			fmt.Sprintf("%.4f", urlReport.StructureSim),
 			fmt.Sprintf("%.4f", urlReport.VisualSim),
 			fmt.Sprintf("%.4f", urlReport.BehaviorSim),
+			urlReport.ClusterSource,
+			urlReport.RuleID,
+			fmt.Sprintf("%d", urlReport.RulePriority),
+			urlReport.MatchReason,
+			urlReport.ErrorClass,
+			fmt.Sprintf("%d", urlReport.Attempts),
+			fmt.Sprintf("%d", urlReport.RenderAttempts),
+			urlReport.RenderError,
+			urlReport.Charset,
+			urlReport.CharsetSource,
+			urlReport.ContentCategory,
+			urlReport.DeclaredCategory,
+			urlReport.DetectedCategory,
+			fmt.Sprintf("%.4f", urlReport.HeaderSim),
+			urlReport.HeaderFingerprint,
+			urlReport.FaviconURL,
+			urlReport.FaviconMMH3,
+			urlReport.FaviconMD5,
+			urlReport.FaviconPHash,
+			urlReport.CertSHA256,
+			urlReport.CertSubjectCN,
+			strings.Join(urlReport.CertSANs, ";"),
+			urlReport.CertIssuer,
+			urlReport.CertNotBefore,
+			urlReport.CertNotAfter,
+			urlReport.TargetIP,
+			urlReport.VHost,
+			formatInputMeta(urlReport.InputMeta),
+			urlReport.Source,
+			formatRuleAnnotations(urlReport.RuleAnnotations),
 		}
 		if err := writer.Write(row); err != nil {
 			return fmt.Errorf("写入数据行失败: %w", err)
@@ -201,3 +303,23 @@ func WriteCSV(report *FullReport, filepath string) error {
 	return nil
 }
 
+// formatRuleAnnotations 规则标注在 CSV 中输出为 "规则ID:clusterID"，多个用 ; 分隔
+func formatRuleAnnotations(annotations []RuleAnnotation) string {
+	parts := make([]string, 0, len(annotations))
+	for _, a := range annotations {
+		parts = append(parts, a.RuleID+":"+a.ClusterID)
+	}
+	return strings.Join(parts, ";")
+}
+
+// formatInputMeta 输入元数据在 CSV 中输出为 JSON 对象，没有时为空
+func formatInputMeta(meta map[string]interface{}) string {
+	if len(meta) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
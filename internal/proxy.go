@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+)
+
+// ProxyPool 上游代理池
+// 多个代理时按轮询方式为每个 HTTP 请求和每个渲染页面选择代理
+type ProxyPool struct {
+	proxies []*url.URL
+	next    atomic.Uint64
+}
+
+// NewProxyPool 创建代理池，proxies 为空时返回 nil（直连）
+func NewProxyPool(proxies []string) (*ProxyPool, error) {
+	pool := &ProxyPool{}
+	for _, s := range proxies {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		u, err := ParseProxyURL(s)
+		if err != nil {
+			return nil, err
+		}
+		pool.proxies = append(pool.proxies, u)
+	}
+
+	if len(pool.proxies) == 0 {
+		return nil, nil
+	}
+	return pool, nil
+}
+
+// LoadProxyList 从文件加载代理列表，每行一个，# 开头为注释
+func LoadProxyList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开代理列表失败: %w", err)
+	}
+	defer f.Close()
+
+	var proxies []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		proxies = append(proxies, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取代理列表失败: %w", err)
+	}
+	return proxies, nil
+}
+
+// ParseProxyURL 解析代理地址
+// 支持 http://、https://、socks5://、socks5h://，可带 user:pass@；省略 scheme 时按 http 处理
+func ParseProxyURL(s string) (*url.URL, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "://") {
+		s = "http://" + s
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("无效的代理地址 %s: %w", s, err)
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("不支持的代理协议: %s（支持 http、https、socks5、socks5h）", u.Scheme)
+	}
+
+	if u.Hostname() == "" || u.Port() == "" {
+		return nil, fmt.Errorf("代理地址需要包含 host:port: %s", u.Redacted())
+	}
+	return u, nil
+}
+
+// Len 代理数量
+func (p *ProxyPool) Len() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.proxies)
+}
+
+// proxiesOrNil 返回所有代理，代理池为 nil 时返回 nil
+func (p *ProxyPool) proxiesOrNil() []*url.URL {
+	if p == nil {
+		return nil
+	}
+	return p.proxies
+}
+
+// Next 轮询取下一个代理，代理池为空时返回 nil
+func (p *ProxyPool) Next() *url.URL {
+	if p.Len() == 0 {
+		return nil
+	}
+	i := p.next.Add(1) - 1
+	return p.proxies[i%uint64(len(p.proxies))]
+}
+
+// String 脱敏后的代理列表，用于日志
+func (p *ProxyPool) String() string {
+	if p.Len() == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(p.proxies))
+	for _, u := range p.proxies {
+		parts = append(parts, u.Redacted())
+	}
+	return strings.Join(parts, ", ")
+}
+
+// HTTPProxyFunc 返回给 http.Transport 使用的代理选择函数
+// 每个请求轮询一个代理，net/http 原生支持 http、https、socks5 代理及其认证
+func (p *ProxyPool) HTTPProxyFunc() func(*http.Request) (*url.URL, error) {
+	if p.Len() == 0 {
+		return nil
+	}
+	return func(*http.Request) (*url.URL, error) {
+		return p.Next(), nil
+	}
+}
+
+// chromeProxyServer 转换为 Chrome 的 proxyServer 格式（不带认证信息）
+// Chrome 的 socks5 代理本身就在代理端解析域名，socks5h 等价于 socks5
+func chromeProxyServer(u *url.URL) string {
+	scheme := u.Scheme
+	if scheme == "socks5h" {
+		scheme = "socks5"
+	}
+	return scheme + "://" + u.Host
+}
+
+// newProxyTabContext 创建使用指定代理的 tab
+// 每个页面在独立的 BrowserContext 中打开，BrowserContext 级别的代理设置对远程 Chrome 同样有效，
+// 也便于在多个代理之间轮询
+func newProxyTabContext(browserCtx context.Context, proxy *url.URL) (context.Context, context.CancelFunc) {
+	return chromedp.NewContext(browserCtx, chromedp.WithNewBrowserContext(
+		func(p *target.CreateBrowserContextParams) *target.CreateBrowserContextParams {
+			return p.WithProxyServer(chromeProxyServer(proxy))
+		},
+	))
+}
+
+// proxyAuthAction 处理代理认证
+// Chrome 不支持在代理地址中携带认证信息，需要通过 Fetch 域拦截 407 挑战并提供凭据；
+// Chrome 不支持 SOCKS5 认证，此时返回 nil，由调用方提示
+func proxyAuthAction(proxy *url.URL) chromedp.Action {
+	if proxy == nil || proxy.User == nil || strings.HasPrefix(proxy.Scheme, "socks5") {
+		return nil
+	}
+
+	username := proxy.User.Username()
+	password, _ := proxy.User.Password()
+
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		c := chromedp.FromContext(ctx)
+		executorCtx := cdp.WithExecutor(ctx, c.Target)
+
+		chromedp.ListenTarget(ctx, func(ev interface{}) {
+			switch e := ev.(type) {
+			case *fetch.EventRequestPaused:
+				go func() {
+					_ = fetch.ContinueRequest(e.RequestID).Do(executorCtx)
+				}()
+			case *fetch.EventAuthRequired:
+				resp := &fetch.AuthChallengeResponse{
+					Response: fetch.AuthChallengeResponseResponseDefault,
+				}
+				if e.AuthChallenge != nil && e.AuthChallenge.Source == fetch.AuthChallengeSourceProxy {
+					resp = &fetch.AuthChallengeResponse{
+						Response: fetch.AuthChallengeResponseResponseProvideCredentials,
+						Username: username,
+						Password: password,
+					}
+				}
+				go func() {
+					_ = fetch.ContinueWithAuth(e.RequestID, resp).Do(executorCtx)
+				}()
+			}
+		})
+
+		return fetch.Enable().WithHandleAuthRequests(true).Do(ctx)
+	})
+}
@@ -2,34 +2,111 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/chromedp/cdproto/page"
 	"github.com/chromedp/chromedp"
 )
 
+// RendererConfig 渲染器配置
+type RendererConfig struct {
+	PerPageTimeout time.Duration
+	MaxWorkers     int             // 最大并发渲染数
+	RemoteURL      string          // 远程 Chrome 的 DevTools 地址（ws://... 或 http://host:port），为空时启动本地浏览器
+	ExecPath       string          // 本地 Chrome 可执行文件路径，为空时自动查找
+	ExtraFlags     []string        // 额外的 Chrome 启动参数，格式 name 或 name=value（仅本地浏览器）
+	RecycleAfter   int             // 每个浏览器实例渲染多少页后重启，0 表示不按页数回收
+	MaxRSSMB       int             // 本地 Chrome 进程树 RSS 上限（MB），超过后重启，0 表示不检查
+	Proxies        *ProxyPool      // 上游代理，nil 表示直连
+	Request        *RequestProfile // UA、请求头和 cookie，与 HTTP 抓取一致
+	Scheduler      *HostScheduler  // 与 Fetcher 共用的按 host 调度器
+
+	HostResolverRules string // Chrome 的 --host-resolver-rules，虚拟主机扫描时把主机名映射到目标 IP（仅本地浏览器）
+}
+
+// 渲染错误
+var (
+	errBrowserUnavailable = errors.New("浏览器不可用")
+	errParseFeatures      = errors.New("解析特征失败")
+)
+
+// RenderInfo 渲染时浏览器中的页面信息
+type RenderInfo struct {
+	Title      string        // 渲染后的标题
+	FinalURL   string        // 浏览器最终停留的 URL
+	ClientHops []RedirectHop // 浏览器中发生的跳转（meta refresh、JS 跳转等），不含起点
+}
+
+// 浏览器健康检查和回收相关常量
+const (
+	browserHealthTimeout = 5 * time.Second // 健康检查超时
+	rssCheckInterval     = 20              // 每渲染多少页检查一次 RSS
+)
+
 // Renderer headless Chrome 渲染器
+// 浏览器崩溃或无响应时自动重启，并按页数/RSS 定期回收，避免长时间运行后内存泄漏
 type Renderer struct {
-	allocCtx       context.Context
-	allocCancel    context.CancelFunc
-	browserCtx     context.Context
-	browserCancel  context.CancelFunc
+	parentCtx      context.Context
+	cfg            RendererConfig
 	perPageTimeout time.Duration
 	workerPool     chan struct{} // 限制并发渲染数量
+
+	mu      sync.RWMutex // 渲染持读锁，重启持写锁（等待进行中的渲染结束）
+	session *browserSession
+
+	restarts atomic.Int64 // 崩溃/无响应导致的重启次数
+	recycles atomic.Int64 // 按页数/RSS 回收的次数
+}
+
+// browserSession 一个浏览器实例
+type browserSession struct {
+	allocCancel   context.CancelFunc
+	browserCtx    context.Context
+	browserCancel context.CancelFunc
+	pages         atomic.Int64 // 本实例已渲染的页数
 }
 
 // NewRenderer 创建新的渲染器
-func NewRenderer(parentCtx context.Context, perPageTimeout time.Duration, maxWorkers int) (*Renderer, error) {
-	opts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.Flag("headless", true),
-		chromedp.Flag("no-sandbox", true),
-		chromedp.Flag("disable-gpu", true),
-		chromedp.Flag("disable-dev-shm-usage", true),
-		chromedp.Flag("ignore-certificate-errors", true),
-		chromedp.Flag("ignore-ssl-errors", true),
-	)
+// 配置了 RemoteURL 时连接已启动的（远程/sidecar）Chrome，否则启动本地浏览器
+func NewRenderer(parentCtx context.Context, cfg RendererConfig) (*Renderer, error) {
+	for _, p := range cfg.Proxies.proxiesOrNil() {
+		if p.User != nil && strings.HasPrefix(p.Scheme, "socks5") {
+			GetLogger().Warn("Chrome 不支持 SOCKS5 代理认证，渲染可能失败: %s", p.Redacted())
+		}
+	}
 
-	allocCtx, allocCancel := chromedp.NewExecAllocator(parentCtx, opts...)
+	session, err := startBrowser(parentCtx, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	maxWorkers := cfg.MaxWorkers
+	if maxWorkers <= 0 {
+		maxWorkers = 1
+	}
+	workerPool := make(chan struct{}, maxWorkers)
+
+	return &Renderer{
+		parentCtx:      parentCtx,
+		cfg:            cfg,
+		perPageTimeout: cfg.PerPageTimeout,
+		workerPool:     workerPool,
+		session:        session,
+	}, nil
+}
+
+// startBrowser 启动（或连接）一个浏览器实例
+func startBrowser(parentCtx context.Context, cfg RendererConfig) (*browserSession, error) {
+	allocCtx, allocCancel, err := newAllocator(parentCtx, cfg)
+	if err != nil {
+		return nil, err
+	}
 
 	browserCtx, browserCancel := chromedp.NewContext(allocCtx, chromedp.WithLogf(func(format string, v ...interface{}) {
 		_ = format
@@ -42,33 +119,296 @@ func NewRenderer(parentCtx context.Context, perPageTimeout time.Duration, maxWor
 		return nil, fmt.Errorf("启动浏览器失败: %w", err)
 	}
 
-	workerPool := make(chan struct{}, maxWorkers)
-
-	return &Renderer{
-		allocCtx:       allocCtx,
-		allocCancel:    allocCancel,
-		browserCtx:     browserCtx,
-		browserCancel:  browserCancel,
-		perPageTimeout: perPageTimeout,
-		workerPool:     workerPool,
+	return &browserSession{
+		allocCancel:   allocCancel,
+		browserCtx:    browserCtx,
+		browserCancel: browserCancel,
 	}, nil
 }
 
+// close 关闭浏览器实例
+func (s *browserSession) close() {
+	if s.browserCancel != nil {
+		s.browserCancel()
+	}
+	if s.allocCancel != nil {
+		s.allocCancel()
+	}
+}
+
+// healthy 检查浏览器是否还能响应
+func (s *browserSession) healthy() bool {
+	if s.browserCtx.Err() != nil {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(s.browserCtx, browserHealthTimeout)
+	defer cancel()
+
+	var ok bool
+	if err := chromedp.Run(ctx, chromedp.Evaluate(`true`, &ok)); err != nil {
+		return false
+	}
+	return ok
+}
+
+// rssBytes 本地 Chrome 进程树的 RSS，远程浏览器或无法读取时返回 0
+func (s *browserSession) rssBytes() uint64 {
+	c := chromedp.FromContext(s.browserCtx)
+	if c == nil || c.Browser == nil {
+		return 0
+	}
+	proc := c.Browser.Process()
+	if proc == nil {
+		return 0
+	}
+	return processTreeRSS(proc.Pid)
+}
+
+// newAllocator 创建浏览器分配器
+// 远程模式用 RemoteAllocator 连接已有的 Chrome，本地模式用 ExecAllocator 启动 Chrome
+func newAllocator(parentCtx context.Context, cfg RendererConfig) (context.Context, context.CancelFunc, error) {
+	logger := GetLogger()
+
+	if cfg.RemoteURL != "" {
+		if cfg.ExecPath != "" || len(cfg.ExtraFlags) > 0 || cfg.HostResolverRules != "" {
+			logger.Warn("已指定远程 Chrome，忽略本地 Chrome 路径和启动参数（包括虚拟主机映射）")
+		}
+
+		u, err := url.Parse(cfg.RemoteURL)
+		if err != nil || u.Host == "" {
+			return nil, nil, fmt.Errorf("无效的远程 Chrome 地址: %s", cfg.RemoteURL)
+		}
+
+		// ws://host:port/devtools/browser/... 和 http://host:port 由 chromedp 自动解析；
+		// 带自定义 path 或 query 的 ws 地址（如 browserless 的 ?token=...）原样使用
+		var remoteOpts []chromedp.RemoteAllocatorOption
+		isWS := u.Scheme == "ws" || u.Scheme == "wss"
+		if isWS && !strings.Contains(u.Path, "/devtools/browser/") && (strings.Trim(u.Path, "/") != "" || u.RawQuery != "") {
+			remoteOpts = append(remoteOpts, chromedp.NoModifyURL)
+		}
+
+		logger.Info("连接远程 Chrome: %s", u.Redacted())
+		allocCtx, allocCancel := chromedp.NewRemoteAllocator(parentCtx, cfg.RemoteURL, remoteOpts...)
+		return allocCtx, allocCancel, nil
+	}
+
+	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.Flag("headless", true),
+		chromedp.Flag("no-sandbox", true),
+		chromedp.Flag("disable-gpu", true),
+		chromedp.Flag("disable-dev-shm-usage", true),
+		chromedp.Flag("ignore-certificate-errors", true),
+		chromedp.Flag("ignore-ssl-errors", true),
+	)
+
+	if cfg.ExecPath != "" {
+		opts = append(opts, chromedp.ExecPath(cfg.ExecPath))
+	}
+
+	if cfg.HostResolverRules != "" {
+		opts = append(opts, chromedp.Flag("host-resolver-rules", cfg.HostResolverRules))
+	}
+
+	for _, f := range cfg.ExtraFlags {
+		name, value, err := parseChromeFlag(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		opts = append(opts, chromedp.Flag(name, value))
+	}
+
+	allocCtx, allocCancel := chromedp.NewExecAllocator(parentCtx, opts...)
+	return allocCtx, allocCancel, nil
+}
+
+// parseChromeFlag 解析 Chrome 启动参数
+// 支持 name、--name、name=value；value 为 true/false 时按布尔处理
+func parseChromeFlag(s string) (string, interface{}, error) {
+	s = strings.TrimLeft(strings.TrimSpace(s), "-")
+	if s == "" {
+		return "", nil, fmt.Errorf("Chrome 启动参数为空")
+	}
+
+	name, value, hasValue := strings.Cut(s, "=")
+	if !hasValue {
+		return name, true, nil
+	}
+
+	switch strings.ToLower(value) {
+	case "true":
+		return name, true, nil
+	case "false":
+		return name, false, nil
+	default:
+		return name, value, nil
+	}
+}
+
 // Close 关闭渲染器
 func (r *Renderer) Close() {
-	if r.browserCancel != nil {
-		r.browserCancel()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.session != nil {
+		r.session.close()
+		r.session = nil
+	}
+}
+
+// SetHostResolverRules 更换虚拟主机映射，与当前映射不同时重启本地浏览器
+// Chrome 只在启动时读取 --host-resolver-rules，需要在没有进行中的渲染时调用
+func (r *Renderer) SetHostResolverRules(rules string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cfg.HostResolverRules == rules {
+		return nil
+	}
+	r.cfg.HostResolverRules = rules
+	if r.cfg.RemoteURL != "" {
+		return nil
 	}
-	if r.allocCancel != nil {
-		r.allocCancel()
+
+	if r.session != nil {
+		r.session.close()
+		r.session = nil
 	}
+	session, err := startBrowser(r.parentCtx, r.cfg)
+	if err != nil {
+		return fmt.Errorf("应用虚拟主机映射时重启浏览器失败: %w", err)
+	}
+	r.session = session
+	return nil
+}
+
+// Restarts 浏览器崩溃/无响应导致的重启次数
+func (r *Renderer) Restarts() int {
+	return int(r.restarts.Load())
 }
 
-// ExtractFeatures 提取页面特征，返回特征和渲染后的标题
-func (r *Renderer) ExtractFeatures(ctx context.Context, finalURL string) (*PageFeatures, string, error) {
+// Recycles 按页数/RSS 回收浏览器的次数
+func (r *Renderer) Recycles() int {
+	return int(r.recycles.Load())
+}
+
+// ExtractFeatures 提取页面特征，返回特征和浏览器中的标题、最终 URL、客户端跳转
+// connectURL 是按 host 限速用的实际连接地址（虚拟主机扫描时 host 为目标 IP，与 HTTP 抓取共用调度名额），为空时使用 finalURL。
+// 渲染失败且浏览器不健康时，重启浏览器并重试一次
+func (r *Renderer) ExtractFeatures(ctx context.Context, finalURL, connectURL string) (*PageFeatures, RenderInfo, error) {
+	if connectURL == "" {
+		connectURL = finalURL
+	}
+	// 先等待 host 的限速，再占用渲染并发名额
+	release, err := r.cfg.Scheduler.Acquire(ctx, connectURL)
+	if err != nil {
+		return nil, RenderInfo{}, err
+	}
+	defer release()
+
 	r.workerPool <- struct{}{}
 	defer func() { <-r.workerPool }()
 
+	features, info, session, err := r.renderOnce(ctx, finalURL)
+	if err == nil {
+		r.afterPage(session)
+		return features, info, nil
+	}
+	if ctx.Err() != nil {
+		return features, info, err
+	}
+
+	// 浏览器正常说明是页面本身的问题（超时、导航失败等），不重试
+	if session != nil && session.healthy() {
+		return features, info, err
+	}
+
+	logger := GetLogger()
+	logger.Warn("浏览器无响应，重启后重试: %s (%v)", finalURL, err)
+	if restartErr := r.restart(session, false); restartErr != nil {
+		return features, info, fmt.Errorf("%w；重启浏览器失败: %v", err, restartErr)
+	}
+
+	features, info, session, err = r.renderOnce(ctx, finalURL)
+	if err == nil {
+		r.afterPage(session)
+	}
+	return features, info, err
+}
+
+// renderOnce 在当前浏览器实例上渲染一次，返回使用的实例
+func (r *Renderer) renderOnce(ctx context.Context, finalURL string) (*PageFeatures, RenderInfo, *browserSession, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	session := r.session
+	if session == nil {
+		return nil, RenderInfo{}, nil, errBrowserUnavailable
+	}
+
+	features, info, err := r.render(ctx, session.browserCtx, finalURL)
+	return features, info, session, err
+}
+
+// afterPage 渲染成功后计数，达到页数或 RSS 上限时回收浏览器
+func (r *Renderer) afterPage(session *browserSession) {
+	n := session.pages.Add(1)
+
+	if r.cfg.RecycleAfter > 0 && n == int64(r.cfg.RecycleAfter) {
+		GetLogger().Info("浏览器已渲染 %d 页，回收重启", n)
+		_ = r.restart(session, true)
+		return
+	}
+
+	if r.cfg.MaxRSSMB > 0 && n%rssCheckInterval == 0 {
+		rss := session.rssBytes()
+		if rss > uint64(r.cfg.MaxRSSMB)*1024*1024 {
+			GetLogger().Info("浏览器 RSS %d MB 超过上限 %d MB，回收重启", rss/1024/1024, r.cfg.MaxRSSMB)
+			_ = r.restart(session, true)
+		}
+	}
+}
+
+// restart 关闭旧实例并启动新实例
+// 多个 worker 同时发现同一个实例失效时只重启一次
+func (r *Renderer) restart(old *browserSession, recycle bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.session != old {
+		// 已经被其他 worker 重启过
+		if r.session == nil {
+			return errBrowserUnavailable
+		}
+		return nil
+	}
+
+	if old != nil {
+		old.close()
+		r.session = nil
+	}
+
+	if r.parentCtx.Err() != nil {
+		return r.parentCtx.Err()
+	}
+
+	session, err := startBrowser(r.parentCtx, r.cfg)
+	if err != nil {
+		GetLogger().Error("重启浏览器失败: %v", err)
+		return err
+	}
+	r.session = session
+
+	if recycle {
+		r.recycles.Add(1)
+	} else {
+		r.restarts.Add(1)
+	}
+	return nil
+}
+
+// render 在指定浏览器上打开新 tab 提取页面特征
+func (r *Renderer) render(ctx context.Context, browserCtx context.Context, finalURL string) (*PageFeatures, RenderInfo, error) {
 	features := &PageFeatures{
 		Category:  ContentCategoryHTML, // HTML 页面
 		TagCount:  make(map[string]int),
@@ -78,14 +418,34 @@ func (r *Renderer) ExtractFeatures(ctx context.Context, finalURL string) (*PageF
 	pageCtx, cancel := context.WithTimeout(ctx, r.perPageTimeout)
 	defer cancel()
 
-	tabCtx, cancelTab := chromedp.NewContext(r.browserCtx)
+	// 配置了代理时每个页面轮询一个代理
+	var tabCtx context.Context
+	var cancelTab context.CancelFunc
+	var actions []chromedp.Action
+	if proxy := r.cfg.Proxies.Next(); proxy != nil {
+		tabCtx, cancelTab = newProxyTabContext(browserCtx, proxy)
+		if auth := proxyAuthAction(proxy); auth != nil {
+			actions = append(actions, auth)
+		}
+	} else {
+		tabCtx, cancelTab = chromedp.NewContext(browserCtx)
+	}
 	defer cancelTab()
 
+	// 在导航前开始监听，记录主 frame 的每次跳转
+	nav := &navTracker{pending: make(map[string]page.ClientNavigationReason)}
+	chromedp.ListenTarget(tabCtx, nav.handle)
+
+	if setup := r.cfg.Request.ChromeAction(); setup != nil {
+		actions = append(actions, setup)
+	}
+
 	var htmlContent string
 	var domStatsJSON string
 	var perfTimingJSON string
 	var screenshotBuf []byte
 	var title string
+	var location string
 
 	// 监听 pageCtx 取消，同步取消 tabCtx
 	done := make(chan struct{})
@@ -98,28 +458,124 @@ func (r *Renderer) ExtractFeatures(ctx context.Context, finalURL string) (*PageF
 		}
 	}()
 
-	err := chromedp.Run(tabCtx,
+	actions = append(actions,
 		chromedp.Navigate(finalURL),
 		chromedp.WaitReady("body"),
 		waitForPageStable(),
 		chromedp.Title(&title),
+		chromedp.Location(&location),
 		chromedp.OuterHTML("html", &htmlContent),
 		chromedp.Evaluate(getDOMStatsJS(), &domStatsJSON),
 		chromedp.Evaluate(getPerfTimingJS(), &perfTimingJSON),
 		chromedp.CaptureScreenshot(&screenshotBuf),
 	)
+	err := chromedp.Run(tabCtx, actions...)
 
 	<-done
 
 	if err != nil {
-		return features, "", fmt.Errorf("渲染页面失败: %w", err)
+		// 页面超时时 tabCtx 是被主动取消的，返回超时错误便于重试判定
+		if errors.Is(pageCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			return features, RenderInfo{}, fmt.Errorf("渲染页面超时: %w", pageCtx.Err())
+		}
+		return features, RenderInfo{}, fmt.Errorf("渲染页面失败: %w", err)
+	}
+
+	info := RenderInfo{
+		Title:      title,
+		FinalURL:   location,
+		ClientHops: nav.clientHops(finalURL, location),
 	}
 
 	if err := parseFeatures(features, htmlContent, domStatsJSON, perfTimingJSON, screenshotBuf); err != nil {
-		return features, title, fmt.Errorf("解析特征失败: %w", err)
+		return features, info, fmt.Errorf("%w: %v", errParseFeatures, err)
+	}
+
+	return features, info, nil
+}
+
+// navTracker 记录标签页主 frame 的导航，还原浏览器中的 meta refresh、JS 跳转
+type navTracker struct {
+	mu      sync.Mutex
+	pending map[string]page.ClientNavigationReason // frame ID -> 尚未提交的客户端跳转原因
+	navs    []RedirectHop                          // 主 frame 依次提交的导航
+}
+
+// handle 处理标签页事件（在 chromedp 的事件 goroutine 中调用）
+func (t *navTracker) handle(ev interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch ev := ev.(type) {
+	case *page.EventFrameRequestedNavigation:
+		t.pending[string(ev.FrameID)] = ev.Reason
+	case *page.EventFrameNavigated:
+		// 只关心主 frame，iframe 的导航不影响页面 URL
+		if ev.Frame == nil || ev.Frame.ParentID != "" {
+			return
+		}
+		hopType := HopTypeBrowser
+		if reason, ok := t.pending[string(ev.Frame.ID)]; ok {
+			hopType = clientHopType(reason)
+			delete(t.pending, string(ev.Frame.ID))
+		}
+		t.navs = append(t.navs, RedirectHop{URL: ev.Frame.URL, Type: hopType})
+	}
+}
+
+// clientHops 返回相对 startURL 在浏览器中发生的跳转
+// 第一次提交的导航就是 startURL 本身（浏览器可能补全末尾的 /），不算跳转；
+// 最终 URL 与最后一次导航不同时说明是同文档内的跳转（history.pushState、hash 变化），只能由 JS 触发
+func (t *navTracker) clientHops(startURL, finalURL string) []RedirectHop {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var hops []RedirectHop
+	last := startURL
+	for _, nav := range t.navs {
+		if nav.URL == "" || nav.URL == "about:blank" || sameURL(nav.URL, last) {
+			continue
+		}
+		hops = append(hops, nav)
+		last = nav.URL
+	}
+	if finalURL != "" && finalURL != "about:blank" && !sameURL(finalURL, last) {
+		hops = append(hops, RedirectHop{URL: finalURL, Type: HopTypeJS})
 	}
+	return hops
+}
 
-	return features, title, nil
+// clientHopType 把 Chrome 的客户端导航原因映射为 hop 类型
+func clientHopType(reason page.ClientNavigationReason) string {
+	switch reason {
+	case page.ClientNavigationReasonMetaTagRefresh:
+		return HopTypeMetaRefresh
+	case page.ClientNavigationReasonHTTPHeaderRefresh:
+		return HopTypeHeaderRefresh
+	case page.ClientNavigationReasonScriptInitiated:
+		return HopTypeJS
+	default:
+		return HopTypeBrowser
+	}
+}
+
+// sameURL 判断两个 URL 是否相同，忽略空 path 和 "/" 的区别
+func sameURL(a, b string) bool {
+	if a == b {
+		return true
+	}
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	if ua.Path == "" {
+		ua.Path = "/"
+	}
+	if ub.Path == "" {
+		ub.Path = "/"
+	}
+	return ua.String() == ub.String()
 }
 
 // getDOMStatsJS 返回用于获取 DOM 统计信息的 JS 代码
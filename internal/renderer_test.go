@@ -0,0 +1,147 @@
+package internal
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/page"
+)
+
+func TestParseChromeFlag(t *testing.T) {
+	tests := []struct {
+		in        string
+		wantName  string
+		wantValue interface{}
+		wantErr   bool
+	}{
+		{"disable-extensions", "disable-extensions", true, false},
+		{"--disable-extensions", "disable-extensions", true, false},
+		{"  --lang=zh-CN ", "lang", "zh-CN", false},
+		{"headless=false", "headless", false, false},
+		{"mute-audio=TRUE", "mute-audio", true, false},
+		{"proxy-bypass-list=a=b", "proxy-bypass-list", "a=b", false},
+		{"--", "", nil, true},
+		{"", "", nil, true},
+	}
+	for _, tt := range tests {
+		name, value, err := parseChromeFlag(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseChromeFlag(%q) err = %v，期望出错 %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if name != tt.wantName || value != tt.wantValue {
+			t.Errorf("parseChromeFlag(%q) = %q, %v，期望 %q, %v", tt.in, name, value, tt.wantName, tt.wantValue)
+		}
+	}
+}
+
+func TestNewAllocatorRemoteURL(t *testing.T) {
+	tests := []struct {
+		remote  string
+		wantErr bool
+	}{
+		{"ws://127.0.0.1:9222/devtools/browser/abc", false},
+		{"http://127.0.0.1:9222", false},
+		{"wss://chrome.example.com/?token=x", false},
+		{"127.0.0.1:9222", true},
+		{"://bad", true},
+	}
+	for _, tt := range tests {
+		// 远程分配器在创建浏览器之前不会连接，这里只检查地址校验
+		ctx, cancel, err := newAllocator(context.Background(), RendererConfig{RemoteURL: tt.remote})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("newAllocator(%q) err = %v，期望出错 %v", tt.remote, err, tt.wantErr)
+		}
+		if err == nil {
+			if ctx == nil {
+				t.Errorf("newAllocator(%q) 返回的 context 为空", tt.remote)
+			}
+			cancel()
+		}
+	}
+}
+
+func TestNewAllocatorInvalidFlag(t *testing.T) {
+	if _, _, err := newAllocator(context.Background(), RendererConfig{ExtraFlags: []string{"--"}}); err == nil {
+		t.Error("无效的 Chrome 启动参数应返回错误")
+	}
+}
+
+func TestClientHopType(t *testing.T) {
+	tests := map[page.ClientNavigationReason]string{
+		page.ClientNavigationReasonMetaTagRefresh:    HopTypeMetaRefresh,
+		page.ClientNavigationReasonHTTPHeaderRefresh: HopTypeHeaderRefresh,
+		page.ClientNavigationReasonScriptInitiated:   HopTypeJS,
+		page.ClientNavigationReasonFormSubmissionGet: HopTypeBrowser,
+		page.ClientNavigationReasonReload:            HopTypeBrowser,
+	}
+	for reason, want := range tests {
+		if got := clientHopType(reason); got != want {
+			t.Errorf("clientHopType(%s) = %s，期望 %s", reason, got, want)
+		}
+	}
+}
+
+func TestSameURL(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"https://a.com", "https://a.com/", true},
+		{"https://a.com/x", "https://a.com/x", true},
+		{"https://a.com/x", "https://a.com/x/", false},
+		{"https://a.com/?q=1", "https://a.com?q=1", true},
+		{"https://a.com/#a", "https://a.com/#b", false},
+		{"http://a.com/", "https://a.com/", false},
+	}
+	for _, tt := range tests {
+		if got := sameURL(tt.a, tt.b); got != tt.want {
+			t.Errorf("sameURL(%q, %q) = %v，期望 %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNavTrackerClientHops(t *testing.T) {
+	navigated := func(frameID, parentID, u string) *page.EventFrameNavigated {
+		return &page.EventFrameNavigated{Frame: &cdp.Frame{ID: cdp.FrameID(frameID), ParentID: cdp.FrameID(parentID), URL: u}}
+	}
+	requested := func(frameID string, reason page.ClientNavigationReason) *page.EventFrameRequestedNavigation {
+		return &page.EventFrameRequestedNavigation{FrameID: cdp.FrameID(frameID), Reason: reason}
+	}
+
+	nav := &navTracker{pending: make(map[string]page.ClientNavigationReason)}
+	events := []interface{}{
+		navigated("main", "", "https://a.com/"), // 起点本身
+		requested("main", page.ClientNavigationReasonMetaTagRefresh),
+		navigated("main", "", "https://a.com/welcome"),
+		navigated("ad", "main", "https://ads.example/frame"), // iframe 不计
+		requested("main", page.ClientNavigationReasonScriptInitiated),
+		navigated("main", "", "https://a.com/login"),
+		navigated("main", "", "https://a.com/sso"), // 没有客户端跳转原因
+	}
+	for _, ev := range events {
+		nav.handle(ev)
+	}
+
+	got := nav.clientHops("https://a.com", "https://a.com/sso#/home")
+	want := []RedirectHop{
+		{URL: "https://a.com/welcome", Type: HopTypeMetaRefresh},
+		{URL: "https://a.com/login", Type: HopTypeJS},
+		{URL: "https://a.com/sso", Type: HopTypeBrowser},
+		{URL: "https://a.com/sso#/home", Type: HopTypeJS}, // 同文档内的跳转
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("clientHops = %+v，期望 %+v", got, want)
+	}
+
+	empty := &navTracker{pending: make(map[string]page.ClientNavigationReason)}
+	empty.handle(navigated("main", "", "https://a.com/"))
+	if hops := empty.clientHops("https://a.com", "https://a.com/"); len(hops) != 0 {
+		t.Errorf("没有跳转时 clientHops = %+v", hops)
+	}
+}
@@ -0,0 +1,212 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+)
+
+// DefaultUAProfile 默认 UA 配置
+const DefaultUAProfile = "chrome-windows"
+
+// UAProfiles 内置 User-Agent 配置
+var UAProfiles = map[string]string{
+	"chrome-windows": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	"chrome-mac":     "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	"chrome-linux":   "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	"chrome-android": "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+	"firefox":        "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0",
+	"safari-mac":     "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15",
+	"safari-iphone":  "Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+	"googlebot":      "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+}
+
+// UAProfileNames 内置 UA 配置名（排序后）
+func UAProfileNames() []string {
+	names := make([]string, 0, len(UAProfiles))
+	for name := range UAProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RequestProfile 请求配置：User-Agent、自定义请求头和 cookie
+// HTTP 抓取和 Chrome 渲染使用同一份配置，保证两个阶段看到的内容一致
+type RequestProfile struct {
+	UserAgent string
+	Headers   http.Header // 不含 User-Agent
+	Cookies   []Cookie
+}
+
+// NewRequestProfile 根据配置构建请求配置
+// User-Agent 优先级：user_agent > headers 中的 User-Agent > ua_profile > 默认配置
+func NewRequestProfile(cfg RequestConfig) (*RequestProfile, error) {
+	profile := &RequestProfile{
+		Headers: make(http.Header),
+	}
+
+	for name, value := range cfg.Headers {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("请求头名称为空")
+		}
+		if strings.EqualFold(name, "Host") {
+			return nil, fmt.Errorf("不支持自定义 Host 请求头")
+		}
+		profile.Headers.Set(name, value)
+	}
+
+	uaName := cfg.UAProfile
+	if uaName == "" {
+		uaName = DefaultUAProfile
+	}
+	ua, ok := UAProfiles[uaName]
+	if !ok {
+		return nil, fmt.Errorf("未知的 UA 配置: %s（可选: %s）", uaName, strings.Join(UAProfileNames(), ", "))
+	}
+	if headerUA := profile.Headers.Get("User-Agent"); headerUA != "" {
+		ua = headerUA
+	}
+	if cfg.UserAgent != "" {
+		ua = cfg.UserAgent
+	}
+	profile.UserAgent = ua
+	profile.Headers.Del("User-Agent")
+
+	if cfg.CookieFile != "" {
+		cookies, err := LoadCookieFile(cfg.CookieFile)
+		if err != nil {
+			return nil, err
+		}
+		profile.Cookies = cookies
+	}
+
+	return profile, nil
+}
+
+// Apply 把 User-Agent、请求头和匹配的 cookie 写入 HTTP 请求
+// 重定向的新请求用 ApplyRedirect
+func (p *RequestProfile) Apply(req *http.Request) {
+	if p == nil {
+		return
+	}
+
+	for name, values := range p.Headers {
+		req.Header[name] = append([]string(nil), values...)
+	}
+	req.Header.Set("User-Agent", p.UserAgent)
+	p.applyCookies(req)
+}
+
+// ApplyRedirect 更新重定向的新请求：User-Agent 保留，cookie 按新 URL 重新匹配
+// 自定义请求头（可能带 Authorization、API key）只在与最初请求的 host 相同时重新写入，
+// 跳到其他 host 时去掉（Go 会把非敏感的请求头原样带过去），避免凭据泄露给跳转目标
+func (p *RequestProfile) ApplyRedirect(req, first *http.Request) {
+	// Go 默认会把上一跳的 Cookie 头原样带过去
+	req.Header.Del("Cookie")
+	if p == nil {
+		return
+	}
+
+	if strings.EqualFold(req.URL.Host, first.URL.Host) {
+		p.Apply(req)
+		return
+	}
+	for name := range p.Headers {
+		req.Header.Del(name)
+	}
+	req.Header.Set("User-Agent", p.UserAgent)
+	p.applyCookies(req)
+}
+
+// applyCookies 写入 cookie 文件中与请求 URL 匹配的 cookie
+func (p *RequestProfile) applyCookies(req *http.Request) {
+	if len(p.Cookies) == 0 {
+		return
+	}
+
+	// 请求头里手写的 Cookie 保留，cookie 文件中匹配的 cookie 追加在后面
+	now := time.Now()
+	for _, c := range p.Cookies {
+		if c.Matches(req.URL, now) {
+			req.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+		}
+	}
+}
+
+// ChromeAction 返回在 tab 中应用同一份配置的 action
+// 通过 CDP 覆盖 UA、设置额外请求头并写入 cookie，对本地和远程 Chrome 都有效
+func (p *RequestProfile) ChromeAction() chromedp.Action {
+	if p == nil {
+		return nil
+	}
+
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if err := network.Enable().Do(ctx); err != nil {
+			return err
+		}
+
+		if err := emulation.SetUserAgentOverride(p.UserAgent).Do(ctx); err != nil {
+			return fmt.Errorf("设置 User-Agent 失败: %w", err)
+		}
+
+		if len(p.Headers) > 0 {
+			headers := make(network.Headers, len(p.Headers))
+			for name, values := range p.Headers {
+				headers[name] = strings.Join(values, ", ")
+			}
+			if err := network.SetExtraHTTPHeaders(headers).Do(ctx); err != nil {
+				return fmt.Errorf("设置请求头失败: %w", err)
+			}
+		}
+
+		if len(p.Cookies) > 0 {
+			if err := network.SetCookies(chromeCookieParams(p.Cookies)).Do(ctx); err != nil {
+				return fmt.Errorf("设置 cookie 失败: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// chromeCookieParams 转换为 CDP 的 cookie 参数
+// host-only cookie 用 URL 指定，避免 Chrome 把它当成域 cookie 发给子域名
+func chromeCookieParams(cookies []Cookie) []*network.CookieParam {
+	params := make([]*network.CookieParam, 0, len(cookies))
+	for _, c := range cookies {
+		param := &network.CookieParam{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HTTPOnly: c.HTTPOnly,
+		}
+
+		if c.HostOnly {
+			scheme := "http"
+			if c.Secure {
+				scheme = "https"
+			}
+			param.URL = (&url.URL{Scheme: scheme, Host: c.Domain, Path: c.Path}).String()
+		} else {
+			param.Domain = "." + c.Domain
+		}
+
+		if !c.Expires.IsZero() {
+			expires := cdp.TimeSinceEpoch(c.Expires)
+			param.Expires = &expires
+		}
+		params = append(params, param)
+	}
+	return params
+}
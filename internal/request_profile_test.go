@@ -0,0 +1,92 @@
+package internal
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewRequestProfileUserAgent(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  RequestConfig
+		want string
+	}{
+		{"默认配置", RequestConfig{}, UAProfiles[DefaultUAProfile]},
+		{"UA 配置", RequestConfig{UAProfile: "googlebot"}, UAProfiles["googlebot"]},
+		{"请求头优先于 UA 配置", RequestConfig{UAProfile: "googlebot", Headers: map[string]string{"user-agent": "h"}}, "h"},
+		{"user_agent 优先", RequestConfig{UserAgent: "u", Headers: map[string]string{"User-Agent": "h"}}, "u"},
+	}
+	for _, tt := range tests {
+		p, err := NewRequestProfile(tt.cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if p.UserAgent != tt.want {
+			t.Errorf("%s: UserAgent = %q，期望 %q", tt.name, p.UserAgent, tt.want)
+		}
+		if p.Headers.Get("User-Agent") != "" {
+			t.Errorf("%s: Headers 中不应保留 User-Agent", tt.name)
+		}
+	}
+}
+
+func TestNewRequestProfileInvalid(t *testing.T) {
+	tests := map[string]RequestConfig{
+		"未知 UA 配置":     {UAProfile: "netscape"},
+		"自定义 Host":     {Headers: map[string]string{"host": "a.com"}},
+		"请求头名称为空":      {Headers: map[string]string{" ": "x"}},
+		"cookie 文件不存在": {CookieFile: filepath.Join(os.TempDir(), "websitesimilar-missing-cookies.txt")},
+	}
+	for name, cfg := range tests {
+		if _, err := NewRequestProfile(cfg); err == nil {
+			t.Errorf("%s: 应返回错误", name)
+		}
+	}
+}
+
+func TestRequestProfileApply(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+	data := ".example.com\tTRUE\t/\tFALSE\t0\tsid\tabc\nother.com\tFALSE\t/\tFALSE\t0\tx\ty\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewRequestProfile(RequestConfig{
+		Headers:    map[string]string{"X-Test": "1", "Cookie": "manual=1"},
+		UserAgent:  "ua",
+		CookieFile: path,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, _ := http.NewRequest("GET", "http://www.example.com/", nil)
+	p.Apply(req)
+	if req.Header.Get("User-Agent") != "ua" || req.Header.Get("X-Test") != "1" {
+		t.Errorf("请求头 = %v", req.Header)
+	}
+	// 手写的 Cookie 保留，cookie 文件中匹配的追加在后面，不匹配的不发送
+	if got := req.Header.Get("Cookie"); got != "manual=1; sid=abc" {
+		t.Errorf("Cookie = %q，期望 %q", got, "manual=1; sid=abc")
+	}
+
+	var nilProfile *RequestProfile
+	nilProfile.Apply(req)
+	if nilProfile.ChromeAction() != nil {
+		t.Error("nil 配置不应返回 action")
+	}
+}
+
+func TestChromeCookieParams(t *testing.T) {
+	params := chromeCookieParams([]Cookie{
+		{Name: "a", Domain: "example.com", Path: "/"},
+		{Name: "b", Domain: "example.com", HostOnly: true, Secure: true, Path: "/app"},
+	})
+	if params[0].Domain != ".example.com" || params[0].URL != "" {
+		t.Errorf("域 cookie = %+v", params[0])
+	}
+	if params[1].Domain != "" || params[1].URL != "https://example.com/app" {
+		t.Errorf("host-only cookie = %+v", params[1])
+	}
+}
@@ -0,0 +1,279 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrorClass 失败原因分类，用于重试判定和报告
+type ErrorClass string
+
+const (
+	ErrorClassNone          ErrorClass = ""
+	ErrorClassTimeout       ErrorClass = "timeout"        // 请求超时
+	ErrorClassCancelled     ErrorClass = "cancelled"      // 被取消
+	ErrorClassDNS           ErrorClass = "dns"            // 域名解析失败
+	ErrorClassConnRefused   ErrorClass = "conn_refused"   // 连接被拒绝
+	ErrorClassConnReset     ErrorClass = "conn_reset"     // 连接被重置或意外断开
+	ErrorClassTLS           ErrorClass = "tls"            // TLS 握手失败
+	ErrorClassRedirectLimit ErrorClass = "redirect_limit" // 重定向次数超限
+	ErrorClassRead          ErrorClass = "read"           // 读取响应体失败
+	ErrorClassHTTPStatus    ErrorClass = "http_status"    // 重试用尽后仍是可重试的状态码
+	ErrorClassRender        ErrorClass = "render"         // 渲染失败
+	ErrorClassRenderTimeout ErrorClass = "render_timeout" // 渲染超时
+	ErrorClassBrowser       ErrorClass = "browser"        // 浏览器不可用
+	ErrorClassParse         ErrorClass = "parse"          // 特征解析失败
+	ErrorClassOther         ErrorClass = "other"          // 其他错误
+)
+
+// errorClasses 所有可配置的错误分类
+var errorClasses = []ErrorClass{
+	ErrorClassTimeout, ErrorClassDNS, ErrorClassConnRefused, ErrorClassConnReset, ErrorClassTLS,
+	ErrorClassRedirectLimit, ErrorClassRead, ErrorClassRender, ErrorClassRenderTimeout,
+	ErrorClassBrowser, ErrorClassParse, ErrorClassOther,
+}
+
+// 重试默认值
+const (
+	DefaultRetryAttempts = 2 // 默认最多尝试 2 次（失败后重试 1 次）
+	DefaultRetryDelay    = "1s"
+	DefaultRetryMaxDelay = "10s"
+)
+
+// errTooManyRedirects 重定向次数超限
+var errTooManyRedirects = errors.New("重定向次数超过限制")
+
+// errPlainHTTPOnHTTPSPort 协议探测时明文请求发到了 HTTPS 端口
+var errPlainHTTPOnHTTPSPort = errors.New("明文请求发到了 HTTPS 端口")
+
+// RetryConfig 重试配置（配置文件 retry 段）
+type RetryConfig struct {
+	MaxAttempts int      `yaml:"max_attempts" json:"max_attempts"` // 最多尝试次数（含第一次），1 表示不重试
+	BaseDelay   string   `yaml:"base_delay" json:"base_delay"`     // 第一次重试前的等待时间，之后每次翻倍
+	MaxDelay    string   `yaml:"max_delay" json:"max_delay"`       // 最大等待时间
+	RetryStatus []int    `yaml:"retry_status" json:"retry_status"` // 可重试的状态码
+	RetryErrors []string `yaml:"retry_errors" json:"retry_errors"` // 可重试的错误分类
+}
+
+// DefaultRetryConfig 默认重试配置
+func DefaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts: DefaultRetryAttempts,
+		BaseDelay:   DefaultRetryDelay,
+		MaxDelay:    DefaultRetryMaxDelay,
+		RetryStatus: []int{429, 502, 503, 504},
+		RetryErrors: []string{
+			string(ErrorClassTimeout),
+			string(ErrorClassConnReset),
+			string(ErrorClassRead),
+			string(ErrorClassRender),
+			string(ErrorClassRenderTimeout),
+		},
+	}
+}
+
+// RetryPolicy 重试策略
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	status      map[int]bool
+	classes     map[ErrorClass]bool
+}
+
+// NewRetryPolicy 根据配置构建重试策略
+func NewRetryPolicy(cfg RetryConfig) (*RetryPolicy, error) {
+	p := &RetryPolicy{
+		MaxAttempts: cfg.MaxAttempts,
+		status:      make(map[int]bool),
+		classes:     make(map[ErrorClass]bool),
+	}
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = 1
+	}
+
+	var err error
+	if p.BaseDelay, err = parseRetryDelay(cfg.BaseDelay, DefaultRetryDelay); err != nil {
+		return nil, fmt.Errorf("base_delay 不合法: %w", err)
+	}
+	if p.MaxDelay, err = parseRetryDelay(cfg.MaxDelay, DefaultRetryMaxDelay); err != nil {
+		return nil, fmt.Errorf("max_delay 不合法: %w", err)
+	}
+	if p.MaxDelay < p.BaseDelay {
+		p.MaxDelay = p.BaseDelay
+	}
+
+	for _, code := range cfg.RetryStatus {
+		if code < 100 || code > 599 {
+			return nil, fmt.Errorf("retry_status 包含非法状态码: %d", code)
+		}
+		p.status[code] = true
+	}
+
+	for _, name := range cfg.RetryErrors {
+		class := ErrorClass(strings.TrimSpace(name))
+		if !isKnownErrorClass(class) {
+			return nil, fmt.Errorf("未知的错误分类: %s（可选: %s）", name, strings.Join(ErrorClassNames(), ", "))
+		}
+		p.classes[class] = true
+	}
+
+	return p, nil
+}
+
+// ErrorClassNames 可配置的错误分类名
+func ErrorClassNames() []string {
+	names := make([]string, 0, len(errorClasses))
+	for _, c := range errorClasses {
+		names = append(names, string(c))
+	}
+	return names
+}
+
+// isKnownErrorClass 是否为可配置的错误分类
+func isKnownErrorClass(class ErrorClass) bool {
+	for _, c := range errorClasses {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRetryDelay 解析等待时间，空字符串使用默认值
+func parseRetryDelay(s, def string) (time.Duration, error) {
+	if strings.TrimSpace(s) == "" {
+		s = def
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("不能为负数: %s", s)
+	}
+	return d, nil
+}
+
+// RetryableClass 错误分类是否可重试
+func (p *RetryPolicy) RetryableClass(class ErrorClass) bool {
+	return p != nil && p.classes[class]
+}
+
+// RetryableStatus 状态码是否可重试
+func (p *RetryPolicy) RetryableStatus(code int) bool {
+	return p != nil && p.status[code]
+}
+
+// Do 按策略执行 fn，fn 返回 true 表示需要重试；返回实际尝试次数
+// 两次尝试之间按指数退避等待（base*2^n，不超过 max，带随机抖动），ctx 取消时立即停止
+func (p *RetryPolicy) Do(ctx context.Context, fn func(attempt int) bool) int {
+	maxAttempts := 1
+	if p != nil {
+		maxAttempts = p.MaxAttempts
+	}
+
+	attempt := 1
+	for ; ; attempt++ {
+		if !fn(attempt) || attempt >= maxAttempts {
+			return attempt
+		}
+
+		timer := time.NewTimer(p.delay(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt
+		}
+	}
+}
+
+// delay 第 attempt 次失败后的等待时间
+// 在 [d/2, d] 之间随机，避免大量失败的 URL 同时重试
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// ClassifyError 对 HTTP 请求错误分类
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ErrorClassNone
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return ErrorClassCancelled
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return ErrorClassTimeout
+	case errors.Is(err, errTooManyRedirects):
+		return ErrorClassRedirectLimit
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorClassConnRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE),
+		errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return ErrorClassConnReset
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		if dnsErr.IsTimeout {
+			return ErrorClassTimeout
+		}
+		return ErrorClassDNS
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorClassTimeout
+	}
+
+	var recordErr tls.RecordHeaderError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthErr x509.UnknownAuthorityError
+	if errors.As(err, &recordErr) || errors.As(err, &certErr) || errors.As(err, &unknownAuthErr) ||
+		strings.Contains(err.Error(), "tls: ") {
+		return ErrorClassTLS
+	}
+
+	return ErrorClassOther
+}
+
+// ClassifyRenderError 对渲染错误分类
+func ClassifyRenderError(err error) ErrorClass {
+	switch {
+	case err == nil:
+		return ErrorClassNone
+	case errors.Is(err, context.Canceled):
+		return ErrorClassCancelled
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassRenderTimeout
+	case errors.Is(err, errBrowserUnavailable):
+		return ErrorClassBrowser
+	case errors.Is(err, errParseFeatures):
+		return ErrorClassParse
+	default:
+		return ErrorClassRender
+	}
+}
@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestNewRetryPolicy(t *testing.T) {
+	p, err := NewRetryPolicy(DefaultRetryConfig())
+	if err != nil {
+		t.Fatalf("默认配置不合法: %v", err)
+	}
+	if p.MaxAttempts != DefaultRetryAttempts || p.BaseDelay != time.Second || p.MaxDelay != 10*time.Second {
+		t.Errorf("默认策略 = %+v", p)
+	}
+	if !p.RetryableStatus(503) || p.RetryableStatus(500) {
+		t.Error("默认只重试 429/502/503/504")
+	}
+	if !p.RetryableClass(ErrorClassTimeout) || p.RetryableClass(ErrorClassDNS) {
+		t.Error("默认重试超时，不重试 DNS 失败")
+	}
+
+	p, err = NewRetryPolicy(RetryConfig{MaxAttempts: 0, BaseDelay: "5s", MaxDelay: "1s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.MaxAttempts != 1 || p.MaxDelay != 5*time.Second {
+		t.Errorf("MaxAttempts 至少为 1，MaxDelay 不小于 BaseDelay: %+v", p)
+	}
+
+	invalid := map[string]RetryConfig{
+		"base_delay 格式错误": {BaseDelay: "abc"},
+		"max_delay 为负数":   {MaxDelay: "-1s"},
+		"非法状态码":           {RetryStatus: []int{42}},
+		"未知错误分类":          {RetryErrors: []string{"flaky"}},
+	}
+	for name, cfg := range invalid {
+		if _, err := NewRetryPolicy(cfg); err == nil {
+			t.Errorf("%s: 应返回错误", name)
+		}
+	}
+
+	var nilPolicy *RetryPolicy
+	if nilPolicy.RetryableStatus(503) || nilPolicy.RetryableClass(ErrorClassTimeout) {
+		t.Error("nil 策略不重试")
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	p := &RetryPolicy{MaxAttempts: 3}
+
+	calls := 0
+	if n := p.Do(context.Background(), func(int) bool { calls++; return true }); n != 3 || calls != 3 {
+		t.Errorf("一直失败：尝试 %d 次（调用 %d 次），期望 3", n, calls)
+	}
+
+	if n := p.Do(context.Background(), func(attempt int) bool { return attempt < 2 }); n != 2 {
+		t.Errorf("第二次成功：尝试 %d 次，期望 2", n)
+	}
+
+	var nilPolicy *RetryPolicy
+	if n := nilPolicy.Do(context.Background(), func(int) bool { return true }); n != 1 {
+		t.Errorf("nil 策略尝试 %d 次，期望 1", n)
+	}
+
+	// ctx 取消后不再等待重试
+	slow := &RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if n := slow.Do(ctx, func(int) bool { return true }); n != 1 {
+		t.Errorf("ctx 已取消：尝试 %d 次，期望 1", n)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := &RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	tests := []struct {
+		attempt int
+		max     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+		{10, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if d := p.delay(tt.attempt); d < tt.max/2 || d > tt.max {
+				t.Errorf("delay(%d) = %v，期望在 [%v, %v] 之间", tt.attempt, d, tt.max/2, tt.max)
+			}
+		}
+	}
+}
+
+// timeoutError 实现 net.Error 的超时错误
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyError(t *testing.T) {
+	wrap := func(err error) error {
+		return &url.Error{Op: "Get", URL: "http://a.com/", Err: err}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want ErrorClass
+	}{
+		{"nil", nil, ErrorClassNone},
+		{"取消", wrap(context.Canceled), ErrorClassCancelled},
+		{"超时", wrap(context.DeadlineExceeded), ErrorClassTimeout},
+		{"读超时", wrap(os.ErrDeadlineExceeded), ErrorClassTimeout},
+		{"net.Error 超时", wrap(timeoutError{}), ErrorClassTimeout},
+		{"重定向超限", wrap(errTooManyRedirects), ErrorClassRedirectLimit},
+		{"连接被拒绝", wrap(&net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), ErrorClassConnRefused},
+		{"连接被重置", wrap(&net.OpError{Op: "read", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), ErrorClassConnReset},
+		{"意外 EOF", wrap(io.ErrUnexpectedEOF), ErrorClassConnReset},
+		{"DNS", wrap(&net.DNSError{Err: "no such host", Name: "a.com", IsNotFound: true}), ErrorClassDNS},
+		{"DNS 超时", wrap(&net.DNSError{Err: "timeout", Name: "a.com", IsTimeout: true}), ErrorClassTimeout},
+		{"TLS", wrap(fmt.Errorf("remote error: tls: handshake failure")), ErrorClassTLS},
+		{"其他", errors.New("boom"), ErrorClassOther},
+	}
+	for _, tt := range tests {
+		if got := ClassifyError(tt.err); got != tt.want {
+			t.Errorf("%s: ClassifyError = %q，期望 %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyRenderError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want ErrorClass
+	}{
+		{nil, ErrorClassNone},
+		{context.Canceled, ErrorClassCancelled},
+		{fmt.Errorf("导航失败: %w", context.DeadlineExceeded), ErrorClassRenderTimeout},
+		{fmt.Errorf("%w: 重启失败", errBrowserUnavailable), ErrorClassBrowser},
+		{fmt.Errorf("%w: bad json", errParseFeatures), ErrorClassParse},
+		{errors.New("net::ERR_ABORTED"), ErrorClassRender},
+	}
+	for _, tt := range tests {
+		if got := ClassifyRenderError(tt.err); got != tt.want {
+			t.Errorf("ClassifyRenderError(%v) = %q，期望 %q", tt.err, got, tt.want)
+		}
+	}
+}
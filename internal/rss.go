@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// processTreeRSS 统计进程及其所有子进程的 RSS（字节）
+// Chrome 的渲染进程、GPU 进程都是浏览器主进程的子进程，只看主进程会严重低估内存占用；
+// 依赖 /proc，非 Linux 系统返回 0（即不按 RSS 回收）
+func processTreeRSS(rootPid int) uint64 {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return 0
+	}
+
+	// pid -> 子进程
+	children := make(map[int][]int)
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		ppid, ok := readParentPid(pid)
+		if !ok {
+			continue
+		}
+		children[ppid] = append(children[ppid], pid)
+	}
+
+	pageSize := uint64(os.Getpagesize())
+	var total uint64
+	queue := []int{rootPid}
+	for len(queue) > 0 {
+		pid := queue[0]
+		queue = queue[1:]
+		total += readRSSPages(pid) * pageSize
+		queue = append(queue, children[pid]...)
+	}
+	return total
+}
+
+// readParentPid 从 /proc/<pid>/stat 读取父进程 ID
+func readParentPid(pid int) (int, bool) {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, false
+	}
+
+	// 格式：pid (comm) state ppid ...，comm 里可能有空格和括号，从最后一个 ')' 之后解析
+	s := string(data)
+	idx := strings.LastIndexByte(s, ')')
+	if idx < 0 {
+		return 0, false
+	}
+	fields := strings.Fields(s[idx+1:])
+	if len(fields) < 2 {
+		return 0, false
+	}
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, false
+	}
+	return ppid, true
+}
+
+// readRSSPages 从 /proc/<pid>/statm 读取常驻内存页数
+func readRSSPages(pid int) uint64 {
+	data, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "statm"))
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0
+	}
+	pages, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return pages
+}
@@ -0,0 +1,37 @@
+package internal
+
+import (
+	"os"
+	"runtime"
+	"testing"
+)
+
+func TestReadParentPid(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("依赖 /proc")
+	}
+	ppid, ok := readParentPid(os.Getpid())
+	if !ok || ppid != os.Getppid() {
+		t.Errorf("readParentPid = %d, %v，期望 %d", ppid, ok, os.Getppid())
+	}
+	if _, ok := readParentPid(-1); ok {
+		t.Error("不存在的进程应返回 false")
+	}
+}
+
+func TestProcessTreeRSS(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("依赖 /proc")
+	}
+	self := readRSSPages(os.Getpid()) * uint64(os.Getpagesize())
+	if self == 0 {
+		t.Fatal("当前进程 RSS 为 0")
+	}
+	// 进程树至少包含当前进程自身
+	if total := processTreeRSS(os.Getpid()); total < self/2 {
+		t.Errorf("processTreeRSS = %d，当前进程 RSS = %d", total, self)
+	}
+	if got := processTreeRSS(-1); got != 0 {
+		t.Errorf("不存在的进程 RSS = %d，期望 0", got)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/url"
 	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -12,7 +13,15 @@ import (
 type RuleAssignment struct {
 	ClusterID   string // 例如 "err5xx-http_example.com_80"
 	IsCanonical bool
-	Priority    int // 内部优先级，优先级高的先执行，避免被覆盖
+	Priority    int    // 内部优先级，优先级高的先执行，避免被覆盖
+	RuleID      string // 产生该分配的规则 ID，例如 "E1"
+}
+
+// RuleAnnotation 标注型规则（annotate: true）的分组结果
+// 只在报告中标注 URL 属于哪个分组（如同一 favicon 的站点），不影响 URL 的聚类分配
+type RuleAnnotation struct {
+	RuleID    string `json:"rule_id"`
+	ClusterID string `json:"cluster_id"`
 }
 
 // HtmlFingerprint HTML 指纹
@@ -24,10 +33,11 @@ type HtmlFingerprint struct {
 
 // perURLInfo 每个 URL 的规则聚类信息
 type perURLInfo struct {
-	FR     FetchResult
-	Origin string
-	HtmlFP HtmlFingerprint
-	IsHTML bool
+	FR      FetchResult
+	Origin  string
+	HtmlFP  HtmlFingerprint
+	IsHTML  bool
+	Matched map[string]bool // 命中条件的规则 ID
 }
 
 // OriginKey 计算 origin key
@@ -120,14 +130,15 @@ func hash64ForRule(s string) uint64 {
 }
 
 // BuildRuleAssignments 构建规则聚类分配
-// 按优先级顺序执行规则，优先级高的先执行，避免被低优先级规则覆盖
-func BuildRuleAssignments(fetchResults []FetchResult) map[int]RuleAssignment {
+// 按优先级顺序执行规则，优先级高的先执行，避免被低优先级规则覆盖；
+// 标注型规则的分组单独返回（URL ID -> 标注），不参与分配
+// 规则定义见 default_rules.yaml，用户可以通过规则文件增加、替换或禁用规则
+func BuildRuleAssignments(fetchResults []FetchResult, rules []*Rule) (map[int]RuleAssignment, map[int][]RuleAnnotation) {
 	assignments := make(map[int]RuleAssignment)
+	annotations := make(map[int][]RuleAnnotation)
 
-	// 先收集 per-origin 和 per-finalURL 的信息
-	originMap := make(map[string][]perURLInfo)
-	finalURLMap := make(map[string][]perURLInfo)
-
+	// 先收集每个 URL 的规则聚类信息
+	infos := make([]perURLInfo, 0, len(fetchResults))
 	for _, fr := range fetchResults {
 		origin := OriginKey(fr.FinalURL)
 		if origin == "" {
@@ -138,344 +149,128 @@ func BuildRuleAssignments(fetchResults []FetchResult) map[int]RuleAssignment {
 		if origin == "" {
 			continue
 		}
+		// 虚拟主机扫描时同一主机名在不同 IP 上分开归类
+		origin = fr.targetScope(origin)
 
-		info := perURLInfo{
-			FR:     fr,
-			Origin: origin,
-			IsHTML: strings.Contains(strings.ToLower(fr.ContentType), "text/html"),
+		// 原始内容还在时现场计算；Run 中已在释放原始内容前计算过
+		if !fr.rulesPrepared {
+			PrepareRuleMatches(&fr, rules)
 		}
 
-		// 如果是 HTML，计算指纹
-		if info.IsHTML && len(fr.RawHTML) > 0 {
-			info.HtmlFP = FingerprintHTML(fr.RawHTML)
+		matched := make(map[string]bool, len(fr.MatchedRules))
+		for _, id := range fr.MatchedRules {
+			matched[id] = true
 		}
 
-		originMap[origin] = append(originMap[origin], info)
-		finalURLMap[fr.FinalURL] = append(finalURLMap[fr.FinalURL], info)
+		infos = append(infos, perURLInfo{
+			FR:      fr,
+			Origin:  origin,
+			HtmlFP:  fr.HtmlFP,
+			IsHTML:  fr.ContentCategory == ContentCategoryHTML,
+			Matched: matched,
+		})
 	}
 
-	// 按优先级顺序执行规则
-	// E1: 同 origin + 5xx 错误
-	applyRuleE1(originMap, assignments)
-
-	// E3: 统一错误模板（404、401、403 等）
-	applyRuleE3(originMap, assignments)
-
-	// L1: 统一登录墙
-	applyRuleL1(originMap, assignments)
-
-	// W1: WAF 拦截页
-	applyRuleW1(originMap, assignments)
-
-	// M1: 维护/升级页
-	applyRuleM1(originMap, assignments)
-
-	// T1: 超短/空 HTML 页
-	applyRuleT1(originMap, assignments)
-
-	// R1: 重定向归并（同 FinalURL）
-	applyRuleR1(finalURLMap, assignments)
-
-	// U1: URL 小变体归一
-	applyRuleU1(originMap, assignments)
-
-	return assignments
-}
-
-// applyRuleE1 规则 E1：同 origin + 5xx 错误
-// 同一个 origin 下所有 5xx 状态码的页面归为一类
-func applyRuleE1(originMap map[string][]perURLInfo, assignments map[int]RuleAssignment) {
-	for origin, urls := range originMap {
-		var err5xx []perURLInfo
-		for _, info := range urls {
-			if info.FR.StatusCode >= 500 && info.FR.StatusCode < 600 {
-				err5xx = append(err5xx, info)
-			}
-		}
-
-		if len(err5xx) < 2 {
-			continue
-		}
-
-		// 生成 cluster ID
-		originSanitized := sanitizeForClusterID(origin)
-		clusterID := fmt.Sprintf("err5xx-%s", originSanitized)
-
-		// 选 canonical：path 最短，ID 最小
-		canonicalID := selectCanonicalByPath(err5xx)
-
-		// 分配
-		for _, info := range err5xx {
-			if _, exists := assignments[info.FR.ID]; !exists {
-				assignments[info.FR.ID] = RuleAssignment{
-					ClusterID:   clusterID,
-					IsCanonical: info.FR.ID == canonicalID,
-					Priority:    1, // E1 优先级
-				}
-			}
-		}
+	// 按优先级顺序执行规则（rules 已按 priority 排序）
+	for _, rule := range rules {
+		applyRule(rule, infos, assignments, annotations)
 	}
-}
-
-// applyRuleE3 规则 E3：统一错误模板
-// 404、401、403 或 200 但包含错误关键词的页面
-// 按 HTML 指纹分组，长度差异 < 20% 的才归为一类
-func applyRuleE3(originMap map[string][]perURLInfo, assignments map[int]RuleAssignment) {
-	for origin, urls := range originMap {
-		// 找出 404、401、403 或 200 但包含错误关键词的页面
-		var errorPages []perURLInfo
-		for _, info := range urls {
-			if info.FR.StatusCode == 404 || info.FR.StatusCode == 401 || info.FR.StatusCode == 403 {
-				errorPages = append(errorPages, info)
-			} else if info.FR.StatusCode == 200 && info.IsHTML && len(info.FR.RawHTML) > 0 {
-				// 检查是否包含错误关键词
-				htmlLower := strings.ToLower(string(info.FR.RawHTML))
-				if containsErrorKeywords(htmlLower) {
-					errorPages = append(errorPages, info)
-				}
-			}
-		}
-
-		if len(errorPages) < 2 {
-			continue
-		}
 
-		// 按 HTML 指纹分组（包括非 HTML 的 404，用 0 作为指纹）
-		fpGroups := make(map[uint64][]perURLInfo)
-		for _, info := range errorPages {
-			if info.IsHTML {
-				fpGroups[info.HtmlFP.Hash] = append(fpGroups[info.HtmlFP.Hash], info)
-			} else {
-				// 非 HTML 的 404 页面，使用 0 作为指纹
-				fpGroups[0] = append(fpGroups[0], info)
-			}
-		}
-
-		originSanitized := sanitizeForClusterID(origin)
-		for fpHash, group := range fpGroups {
-			if len(group) < 2 {
-				continue
-			}
-
-			// 检查长度是否接近（差异 < 20%）
-			if !isLengthSimilar(group) {
-				continue
-			}
-
-			clusterID := fmt.Sprintf("errtpl-%s-%x", originSanitized, fpHash&0xFFFF)
-			canonicalID := selectCanonicalByPath(group)
-
-			for _, info := range group {
-				if _, exists := assignments[info.FR.ID]; !exists {
-					assignments[info.FR.ID] = RuleAssignment{
-						ClusterID:   clusterID,
-						IsCanonical: info.FR.ID == canonicalID,
-						Priority:    3, // E3 优先级
-					}
-				}
-			}
-		}
-	}
+	return assignments, annotations
 }
 
-// applyRuleL1 规则 L1：统一登录墙
-// 包含登录关键词的页面按 HTML 指纹分组
-func applyRuleL1(originMap map[string][]perURLInfo, assignments map[int]RuleAssignment) {
-	for origin, urls := range originMap {
-		var loginPages []perURLInfo
-		for _, info := range urls {
-			if info.IsHTML && len(info.FR.RawHTML) > 0 {
-				htmlLower := strings.ToLower(string(info.FR.RawHTML))
-				if containsLoginKeywords(htmlLower) {
-					loginPages = append(loginPages, info)
-				}
-			}
-		}
-
-		if len(loginPages) < 2 {
+// applyRule 执行单条规则
+// 命中条件的 URL 先按 scope（origin 或最终 URL）分组，再按 group_by 细分，
+// 组内 URL 数达到 min_group_size（且长度相近）的归为一个 cluster；标注型规则只写入 annotations
+func applyRule(rule *Rule, infos []perURLInfo, assignments map[int]RuleAssignment, annotations map[int][]RuleAnnotation) {
+	scopes := make(map[string][]perURLInfo)
+	for _, info := range infos {
+		if !info.Matched[rule.ID] {
 			continue
 		}
-
-		// 按指纹分组
-		fpGroups := make(map[uint64][]perURLInfo)
-		for _, info := range loginPages {
-			fpGroups[info.HtmlFP.Hash] = append(fpGroups[info.HtmlFP.Hash], info)
-		}
-
-		originSanitized := sanitizeForClusterID(origin)
-		for fpHash, group := range fpGroups {
-			if len(group) < 2 {
-				continue
-			}
-
-			if !isLengthSimilar(group) {
+		// 只处理还没有被规则分配的 URL
+		if rule.OnlyUnassigned {
+			if _, exists := assignments[info.FR.ID]; exists {
 				continue
 			}
-
-			clusterID := fmt.Sprintf("loginwall-%s-%x", originSanitized, fpHash&0xFFFF)
-			canonicalID := selectCanonicalByPath(group)
-
-			for _, info := range group {
-				if _, exists := assignments[info.FR.ID]; !exists {
-					assignments[info.FR.ID] = RuleAssignment{
-						ClusterID:   clusterID,
-						IsCanonical: info.FR.ID == canonicalID,
-						Priority:    4, // L1 优先级
-					}
-				}
-			}
-		}
-	}
-}
-
-// applyRuleW1 规则 W1：WAF 拦截页
-// 包含 WAF 关键词的页面按 HTML 指纹分组
-func applyRuleW1(originMap map[string][]perURLInfo, assignments map[int]RuleAssignment) {
-	for origin, urls := range originMap {
-		var wafPages []perURLInfo
-		for _, info := range urls {
-			if info.IsHTML && len(info.FR.RawHTML) > 0 {
-				htmlLower := strings.ToLower(string(info.FR.RawHTML))
-				if containsWAFKeywords(htmlLower) {
-					wafPages = append(wafPages, info)
-				}
-			}
 		}
 
-		if len(wafPages) < 2 {
-			continue
-		}
-
-		fpGroups := make(map[uint64][]perURLInfo)
-		for _, info := range wafPages {
-			fpGroups[info.HtmlFP.Hash] = append(fpGroups[info.HtmlFP.Hash], info)
-		}
-
-		originSanitized := sanitizeForClusterID(origin)
-		for fpHash, group := range fpGroups {
-			if len(group) < 2 {
+		scopeKey := info.Origin
+		switch rule.Scope {
+		case RuleScopeFinalURL:
+			// 没有最终 URL（请求失败）的不参与重定向归并
+			if info.FR.FinalURL == "" {
 				continue
 			}
-
-			if !isLengthSimilar(group) {
+			scopeKey = info.FR.FinalURL
+		case RuleScopeFavicon:
+			// 没有 favicon 的不参与
+			if info.FR.Favicon == nil {
 				continue
 			}
-
-			clusterID := fmt.Sprintf("waf-%s-%x", originSanitized, fpHash&0xFFFF)
-			canonicalID := selectCanonicalByPath(group)
-
-			for _, info := range group {
-				if _, exists := assignments[info.FR.ID]; !exists {
-					assignments[info.FR.ID] = RuleAssignment{
-						ClusterID:   clusterID,
-						IsCanonical: info.FR.ID == canonicalID,
-						Priority:    5, // W1 优先级
-					}
-				}
+			scopeKey = fmt.Sprintf("%d", info.FR.Favicon.MMH3)
+		case RuleScopeCert:
+			// 非 HTTPS 的不参与；通配符证书和 CDN 共享证书不能说明站点属于同一方，也不参与
+			if info.FR.Cert == nil || info.FR.Cert.Shared() {
+				continue
 			}
+			scopeKey = info.FR.Cert.SHA256
 		}
+		scopes[scopeKey] = append(scopes[scopeKey], info)
 	}
-}
 
-// applyRuleM1 规则 M1：维护/升级页
-// 包含维护关键词的页面按 HTML 指纹分组
-func applyRuleM1(originMap map[string][]perURLInfo, assignments map[int]RuleAssignment) {
-	for origin, urls := range originMap {
-		var maintPages []perURLInfo
-		for _, info := range urls {
-			if info.IsHTML && len(info.FR.RawHTML) > 0 {
-				htmlLower := strings.ToLower(string(info.FR.RawHTML))
-				if containsMaintenanceKeywords(htmlLower) {
-					maintPages = append(maintPages, info)
-				}
-			}
-		}
-
-		if len(maintPages) < 2 {
+	usedIDs := make(map[string]bool)
+	for scopeKey, urls := range scopes {
+		if len(urls) < rule.MinGroupSize {
 			continue
 		}
 
-		fpGroups := make(map[uint64][]perURLInfo)
-		for _, info := range maintPages {
-			fpGroups[info.HtmlFP.Hash] = append(fpGroups[info.HtmlFP.Hash], info)
+		groups := groupRuleURLs(rule, urls)
+		groupKeys := make([]string, 0, len(groups))
+		for groupKey := range groups {
+			groupKeys = append(groupKeys, groupKey)
 		}
+		sort.Strings(groupKeys)
 
-		originSanitized := sanitizeForClusterID(origin)
-		for fpHash, group := range fpGroups {
-			if len(group) < 2 {
+		for _, groupKey := range groupKeys {
+			group := groups[groupKey]
+			if len(group) < rule.MinGroupSize {
 				continue
 			}
 
-			if !isLengthSimilar(group) {
+			// 检查长度是否接近
+			if rule.LengthTolerance > 0 && !isLengthSimilar(group, 1-rule.LengthTolerance) {
 				continue
 			}
 
-			clusterID := fmt.Sprintf("maint-%s-%x", originSanitized, fpHash&0xFFFF)
-			canonicalID := selectCanonicalByPath(group)
-
-			for _, info := range group {
-				if _, exists := assignments[info.FR.ID]; !exists {
-					assignments[info.FR.ID] = RuleAssignment{
-						ClusterID:   clusterID,
-						IsCanonical: info.FR.ID == canonicalID,
-						Priority:    6, // M1 优先级
-					}
-				}
+			// 分组按完整哈希，ID 里只显示低 16 位；低 16 位相同的不同分组改用完整哈希，避免 ID 相同被合并
+			clusterID := ruleClusterID(rule, scopeKey, shortGroupKey(rule, groupKey))
+			if usedIDs[clusterID] {
+				clusterID = ruleClusterID(rule, scopeKey, groupKey)
 			}
-		}
-	}
-}
+			usedIDs[clusterID] = true
 
-// applyRuleT1 规则 T1：超短/空 HTML 页
-// HTML 大小 < 1KB 或文本长度 < 200 字符的页面
-// 包括 2xx、401、403 状态码
-func applyRuleT1(originMap map[string][]perURLInfo, assignments map[int]RuleAssignment) {
-	for origin, urls := range originMap {
-		var thinPages []perURLInfo
-		for _, info := range urls {
-			// 包括 2xx、401、403 状态码的 HTML 页面
-			if ((info.FR.StatusCode >= 200 && info.FR.StatusCode < 300) ||
-				info.FR.StatusCode == 401 || info.FR.StatusCode == 403) &&
-				info.IsHTML {
-				// 超短：小于 1KB 或（已计算指纹且）文本长度小于阈值
-				if len(info.FR.RawHTML) < 1024 {
-					thinPages = append(thinPages, info)
-				} else if info.HtmlFP.Length > 0 && info.HtmlFP.Length < MinTextLength {
-					// 只有当指纹已计算且长度小于阈值时才加入
-					thinPages = append(thinPages, info)
+			if rule.Annotate {
+				for _, info := range group {
+					annotations[info.FR.ID] = append(annotations[info.FR.ID], RuleAnnotation{RuleID: rule.ID, ClusterID: clusterID})
 				}
-			}
-		}
-
-		if len(thinPages) < 2 {
-			continue
-		}
-
-		// 按指纹分组
-		fpGroups := make(map[uint64][]perURLInfo)
-		for _, info := range thinPages {
-			fpGroups[info.HtmlFP.Hash] = append(fpGroups[info.HtmlFP.Hash], info)
-		}
-
-		originSanitized := sanitizeForClusterID(origin)
-		for fpHash, group := range fpGroups {
-			if len(group) < 2 {
 				continue
 			}
 
-			if !isLengthSimilar(group) {
-				continue
+			var canonicalID int
+			if rule.Canonical == RuleCanonicalRedirect {
+				canonicalID = selectCanonicalForRedirect(group)
+			} else {
+				canonicalID = selectCanonicalByPath(group)
 			}
 
-			clusterID := fmt.Sprintf("thin-%s-%x", originSanitized, fpHash&0xFFFF)
-			canonicalID := selectCanonicalByPath(group)
-
 			for _, info := range group {
 				if _, exists := assignments[info.FR.ID]; !exists {
 					assignments[info.FR.ID] = RuleAssignment{
 						ClusterID:   clusterID,
 						IsCanonical: info.FR.ID == canonicalID,
-						Priority:    7, // T1 优先级
+						Priority:    rule.Priority,
+						RuleID:      rule.ID,
 					}
 				}
 			}
@@ -483,88 +278,73 @@ func applyRuleT1(originMap map[string][]perURLInfo, assignments map[int]RuleAssi
 	}
 }
 
-// applyRuleR1 规则 R1：重定向归并
-// 最终 URL 相同的页面归为一类（不同 URL 重定向到同一个页面）
-func applyRuleR1(finalURLMap map[string][]perURLInfo, assignments map[int]RuleAssignment) {
-	for finalURL, urls := range finalURLMap {
-		if len(urls) < 2 {
-			continue
-		}
+// groupRuleURLs 按 group_by 把同一 scope 的 URL 细分
+func groupRuleURLs(rule *Rule, urls []perURLInfo) map[string][]perURLInfo {
+	groups := make(map[string][]perURLInfo)
 
-		// 只处理还没有被规则分配的 URL
-		var unassigned []perURLInfo
+	switch rule.GroupBy {
+	case RuleGroupFingerprint:
+		// 按 HTML 指纹分组（非 HTML 的指纹为 0，归为同一组）
 		for _, info := range urls {
-			if _, exists := assignments[info.FR.ID]; !exists {
-				unassigned = append(unassigned, info)
-			}
+			key := fmt.Sprintf("%x", info.HtmlFP.Hash)
+			groups[key] = append(groups[key], info)
 		}
 
-		if len(unassigned) < 2 {
-			continue
-		}
-
-		// 生成 cluster ID
-		hash := md5.Sum([]byte(finalURL))
-		clusterID := fmt.Sprintf("redir-%x", hash[:8])
-
-		// 选 canonical：StatusCode 2xx 优先，其次 path 最短，再其次 ID 最小
-		canonicalID := selectCanonicalForRedirect(unassigned)
-
-		for _, info := range unassigned {
-			assignments[info.FR.ID] = RuleAssignment{
-				ClusterID:   clusterID,
-				IsCanonical: info.FR.ID == canonicalID,
-				Priority:    8, // R1 优先级（较低）
-			}
-		}
-	}
-}
-
-// applyRuleU1 规则 U1：URL 小变体归一
-// 规范化 path 后相同的 URL 归为一类（比如 /index.html 和 /）
-func applyRuleU1(originMap map[string][]perURLInfo, assignments map[int]RuleAssignment) {
-	for origin, urls := range originMap {
+	case RuleGroupPath:
 		// 按规范化 path 分组
-		pathGroups := make(map[string][]perURLInfo)
 		for _, info := range urls {
 			normalizedPath := normalizePath(info.FR.FinalURL)
 			if normalizedPath == "" {
 				normalizedPath = normalizePath(info.FR.NormalizedURL)
 			}
-			pathGroups[normalizedPath] = append(pathGroups[normalizedPath], info)
+			groups[normalizedPath] = append(groups[normalizedPath], info)
 		}
 
-		originSanitized := sanitizeForClusterID(origin)
-		for path, group := range pathGroups {
-			if len(group) < 2 {
-				continue
-			}
-
-			// 只处理还没有被规则分配的 URL
-			var unassigned []perURLInfo
-			for _, info := range group {
-				if _, exists := assignments[info.FR.ID]; !exists {
-					unassigned = append(unassigned, info)
-				}
-			}
+	case RuleGroupHeaders:
+		// 按响应头指纹分组（同一 origin 下不同后端返回的页面分开）
+		for _, info := range urls {
+			key := fmt.Sprintf("%x", info.FR.HeaderHash)
+			groups[key] = append(groups[key], info)
+		}
 
-			if len(unassigned) < 2 {
-				continue
-			}
+	default:
+		groups[""] = urls
+	}
 
-			pathSanitized := sanitizeForClusterID(path)
-			clusterID := fmt.Sprintf("urlcanon-%s-%s", originSanitized, pathSanitized)
-			canonicalID := selectCanonicalByPath(unassigned)
+	return groups
+}
 
-			for _, info := range unassigned {
-				assignments[info.FR.ID] = RuleAssignment{
-					ClusterID:   clusterID,
-					IsCanonical: info.FR.ID == canonicalID,
-					Priority:    9, // U1 优先级（最低）
-				}
-			}
-		}
+// shortGroupKey 按指纹、响应头哈希分组时，cluster ID 中只用哈希的低 16 位
+func shortGroupKey(rule *Rule, groupKey string) string {
+	if rule.GroupBy != RuleGroupFingerprint && rule.GroupBy != RuleGroupHeaders {
+		return groupKey
 	}
+	hash, err := strconv.ParseUint(groupKey, 16, 64)
+	if err != nil {
+		return groupKey
+	}
+	return fmt.Sprintf("%x", hash&0xFFFF)
+}
+
+// ruleClusterID 生成规则 cluster ID
+// 格式：{prefix}-{origin}[-{group}]，scope 为 final_url 时用最终 URL 的哈希代替 origin，
+// scope 为 favicon 时用 favicon 的 mmh3 哈希代替 origin，scope 为 cert 时用证书 SHA-256 指纹的前 16 位
+func ruleClusterID(rule *Rule, scopeKey, groupKey string) string {
+	var scopePart string
+	switch rule.Scope {
+	case RuleScopeFinalURL:
+		hash := md5.Sum([]byte(scopeKey))
+		scopePart = fmt.Sprintf("%x", hash[:8])
+	case RuleScopeCert:
+		scopePart = scopeKey[:min(len(scopeKey), 16)]
+	default:
+		scopePart = sanitizeForClusterID(scopeKey)
+	}
+
+	if groupKey == "" {
+		return fmt.Sprintf("%s-%s", rule.ClusterPrefix, scopePart)
+	}
+	return fmt.Sprintf("%s-%s-%s", rule.ClusterPrefix, scopePart, sanitizeForClusterID(groupKey))
 }
 
 // 辅助函数
@@ -643,6 +423,12 @@ func getPath(u string) string {
 	return parsed.Path
 }
 
+// indexDocuments 常见的目录默认文档，访问目录和访问这些文件通常是同一个页面
+var indexDocuments = []string{
+	"index.html", "index.htm", "index.shtml", "index.php", "index.jsp", "index.asp", "index.aspx",
+	"default.html", "default.htm", "default.asp", "default.aspx", "index",
+}
+
 // normalizePath 规范化 path（用于 URL 小变体归一）
 func normalizePath(u string) string {
 	parsed, err := url.Parse(u)
@@ -650,13 +436,21 @@ func normalizePath(u string) string {
 		return ""
 	}
 
+	// 去掉 ;jsessionid 等 path 参数，合并重复的 / 和 .、.. 段
 	path := parsed.Path
+	if i := strings.Index(path, ";"); i >= 0 {
+		path = path[:i]
+	}
+	path = cleanURLPath(path)
 
-	// 移除 index.html/index.htm/index.php 等
-	path = strings.TrimSuffix(path, "/index.html")
-	path = strings.TrimSuffix(path, "/index.htm")
-	path = strings.TrimSuffix(path, "/index.php")
-	path = strings.TrimSuffix(path, "/index")
+	// 移除 index.html/index.htm/index.php 等默认文档（不区分大小写）
+	lower := strings.ToLower(path)
+	for _, index := range indexDocuments {
+		if strings.HasSuffix(lower, "/"+index) {
+			path = path[:len(path)-len(index)-1]
+			break
+		}
+	}
 
 	// 规范化：空路径或根路径统一为 "/"
 	if path == "" {
@@ -669,8 +463,8 @@ func normalizePath(u string) string {
 	return path
 }
 
-// isLengthSimilar 检查长度是否相似（差异 < 20%）
-func isLengthSimilar(group []perURLInfo) bool {
+// isLengthSimilar 检查长度是否相似（最短 / 最长 >= minRatio）
+func isLengthSimilar(group []perURLInfo, minRatio float64) bool {
 	if len(group) < 2 {
 		return false
 	}
@@ -701,44 +495,7 @@ func isLengthSimilar(group []perURLInfo) bool {
 	}
 
 	ratio := float64(min) / float64(max)
-	return ratio >= 0.8 // 差异 < 20%
-}
-
-// 关键词检测函数
-
-func containsErrorKeywords(html string) bool {
-	keywords := []string{
-		"404", "page not found", "页面不存在", "not found",
-		"error", "错误", "无法找到", "找不到",
-	}
-	return containsAny(html, keywords)
-}
-
-func containsLoginKeywords(html string) bool {
-	keywords := []string{
-		"登录", "登陆", "login", "sign in", "signin",
-		"password", "密码", "username", "用户名",
-		"type=\"password\"", "type='password'",
-	}
-	return containsAny(html, keywords)
-}
-
-func containsWAFKeywords(html string) bool {
-	keywords := []string{
-		"access denied", "防火墙", "安全验证", "滑动验证",
-		"checking your browser", "cloudflare", "waf",
-		"security check", "安全检查", "验证码",
-	}
-	return containsAny(html, keywords)
-}
-
-func containsMaintenanceKeywords(html string) bool {
-	keywords := []string{
-		"维护中", "升级中", "maintenance", "under maintenance",
-		"service unavailable", "系统维护", "网站维护",
-		"upgrading", "升级", "维护",
-	}
-	return containsAny(html, keywords)
+	return ratio >= minRatio
 }
 
 func containsAny(text string, keywords []string) bool {
@@ -0,0 +1,510 @@
+package internal
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed default_rules.yaml
+var defaultRulesYAML []byte
+
+// 规则分组范围
+const (
+	RuleScopeOrigin   = "origin"    // 同 scheme://host:port
+	RuleScopeFinalURL = "final_url" // 同最终 URL
+	RuleScopeFavicon  = "favicon"   // 同 favicon（mmh3 哈希），可以跨 origin
+	RuleScopeCert     = "cert"      // 同 TLS 证书（SHA-256 指纹），可以跨 origin
+)
+
+// 规则范围内的再分组方式
+const (
+	RuleGroupNone        = "none"        // 不再分组
+	RuleGroupFingerprint = "fingerprint" // 按 HTML 指纹分组
+	RuleGroupPath        = "path"        // 按规范化 path 分组
+	RuleGroupHeaders     = "headers"     // 按响应头指纹分组
+)
+
+// canonical 选择方式
+const (
+	RuleCanonicalPath     = "path"     // path 最短，ID 最小
+	RuleCanonicalRedirect = "redirect" // 2xx 优先，其次 path 最短，ID 最小
+)
+
+// RuleFile 规则文件
+type RuleFile struct {
+	Rules []RuleDef `yaml:"rules" json:"rules"`
+}
+
+// RuleDef 规则定义（YAML）
+type RuleDef struct {
+	ID              string          `yaml:"id" json:"id"`
+	Name            string          `yaml:"name" json:"name"`
+	Priority        int             `yaml:"priority" json:"priority"`
+	ClusterPrefix   string          `yaml:"cluster_prefix" json:"cluster_prefix"`
+	Scope           string          `yaml:"scope" json:"scope"`
+	GroupBy         string          `yaml:"group_by" json:"group_by"`
+	LengthTolerance float64         `yaml:"length_tolerance" json:"length_tolerance"`
+	OnlyUnassigned  bool            `yaml:"only_unassigned" json:"only_unassigned"`
+	Canonical       string          `yaml:"canonical" json:"canonical"`
+	MinGroupSize    int             `yaml:"min_group_size" json:"min_group_size"`
+	Annotate        bool            `yaml:"annotate" json:"annotate"` // 只标注：分组写入报告的 rule_annotations，不占用 URL 的聚类分配
+	Disabled        bool            `yaml:"disabled" json:"disabled"`
+	When            []RuleCondition `yaml:"when" json:"when"`
+}
+
+// RuleCondition 规则命中条件，同一个条件内所有字段都要满足
+type RuleCondition struct {
+	Status        []string                `yaml:"status" json:"status"`
+	HTML          *bool                   `yaml:"html" json:"html"`
+	Body          *RuleMatcher            `yaml:"body" json:"body"`
+	Title         *RuleMatcher            `yaml:"title" json:"title"`
+	Path          *RuleMatcher            `yaml:"path" json:"path"`
+	Headers       map[string]*RuleMatcher `yaml:"headers" json:"headers"`
+	MinBodySize   int                     `yaml:"min_body_size" json:"min_body_size"`
+	MaxBodySize   int                     `yaml:"max_body_size" json:"max_body_size"`
+	MinTextLength int                     `yaml:"min_text_length" json:"min_text_length"`
+	MaxTextLength int                     `yaml:"max_text_length" json:"max_text_length"`
+	SoftNotFound  *bool                   `yaml:"soft_404" json:"soft_404"`
+}
+
+// RuleMatcher 文本匹配器，contains 和 regex 中任意一个命中即可
+type RuleMatcher struct {
+	Contains []string `yaml:"contains" json:"contains"` // 子串，不区分大小写
+	Regex    []string `yaml:"regex" json:"regex"`       // 正则，区分大小写需要自己加 (?i)
+}
+
+// Rule 编译后的规则
+type Rule struct {
+	RuleDef
+	conditions []compiledCondition
+}
+
+type statusRange struct {
+	lo, hi int
+}
+
+type compiledCondition struct {
+	status        []statusRange
+	html          *bool
+	body          *compiledMatcher
+	title         *compiledMatcher
+	path          *compiledMatcher
+	headers       map[string]*compiledMatcher
+	minBodySize   int
+	maxBodySize   int
+	minTextLength int
+	maxTextLength int
+	softNotFound  *bool
+}
+
+type compiledMatcher struct {
+	contains []string
+	regex    []*regexp.Regexp
+}
+
+// ruleSubject 规则匹配的对象（从 FetchResult 提取）
+type ruleSubject struct {
+	fr        *FetchResult
+	isHTML    bool
+	bodyLower string
+	body      string
+	title     string
+}
+
+// DefaultRules 返回内置规则
+func DefaultRules() ([]*Rule, error) {
+	file, err := parseRuleFile(defaultRulesYAML)
+	if err != nil {
+		return nil, fmt.Errorf("解析内置规则失败: %w", err)
+	}
+	return compileRules(file.Rules)
+}
+
+// LoadRules 加载规则：内置规则 + 用户规则文件
+// 用户规则 ID 与内置规则相同时替换内置规则；disabled 为 true 或在 disabledIDs 中的规则不执行
+func LoadRules(userRulePath string, disabledIDs []string) ([]*Rule, error) {
+	file, err := parseRuleFile(defaultRulesYAML)
+	if err != nil {
+		return nil, fmt.Errorf("解析内置规则失败: %w", err)
+	}
+	defs := file.Rules
+
+	if userRulePath != "" {
+		data, err := os.ReadFile(userRulePath)
+		if err != nil {
+			return nil, fmt.Errorf("无法读取规则文件 %s: %w", userRulePath, err)
+		}
+		userFile, err := parseRuleFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("解析规则文件 %s 失败: %w", userRulePath, err)
+		}
+		defs = mergeRuleDefs(defs, userFile.Rules)
+	}
+
+	disabled := make(map[string]bool)
+	for _, id := range disabledIDs {
+		id = strings.TrimSpace(id)
+		if id != "" {
+			disabled[id] = true
+		}
+	}
+
+	var enabled []RuleDef
+	for _, def := range defs {
+		if def.Disabled || disabled[def.ID] {
+			continue
+		}
+		enabled = append(enabled, def)
+	}
+
+	return compileRules(enabled)
+}
+
+// parseRuleFile 解析规则文件（YAML，JSON 是 YAML 的子集也可以直接解析）
+func parseRuleFile(data []byte) (*RuleFile, error) {
+	file := &RuleFile{}
+	if err := yaml.Unmarshal(data, file); err != nil {
+		return nil, err
+	}
+	return file, nil
+}
+
+// mergeRuleDefs 合并规则，ID 相同的用户规则替换基础规则
+func mergeRuleDefs(base, override []RuleDef) []RuleDef {
+	merged := make([]RuleDef, len(base))
+	copy(merged, base)
+
+	index := make(map[string]int)
+	for i, def := range merged {
+		index[def.ID] = i
+	}
+
+	for _, def := range override {
+		if i, ok := index[def.ID]; ok {
+			merged[i] = def
+			continue
+		}
+		index[def.ID] = len(merged)
+		merged = append(merged, def)
+	}
+
+	return merged
+}
+
+// compileRules 校验并编译规则，按优先级排序
+func compileRules(defs []RuleDef) ([]*Rule, error) {
+	rules := make([]*Rule, 0, len(defs))
+	seen := make(map[string]bool)
+
+	for _, def := range defs {
+		if def.ID == "" {
+			return nil, fmt.Errorf("规则缺少 id")
+		}
+		if seen[def.ID] {
+			return nil, fmt.Errorf("规则 id 重复: %s", def.ID)
+		}
+		seen[def.ID] = true
+
+		rule, err := compileRule(def)
+		if err != nil {
+			return nil, fmt.Errorf("规则 %s: %w", def.ID, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Priority < rules[j].Priority
+	})
+
+	return rules, nil
+}
+
+// compileRule 编译单条规则，填充默认值
+func compileRule(def RuleDef) (*Rule, error) {
+	if def.ClusterPrefix == "" {
+		def.ClusterPrefix = strings.ToLower(def.ID)
+	}
+	if def.Scope == "" {
+		def.Scope = RuleScopeOrigin
+	}
+	if def.GroupBy == "" {
+		def.GroupBy = RuleGroupNone
+	}
+	if def.Canonical == "" {
+		def.Canonical = RuleCanonicalPath
+	}
+	if def.MinGroupSize < 2 {
+		def.MinGroupSize = 2
+	}
+
+	switch def.Scope {
+	case RuleScopeOrigin, RuleScopeFinalURL, RuleScopeFavicon, RuleScopeCert:
+	default:
+		return nil, fmt.Errorf("未知的 scope: %s", def.Scope)
+	}
+	switch def.GroupBy {
+	case RuleGroupNone, RuleGroupFingerprint, RuleGroupPath, RuleGroupHeaders:
+	default:
+		return nil, fmt.Errorf("未知的 group_by: %s", def.GroupBy)
+	}
+	switch def.Canonical {
+	case RuleCanonicalPath, RuleCanonicalRedirect:
+	default:
+		return nil, fmt.Errorf("未知的 canonical: %s", def.Canonical)
+	}
+	if def.LengthTolerance < 0 || def.LengthTolerance >= 1 {
+		return nil, fmt.Errorf("length_tolerance 必须在 [0, 1) 范围内: %v", def.LengthTolerance)
+	}
+
+	rule := &Rule{RuleDef: def}
+	for i, cond := range def.When {
+		cc, err := compileCondition(cond)
+		if err != nil {
+			return nil, fmt.Errorf("条件 %d: %w", i+1, err)
+		}
+		rule.conditions = append(rule.conditions, cc)
+	}
+
+	return rule, nil
+}
+
+// compileCondition 编译命中条件
+func compileCondition(cond RuleCondition) (compiledCondition, error) {
+	cc := compiledCondition{
+		html:          cond.HTML,
+		minBodySize:   cond.MinBodySize,
+		maxBodySize:   cond.MaxBodySize,
+		minTextLength: cond.MinTextLength,
+		maxTextLength: cond.MaxTextLength,
+		softNotFound:  cond.SoftNotFound,
+	}
+
+	for _, s := range cond.Status {
+		r, err := parseStatusRange(s)
+		if err != nil {
+			return cc, err
+		}
+		cc.status = append(cc.status, r)
+	}
+
+	var err error
+	if cc.body, err = compileMatcher(cond.Body); err != nil {
+		return cc, fmt.Errorf("body: %w", err)
+	}
+	if cc.title, err = compileMatcher(cond.Title); err != nil {
+		return cc, fmt.Errorf("title: %w", err)
+	}
+	if cc.path, err = compileMatcher(cond.Path); err != nil {
+		return cc, fmt.Errorf("path: %w", err)
+	}
+	if len(cond.Headers) > 0 {
+		cc.headers = make(map[string]*compiledMatcher)
+		for name, m := range cond.Headers {
+			compiled, err := compileMatcher(m)
+			if err != nil {
+				return cc, fmt.Errorf("header %s: %w", name, err)
+			}
+			cc.headers[strings.ToLower(name)] = compiled
+		}
+	}
+
+	return cc, nil
+}
+
+// compileMatcher 编译匹配器
+func compileMatcher(m *RuleMatcher) (*compiledMatcher, error) {
+	if m == nil {
+		return nil, nil
+	}
+	cm := &compiledMatcher{}
+	for _, s := range m.Contains {
+		cm.contains = append(cm.contains, strings.ToLower(s))
+	}
+	for _, expr := range m.Regex {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("无效的正则 %q: %w", expr, err)
+		}
+		cm.regex = append(cm.regex, re)
+	}
+	return cm, nil
+}
+
+// parseStatusRange 解析状态码范围："404"、"500-599"、"2xx"
+func parseStatusRange(s string) (statusRange, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	if len(s) == 3 && strings.HasSuffix(s, "xx") {
+		d, err := strconv.Atoi(s[:1])
+		if err != nil {
+			return statusRange{}, fmt.Errorf("无效的状态码: %s", s)
+		}
+		return statusRange{lo: d * 100, hi: d*100 + 99}, nil
+	}
+
+	if lo, hi, ok := strings.Cut(s, "-"); ok {
+		l, err1 := strconv.Atoi(strings.TrimSpace(lo))
+		h, err2 := strconv.Atoi(strings.TrimSpace(hi))
+		if err1 != nil || err2 != nil || l > h {
+			return statusRange{}, fmt.Errorf("无效的状态码范围: %s", s)
+		}
+		return statusRange{lo: l, hi: h}, nil
+	}
+
+	code, err := strconv.Atoi(s)
+	if err != nil {
+		return statusRange{}, fmt.Errorf("无效的状态码: %s", s)
+	}
+	return statusRange{lo: code, hi: code}, nil
+}
+
+// matches 判断 URL 是否命中规则（任意一个条件满足即命中）
+func (r *Rule) matches(subj *ruleSubject) bool {
+	if len(r.conditions) == 0 {
+		return true
+	}
+	for i := range r.conditions {
+		if r.conditions[i].matches(subj) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches 判断 URL 是否满足条件（所有字段都要满足）
+func (c *compiledCondition) matches(subj *ruleSubject) bool {
+	fr := subj.fr
+
+	if len(c.status) > 0 {
+		ok := false
+		for _, r := range c.status {
+			if fr.StatusCode >= r.lo && fr.StatusCode <= r.hi {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	if c.html != nil && *c.html != subj.isHTML {
+		return false
+	}
+
+	if c.softNotFound != nil && *c.softNotFound != fr.SoftNotFound {
+		return false
+	}
+
+	bodySize := len(fr.RawHTML)
+	if c.minBodySize > 0 && bodySize < c.minBodySize {
+		return false
+	}
+	if c.maxBodySize > 0 && bodySize > c.maxBodySize {
+		return false
+	}
+
+	textLength := fr.HtmlFP.Length
+	if c.minTextLength > 0 && textLength < c.minTextLength {
+		return false
+	}
+	if c.maxTextLength > 0 && textLength > c.maxTextLength {
+		return false
+	}
+
+	if c.body != nil && !c.body.matches(subj.bodyLower, subj.body) {
+		return false
+	}
+	if c.title != nil && !c.title.matches(strings.ToLower(subj.title), subj.title) {
+		return false
+	}
+	if c.path != nil {
+		path := ruleURLPath(fr)
+		if !c.path.matches(strings.ToLower(path), path) {
+			return false
+		}
+	}
+	for name, m := range c.headers {
+		value := ruleHeaderValue(fr, name)
+		if !m.matches(strings.ToLower(value), value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matches 匹配文本，lower 为转小写后的文本
+func (m *compiledMatcher) matches(lower, original string) bool {
+	if len(m.contains) == 0 && len(m.regex) == 0 {
+		return true
+	}
+	if containsAny(lower, m.contains) {
+		return true
+	}
+	for _, re := range m.regex {
+		if re.MatchString(original) {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleURLPath 取规则匹配用的 path（最终 URL 的 path，没有最终 URL 时用输入 URL，空 path 为 "/"）
+func ruleURLPath(fr *FetchResult) string {
+	u := fr.FinalURL
+	if u == "" {
+		u = fr.NormalizedURL
+	}
+	path := getPath(u)
+	if path == "" {
+		path = "/"
+	}
+	return path
+}
+
+// ruleHeaderValue 取规则匹配用的响应头（规范化后的值，Set-Cookie 为 cookie 名称列表）
+func ruleHeaderValue(fr *FetchResult, name string) string {
+	name = strings.ToLower(name)
+	if value, ok := fr.Headers[name]; ok {
+		return value
+	}
+	if name == "content-type" {
+		return fr.ContentType
+	}
+	return ""
+}
+
+// PrepareRuleMatches 在释放原始内容之前计算规则聚类需要的信息
+// 计算 HTML 指纹，并记录命中了哪些规则的条件，之后 RawHTML 可以安全释放
+func PrepareRuleMatches(fr *FetchResult, rules []*Rule) {
+	isHTML := fr.ContentCategory == ContentCategoryHTML
+	if isHTML && len(fr.RawHTML) > 0 {
+		fr.HtmlFP = FingerprintHTML(fr.RawHTML)
+	}
+
+	subj := &ruleSubject{
+		fr:     fr,
+		isHTML: isHTML,
+		title:  fr.Title,
+	}
+	if isHTML && len(fr.RawHTML) > 0 {
+		subj.body = string(fr.RawHTML)
+		subj.bodyLower = strings.ToLower(subj.body)
+	}
+
+	fr.MatchedRules = fr.MatchedRules[:0]
+	for _, rule := range rules {
+		if rule.matches(subj) {
+			fr.MatchedRules = append(fr.MatchedRules, rule.ID)
+		}
+	}
+	fr.rulesPrepared = true
+}
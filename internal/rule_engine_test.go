@@ -0,0 +1,274 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseStatusRange(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    statusRange
+		wantErr bool
+	}{
+		{"404", statusRange{404, 404}, false},
+		{"5xx", statusRange{500, 599}, false},
+		{"2XX", statusRange{200, 299}, false},
+		{"500-503", statusRange{500, 503}, false},
+		{"503-500", statusRange{}, true},
+		{"abc", statusRange{}, true},
+		{"xxx", statusRange{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseStatusRange(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseStatusRange(%q) err = %v, wantErr %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseStatusRange(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDefaultRulesCompile(t *testing.T) {
+	rules, err := DefaultRules()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) == 0 {
+		t.Fatal("没有内置规则")
+	}
+	for i := 1; i < len(rules); i++ {
+		if rules[i-1].Priority > rules[i].Priority {
+			t.Errorf("规则没有按优先级排序: %s(%d) 在 %s(%d) 之前",
+				rules[i-1].ID, rules[i-1].Priority, rules[i].ID, rules[i].Priority)
+		}
+	}
+}
+
+func TestLoadRulesOverrideAndDisable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.yaml")
+	userRules := `
+rules:
+  - id: E1
+    priority: 1
+    when:
+      - status: ["418"]
+  - id: X1
+    priority: 5
+    when:
+      - title: {contains: ["custom"]}
+`
+	if err := os.WriteFile(path, []byte(userRules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := LoadRules(path, []string{"L1", " W1 "})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byID := make(map[string]*Rule)
+	for _, r := range rules {
+		byID[r.ID] = r
+	}
+	if byID["L1"] != nil || byID["W1"] != nil {
+		t.Error("-disable-rules 中的规则不应加载")
+	}
+	if byID["X1"] == nil {
+		t.Fatal("用户新增的规则没有加载")
+	}
+	if e1 := byID["E1"]; e1 == nil || e1.Priority != 1 || e1.ClusterPrefix != "e1" || e1.Scope != RuleScopeOrigin {
+		t.Errorf("同 id 的用户规则应替换内置规则并填充默认值: %+v", e1)
+	}
+}
+
+func TestLoadRulesInvalid(t *testing.T) {
+	tests := map[string]string{
+		"缺少 id":    "rules:\n  - priority: 1\n",
+		"状态码无效":    "rules:\n  - id: B1\n    when:\n      - status: [\"abc\"]\n",
+		"正则无效":     "rules:\n  - id: B1\n    when:\n      - body: {regex: [\"(\"]}\n",
+		"YAML 格式错": "rules: [",
+	}
+	for name, content := range tests {
+		path := filepath.Join(t.TempDir(), "rules.yaml")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadRules(path, nil); err == nil {
+			t.Errorf("%s: 应该报错", name)
+		}
+	}
+}
+
+// compileTestRule 从 YAML 编译单条规则
+func compileTestRule(t *testing.T, yamlText string) *Rule {
+	t.Helper()
+	file, err := parseRuleFile([]byte(yamlText))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := compileRules(file.Rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rules[0]
+}
+
+func TestRuleConditionMatches(t *testing.T) {
+	rule := compileTestRule(t, `
+rules:
+  - id: T1
+    when:
+      - status: ["4xx"]
+        html: true
+        title: {contains: ["Not Found"]}
+      - path: {regex: ["^/admin"]}
+        headers:
+          server: {contains: ["nginx"]}
+`)
+
+	page := func(status int, title, path, server string) *FetchResult {
+		fr := &FetchResult{
+			StatusCode:      status,
+			FinalURL:        "https://a.com" + path,
+			ContentCategory: ContentCategoryHTML,
+			RawHTML:         []byte("<html><title>" + title + "</title></html>"),
+			Title:           title,
+			Headers:         map[string]string{"server": server},
+		}
+		return fr
+	}
+
+	tests := []struct {
+		name string
+		fr   *FetchResult
+		want bool
+	}{
+		{"第一个条件全部满足", page(404, "404 not found", "/x", "apache"), true},
+		{"状态码不满足", page(200, "Not Found", "/x", "apache"), false},
+		{"第二个条件满足", page(200, "Home", "/admin/login", "nginx/1.25"), true},
+		{"第二个条件响应头不满足", page(200, "Home", "/admin/login", "apache"), false},
+	}
+	for _, tt := range tests {
+		PrepareRuleMatches(tt.fr, []*Rule{rule})
+		got := len(tt.fr.MatchedRules) == 1
+		if got != tt.want {
+			t.Errorf("%s: 命中 = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestApplyRuleGroupsByFullHash(t *testing.T) {
+	rule := compileTestRule(t, `
+rules:
+  - id: G1
+    group_by: fingerprint
+`)
+
+	// 两个模板的指纹低 16 位相同，必须分成两组，且 cluster ID 不同
+	hashes := []uint64{0x1111_0000_0000_abcd, 0x2222_0000_0000_abcd}
+	var infos []perURLInfo
+	id := 0
+	for _, h := range hashes {
+		for i := 0; i < 2; i++ {
+			id++
+			infos = append(infos, perURLInfo{
+				FR:      FetchResult{URLItem: URLItem{ID: id}, FinalURL: "https://a.com/p" + strings.Repeat("x", id)},
+				Origin:  "https://a.com:443",
+				HtmlFP:  HtmlFingerprint{Length: 100, Hash: h},
+				IsHTML:  true,
+				Matched: map[string]bool{"G1": true},
+			})
+		}
+	}
+
+	assignments := make(map[int]RuleAssignment)
+	applyRule(rule, infos, assignments, make(map[int][]RuleAnnotation))
+
+	if len(assignments) != 4 {
+		t.Fatalf("分配数 = %d, want 4", len(assignments))
+	}
+	if assignments[1].ClusterID != assignments[2].ClusterID || assignments[3].ClusterID != assignments[4].ClusterID {
+		t.Errorf("同一指纹应在同一 cluster: %+v", assignments)
+	}
+	if assignments[1].ClusterID == assignments[3].ClusterID {
+		t.Errorf("低 16 位相同的不同指纹不应合并: %s", assignments[1].ClusterID)
+	}
+	// 排在前面的分组仍然只显示低 16 位
+	if want := "g1-https___acom_443-abcd"; assignments[1].ClusterID != want {
+		t.Errorf("ClusterID = %s, want %s", assignments[1].ClusterID, want)
+	}
+}
+
+func TestDefaultRulesFaviconAnnotation(t *testing.T) {
+	rules, err := LoadRules("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert := &CertInfo{SHA256: strings.Repeat("ab", 32), SubjectCN: "a.example.com", SANs: []string{"a.example.com"}}
+	favicon := &FaviconInfo{MMH3: 116323821}
+
+	home := func(id int, u string, c *CertInfo) FetchResult {
+		return FetchResult{
+			URLItem:         URLItem{ID: id, NormalizedURL: u},
+			FinalURL:        u,
+			StatusCode:      200,
+			ContentCategory: ContentCategoryHTML,
+			RawHTML:         []byte(fmt.Sprintf("<html><body>home %d</body></html>", id)),
+			Cert:            c,
+			Favicon:         favicon,
+		}
+	}
+	results := []FetchResult{
+		home(1, "https://1.1.1.1/", cert),
+		home(2, "https://a.example.com/", cert),
+		home(3, "http://x.example.org/", nil),
+		home(4, "http://y.example.org/", nil),
+	}
+
+	assignments, annotations := BuildRuleAssignments(results, rules)
+
+	if a1, a2 := assignments[1], assignments[2]; a1.RuleID != "C1" || a1.ClusterID != a2.ClusterID {
+		t.Errorf("共用同一张证书的首页应由 C1 归并: %+v %+v", a1, a2)
+	}
+
+	// F1 只标注，不管 URL 是否已被 C1 分配
+	for id := 1; id <= 4; id++ {
+		got := annotations[id]
+		if len(got) != 1 || got[0].RuleID != "F1" || got[0].ClusterID != "favicon-116323821" {
+			t.Errorf("URL %d 的标注 = %+v，期望 F1 favicon-116323821", id, got)
+		}
+		if assignments[id].RuleID == "F1" {
+			t.Errorf("F1 不应分配 cluster: %+v", assignments[id])
+		}
+	}
+}
+
+func TestDefaultRulesSkipSharedCert(t *testing.T) {
+	rules, err := LoadRules("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wildcard := &CertInfo{SHA256: strings.Repeat("cd", 32), SubjectCN: "*.cdn.example", SANs: []string{"*.cdn.example"}}
+	results := []FetchResult{
+		{URLItem: URLItem{ID: 1, NormalizedURL: "https://x.cdn.example/"}, FinalURL: "https://x.cdn.example/", StatusCode: 200,
+			ContentCategory: ContentCategoryHTML, RawHTML: []byte("<html><body>x</body></html>"), Cert: wildcard},
+		{URLItem: URLItem{ID: 2, NormalizedURL: "https://y.cdn.example/"}, FinalURL: "https://y.cdn.example/", StatusCode: 200,
+			ContentCategory: ContentCategoryHTML, RawHTML: []byte("<html><body>y</body></html>"), Cert: wildcard},
+	}
+
+	assignments, _ := BuildRuleAssignments(results, rules)
+	for id, a := range assignments {
+		if a.RuleID == "C1" {
+			t.Errorf("通配符证书不应由 C1 归并: URL %d %+v", id, a)
+		}
+	}
+}
@@ -11,9 +11,26 @@ func Run(ctx context.Context, opts Options) (*FullReport, error) {
 	logger := GetLogger()
 	logger.Info("开始处理，共 %d 个 URL 输入源", len(opts.URLs))
 
+	// 未指定阈值时使用默认值
+	if opts.Thresholds == (Thresholds{}) {
+		opts.Thresholds = DefaultThresholds()
+	}
+	if err := opts.Thresholds.Validate(); err != nil {
+		return nil, fmt.Errorf("阈值配置不合法: %w", err)
+	}
+
+	// 未指定规则时使用内置规则
+	if opts.Rules == nil {
+		rules, err := DefaultRules()
+		if err != nil {
+			return nil, err
+		}
+		opts.Rules = rules
+	}
+
 	var allItems []URLItem
 	for _, urlInput := range opts.URLs {
-		items, err := LoadURLs(urlInput)
+		items, err := LoadURLs(urlInput, opts.Input)
 		if err != nil {
 			return nil, fmt.Errorf("加载 URL 失败 (%s): %w", urlInput, err)
 		}
@@ -28,20 +45,128 @@ func Run(ctx context.Context, opts Options) (*FullReport, error) {
 		return nil, fmt.Errorf("没有有效的 URL 输入")
 	}
 	logger.Info("加载完成，共 %d 个 URL", len(items))
+	vhostMode := len(opts.VHosts) > 0
+	// 上游代理自己解析主机名，虚拟主机没法连到指定的 IP
+	if vhostMode && len(opts.Proxies) > 0 {
+		return nil, fmt.Errorf("虚拟主机扫描不支持上游代理: 代理会按 DNS 解析主机名，无法连到指定的 IP")
+	}
+
+	// 主机/端口/CIDR 展开后重复的地址只保留一个
+	items, expandedDups := dedupeExpanded(items)
+	if expandedDups > 0 {
+		logger.Info("展开后合并 %d 个重复地址", expandedDups)
+	}
+
+	proxies, err := NewProxyPool(opts.Proxies)
+	if err != nil {
+		return nil, err
+	}
+	if proxies != nil {
+		logger.Info("使用 %d 个上游代理: %s", proxies.Len(), proxies)
+	}
 
-	fetcher := NewFetcher(opts.HTTPTimeout, MaxRedirects)
-	renderer, err := NewRenderer(ctx, opts.PerPageTimeout, opts.RenderParallel)
+	request, err := NewRequestProfile(opts.Request)
 	if err != nil {
-		return nil, fmt.Errorf("创建渲染器失败: %w", err)
+		return nil, err
+	}
+	if len(request.Cookies) > 0 {
+		logger.Info("加载 %d 个 cookie", len(request.Cookies))
+	}
+
+	retry, err := NewRetryPolicy(opts.Retry)
+	if err != nil {
+		return nil, fmt.Errorf("重试配置不合法: %w", err)
+	}
+
+	// 抓取和渲染共用一个按 host 的调度器
+	scheduler := NewHostScheduler(SchedulerConfig{
+		HostConcurrency: opts.HostConcurrency,
+		HostRPS:         opts.HostRPS,
+		Jitter:          opts.HostJitter,
+		MaxBackoff:      opts.MaxBackoff,
+	})
+
+	fetcher := NewFetcher(FetcherConfig{
+		Timeout:      opts.HTTPTimeout,
+		MaxRedirects: MaxRedirects,
+		Proxies:      proxies,
+		Request:      request,
+		Scheduler:    scheduler,
+		Retry:        retry,
+		VHostMode:    vhostMode,
+	})
+
+	// 没有写 scheme 的输入探测 http/https，只保留有响应的；都无响应的地址直接记为抓取失败
+	items, unreachable := ProbeSchemes(ctx, fetcher, items, opts.Input.SchemeMode, opts.Input.Canonical, opts.Parallel)
+	if len(items) == 0 && len(unreachable) == 0 {
+		return nil, fmt.Errorf("没有有响应的 URL")
+	}
+
+	// 规范化后相同的 URL 只抓取一次
+	items, collapsed := DedupeURLs(items)
+	logger.Info("输入去重：合并 %d 个规范化后相同的 URL，剩余 %d 个", collapsed, len(items))
+
+	// 从每个 origin 的 robots.txt 和 sitemap 发现更多 URL
+	var seeded int
+	if opts.SeedPerOrigin > 0 {
+		items, seeded = SeedFromSitemaps(ctx, fetcher, items, opts.SeedPerOrigin, opts.Input.Canonical, opts.Parallel)
+	}
+	for i := range items {
+		items[i].ID = i + 1
+	}
+
+	// 虚拟主机扫描：每个 IP 输入按候选主机名展开
+	if vhostMode {
+		items = ExpandVHosts(items, opts.VHosts)
+		logger.Info("虚拟主机扫描：%d 个候选主机名，展开后共 %d 个 URL", len(opts.VHosts), len(items))
+		if opts.ChromeURL != "" && !opts.NoRender {
+			logger.Warn("远程 Chrome 不支持虚拟主机映射，渲染时主机名按 DNS 解析")
+		}
+	}
+
+	// 软 404 预探测：每个 origin 请求随机不存在的路径，建立基线
+	var softNotFound *SoftNotFoundDetector
+	if opts.SoftNotFoundProbes > 0 {
+		softNotFound = ProbeSoftNotFound(ctx, fetcher, items, opts.SoftNotFoundProbes, opts.Parallel, opts.Thresholds.TextSimHashMaxDist)
+	}
+	// favicon 按地址缓存，所有批次共用
+	var favicons *FaviconResolver
+	if !opts.NoFavicon {
+		favicons = NewFaviconResolver(fetcher, opts.Parallel)
+	}
+
+	// HTTP-only 模式不启动浏览器，HTML 特征从原始 HTML 静态提取
+	var renderer *Renderer
+	var staticPool chan struct{}
+	if opts.NoRender {
+		logger.Info("HTTP-only 模式：跳过 headless Chrome，视觉和行为维度不可用")
+		staticPool = make(chan struct{}, maxInt(opts.RenderParallel, 1))
+	} else {
+		var err error
+		renderer, err = NewRenderer(ctx, RendererConfig{
+			PerPageTimeout: opts.PerPageTimeout,
+			MaxWorkers:     opts.RenderParallel,
+			RemoteURL:      opts.ChromeURL,
+			ExecPath:       opts.ChromePath,
+			ExtraFlags:     opts.ChromeFlags,
+			RecycleAfter:   opts.RecyclePages,
+			MaxRSSMB:       opts.MaxChromeRSS,
+			Proxies:        proxies,
+			Request:        request,
+			Scheduler:      scheduler,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("创建渲染器失败: %w", err)
+		}
+		defer renderer.Close()
 	}
-	defer renderer.Close()
 
 	fetchResults := make([]FetchResult, 0, len(items))
 	pagesWithFeatures := make([]*PageWithFeatures, 0)
 
 	batchSize := opts.BatchSize
 	if batchSize <= 0 {
-		batchSize = len(items)
+		batchSize = maxInt(len(items), 1)
 	}
 
 	totalBatches := (len(items) + batchSize - 1) / batchSize
@@ -67,6 +192,25 @@ func Run(ctx context.Context, opts Options) (*FullReport, error) {
 		batchFetchResults := fetcher.FetchBatch(ctx, batchItems, opts.Parallel)
 		logger.Info("HTTP 抓取完成，本批 %d 个结果", len(batchFetchResults))
 
+		// 标记软 404（不参与内容聚类，交给规则聚类）
+		if softNotFound != nil {
+			softCount := 0
+			for i := range batchFetchResults {
+				if softNotFound.Mark(&batchFetchResults[i]) {
+					softCount++
+				}
+			}
+			if softCount > 0 {
+				logger.Info("本批发现 %d 个软 404", softCount)
+			}
+		}
+
+		// 解析并下载 favicon（需要原始 HTML 中的 <link rel="icon">）
+		if favicons != nil {
+			n := favicons.Resolve(ctx, batchFetchResults)
+			logger.Info("本批 %d 个 URL 得到 favicon", n)
+		}
+
 		// 分类：HTML 需要渲染，非 HTML 直接提取特征
 		var batchEligibleHTML []FetchResult
 		var batchEligibleNonHTML []FetchResult
@@ -88,6 +232,7 @@ func Run(ctx context.Context, opts Options) (*FullReport, error) {
 			if features == nil {
 				continue
 			}
+			setHeaderFeatures(features, &fr)
 
 			// 根据内容类型使用不同的最小阈值
 			eligible := false
@@ -115,78 +260,122 @@ func Run(ctx context.Context, opts Options) (*FullReport, error) {
 
 		var wg sync.WaitGroup
 		cancelled := false
-		titleUpdates := make(map[int]string)
-		var titleMu sync.Mutex
+		renderUpdates := make(map[int]renderUpdate)
+		var renderMu sync.Mutex
 
-		// 处理 HTML 页面（需要渲染）
-		for idx, fr := range batchEligibleHTML {
-			select {
-			case <-ctx.Done():
-				logger.Warn("渲染被取消，跳过剩余任务")
-				cancelled = true
-			default:
-			}
+		// 虚拟主机扫描时按主机名映射分组渲染，每组使用各自的 host 映射
+		renderGroups := [][]FetchResult{batchEligibleHTML}
+		if vhostMode && renderer != nil {
+			renderGroups = groupByResolverMapping(batchEligibleHTML)
+		}
 
+		// 处理 HTML 页面（需要渲染）
+		idx := 0
+		for _, group := range renderGroups {
 			if cancelled {
 				break
 			}
-
-			wg.Add(1)
-			go func(fr FetchResult, pos int) {
-				defer wg.Done()
-
-				defer func() {
-					if r := recover(); r != nil {
-						logger.Error("渲染 panic (URL %d, %s): %v", fr.ID, fr.FinalURL, r)
-					}
-				}()
-
-				logger.Progress(start+pos+1, len(items), "渲染中")
-
-				features, renderedTitle, err := renderer.ExtractFeatures(ctx, fr.FinalURL)
-				if err != nil {
-					logger.Debug("渲染失败 (URL %d, %s): %v", fr.ID, fr.FinalURL, err)
-					features = nil
+			// 没有虚拟主机的组也要设置（清空上一组的映射）
+			if vhostMode && renderer != nil {
+				if err := renderer.SetHostResolverRules(hostResolverRules(group)); err != nil {
+					logger.Error("%v", err)
 				}
+			}
 
-				if renderedTitle != "" {
-					titleMu.Lock()
-					titleUpdates[fr.ID] = renderedTitle
-					titleMu.Unlock()
-					fr.Title = renderedTitle
+			for _, fr := range group {
+				select {
+				case <-ctx.Done():
+					logger.Warn("渲染被取消，跳过剩余任务")
+					cancelled = true
+				default:
 				}
 
-				if features != nil && features.TextLength < MinTextLength {
-					features = nil
+				if cancelled {
+					break
 				}
 
-				mu.Lock()
-				pagesWithFeatures = append(pagesWithFeatures, &PageWithFeatures{
-					FetchResult: fr,
-					Features:    features,
-				})
-				mu.Unlock()
-			}(fr, idx)
-		}
+				wg.Add(1)
+				go func(fr FetchResult, pos int) {
+					defer wg.Done()
+
+					defer func() {
+						if r := recover(); r != nil {
+							logger.Error("渲染 panic (URL %d, %s): %v", fr.ID, fr.FinalURL, r)
+						}
+					}()
+
+					logger.Progress(start+pos+1, len(items), "渲染中")
+
+					var features *PageFeatures
+					var info RenderInfo
+					var err error
+					attempts := 0
+					if renderer != nil {
+						// 渲染失败按重试策略重试（浏览器崩溃导致的失败在 Renderer 内部已经重启重试过一次）
+						attempts = retry.Do(ctx, func(attempt int) bool {
+							features, info, err = renderer.ExtractFeatures(ctx, fr.FinalURL, fr.connectURLOf(fr.FinalURL))
+							if err != nil {
+								logger.Debug("渲染失败 (URL %d, %s, 第 %d 次): %v", fr.ID, fr.FinalURL, attempt, err)
+							}
+							return err != nil && ctx.Err() == nil && retry.RetryableClass(ClassifyRenderError(err))
+						})
+					} else {
+						staticPool <- struct{}{}
+						features, err = ExtractStaticHTMLFeatures(fr.RawHTML)
+						<-staticPool
+					}
+					if err != nil {
+						logger.Debug("特征提取失败 (URL %d, %s): %v", fr.ID, fr.FinalURL, err)
+						features = nil
+					}
 
-		wg.Wait()
+					update := renderUpdate{Title: info.Title, ClientHops: info.ClientHops, Attempts: attempts}
+					if err != nil {
+						update.Error = err.Error()
+						update.ErrorClass = ClassifyRenderError(err)
+					}
+					renderMu.Lock()
+					renderUpdates[fr.ID] = update
+					renderMu.Unlock()
+					update.apply(&fr)
 
-		titleMu.Lock()
+					if features != nil && features.TextLength < MinTextLength {
+						features = nil
+					}
+					setHeaderFeatures(features, &fr)
+
+					mu.Lock()
+					pagesWithFeatures = append(pagesWithFeatures, &PageWithFeatures{
+						FetchResult: fr,
+						Features:    features,
+					})
+					mu.Unlock()
+				}(fr, idx)
+				idx++
+			}
+
+			// 同一组渲染完再切换映射
+			wg.Wait()
+		}
+
+		renderMu.Lock()
 		for i := range batchFetchResults {
-			if updatedTitle, ok := titleUpdates[batchFetchResults[i].ID]; ok {
-				batchFetchResults[i].Title = updatedTitle
+			if update, ok := renderUpdates[batchFetchResults[i].ID]; ok {
+				update.apply(&batchFetchResults[i])
 			}
 		}
-		titleMu.Unlock()
+		renderMu.Unlock()
 
 		if cancelled {
 			logger.Info("本批渲染被取消，继续处理下一批")
 		}
 
-		// 清理原始内容，释放内存
+		// 清理原始内容前先计算规则聚类需要的指纹和规则命中情况，再释放内存
 		for i := range batchFetchResults {
+			PrepareRuleMatches(&batchFetchResults[i], opts.Rules)
 			batchFetchResults[i].RawHTML = nil
 			batchFetchResults[i].RawBody = nil
+			batchFetchResults[i].OriginalBody = nil
 		}
 		fetchResults = append(fetchResults, batchFetchResults...)
 
@@ -195,16 +384,41 @@ func Run(ctx context.Context, opts Options) (*FullReport, error) {
 
 	logger.Info("所有批次处理完成")
 
+	// 协议探测无响应的地址排在最后，ID 接着已抓取的 URL 编号
+	for i := range unreachable {
+		unreachable[i].ID = len(items) + i + 1
+		PrepareRuleMatches(&unreachable[i], opts.Rules)
+	}
+	fetchResults = append(fetchResults, unreachable...)
+
 	logger.Info("开始全局聚类...")
-	contentClusters := Cluster(pagesWithFeatures)
-	logger.Info("内容聚类完成，生成 %d 个 cluster", len(contentClusters))
+	contentClusters, clusterStats := Cluster(pagesWithFeatures, opts)
+	logger.Info("内容聚类完成，比较 %d 个候选对，生成 %d 个 cluster", clusterStats.CandidatePairs, len(contentClusters))
+	if clusterStats.CappedBuckets > 0 {
+		logger.Warn("%d 个 LSH 桶成员超过 %d 个，只比较了每个桶的第一个成员，桶内其他页面对可能漏判", clusterStats.CappedBuckets, MaxLSHBucketSize)
+	}
 
 	logger.Info("开始规则聚类...")
-	ruleAssignments := BuildRuleAssignments(fetchResults)
-	logger.Info("规则聚类完成，分配 %d 个 URL", len(ruleAssignments))
+	ruleAssignments, ruleAnnotations := BuildRuleAssignments(fetchResults, opts.Rules)
+	logger.Info("规则聚类完成，分配 %d 个 URL，标注 %d 个 URL", len(ruleAssignments), len(ruleAnnotations))
 
 	logger.Info("构建报告...")
-	report := BuildReport(fetchResults, pagesWithFeatures, contentClusters, ruleAssignments, opts)
+	report := BuildReport(fetchResults, pagesWithFeatures, contentClusters, clusterStats, ruleAssignments, ruleAnnotations, opts)
+	report.Meta.ExpandedDuplicates = expandedDups
+	report.Meta.ProbeDropped = len(unreachable)
+	report.Meta.CollapsedURLs = collapsed
+	report.Meta.SeededURLs = seeded
+	report.Meta.ThrottleEvents = scheduler.ThrottleEvents()
+	if report.Meta.ThrottleEvents > 0 {
+		logger.Warn("检测到 %d 次限流/WAF 拦截，已自动退避", report.Meta.ThrottleEvents)
+	}
+	if renderer != nil {
+		report.Meta.RendererRestarts = renderer.Restarts()
+		report.Meta.RendererRecycles = renderer.Recycles()
+		if report.Meta.RendererRestarts > 0 {
+			logger.Warn("渲染过程中浏览器重启 %d 次", report.Meta.RendererRestarts)
+		}
+	}
 
 	logger.Info("完成！共处理 %d 个 URL，其中 %d 个可判定的 HTML 页面，生成 %d 个聚类",
 		report.Meta.TotalURLs,
@@ -215,12 +429,45 @@ func Run(ctx context.Context, opts Options) (*FullReport, error) {
 	return report, nil
 }
 
+// renderUpdate 渲染阶段需要回写到抓取结果的信息
+type renderUpdate struct {
+	Title      string        // 渲染后的标题，为空时保留 HTTP 阶段提取的标题
+	ClientHops []RedirectHop // 浏览器中的 meta refresh、JS 跳转，追加到重定向链
+	Attempts   int
+	Error      string
+	ErrorClass ErrorClass
+}
+
+// apply 回写到抓取结果
+func (u renderUpdate) apply(fr *FetchResult) {
+	if u.Title != "" {
+		fr.Title = u.Title
+	}
+	if len(u.ClientHops) > 0 {
+		for _, hop := range u.ClientHops {
+			fr.RedirectChain = append(fr.RedirectChain, hop.URL)
+			fr.RedirectHops = append(fr.RedirectHops, hop)
+		}
+		// 规则 R1 按浏览器最终停留的 URL 归并
+		fr.FinalURL = u.ClientHops[len(u.ClientHops)-1].URL
+	}
+	fr.RenderAttempts = u.Attempts
+	fr.RenderError = u.Error
+	if u.ErrorClass != ErrorClassNone && fr.ErrorClass == ErrorClassNone {
+		fr.ErrorClass = u.ErrorClass
+	}
+}
+
 // isEligibleHTML 判断是否为可判定的 HTML 页面
 func isEligibleHTML(result FetchResult) bool {
 	if result.StatusCode < 200 || result.StatusCode >= 300 {
 		return false
 	}
 
+	if result.SoftNotFound {
+		return false
+	}
+
 	if result.ContentCategory != ContentCategoryHTML {
 		return false
 	}
@@ -238,6 +485,10 @@ func isEligibleNonHTML(result FetchResult) bool {
 		return false
 	}
 
+	if result.SoftNotFound {
+		return false
+	}
+
 	switch result.ContentCategory {
 	case ContentCategoryText:
 		return len(result.RawBody) >= MinTextSize
@@ -0,0 +1,247 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 自适应退避相关常量
+const (
+	DefaultHostConcurrency = 5                // 默认每个 host 的最大并发
+	DefaultHostJitter      = 0.2              // 默认请求间隔随机抖动比例
+	DefaultMaxBackoff      = 60 * time.Second // 默认最大退避时间
+	minBackoff             = 1 * time.Second  // 第一次被限流时的退避时间
+	wafSniffLength         = 64 * 1024        // WAF 特征只在响应开头查找
+)
+
+// wafChallengeMarkers WAF 拦截/质询页的特征（小写）
+// 只收录拦截页特有的标记，避免正常页面里出现 cloudflare CDN 等字样时误触发退避
+var wafChallengeMarkers = [][]byte{
+	[]byte("cf-chl-"),
+	[]byte("/cdn-cgi/challenge-platform/"),
+	[]byte("attention required! | cloudflare"),
+	[]byte("checking your browser before accessing"),
+	[]byte("_incapsula_resource"),
+	[]byte("request unsuccessful. incapsula incident"),
+	[]byte("errors.aliyun.com"),
+	[]byte("安全狗"),
+	[]byte("当前访问疑似黑客攻击"),
+	[]byte("您的请求带有不合法参数"),
+	[]byte("yunsuo_session_verify"),
+}
+
+// SchedulerConfig 按 host 调度的限速配置
+type SchedulerConfig struct {
+	HostConcurrency int           // 每个 host 的最大并发，0 表示不限制
+	HostRPS         float64       // 每个 host 每秒最多发起的请求数，0 表示不限制
+	Jitter          float64       // 请求间隔的随机抖动比例（0-1）
+	MaxBackoff      time.Duration // 被限流（429/503/WAF）后的最大退避时间，0 表示不退避
+}
+
+// HostScheduler 按 host 的并发、速率和自适应退避调度
+// Fetcher 和 Renderer 共用同一个调度器，两个阶段对同一个 host 的访问合并计算
+type HostScheduler struct {
+	cfg   SchedulerConfig
+	mu    sync.Mutex
+	hosts map[string]*hostState
+
+	throttled atomic.Int64 // 检测到限流的次数
+}
+
+// hostState 单个 host 的调度状态
+type hostState struct {
+	sem          chan struct{} // nil 表示不限并发
+	next         time.Time     // 下一个请求最早的开始时间
+	backoff      time.Duration // 当前退避时间
+	blockedUntil time.Time     // 退避结束时间
+}
+
+// NewHostScheduler 创建调度器，所有限制都关闭时返回 nil
+func NewHostScheduler(cfg SchedulerConfig) *HostScheduler {
+	if cfg.HostConcurrency <= 0 && cfg.HostRPS <= 0 && cfg.MaxBackoff <= 0 {
+		return nil
+	}
+	if cfg.Jitter < 0 {
+		cfg.Jitter = 0
+	}
+	return &HostScheduler{
+		cfg:   cfg,
+		hosts: make(map[string]*hostState),
+	}
+}
+
+// schedulerHostKey 调度使用的 host（不含端口，同一主机不同端口共享限额）
+func schedulerHostKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// state 获取 host 的调度状态
+func (s *HostScheduler) state(host string) *hostState {
+	st, ok := s.hosts[host]
+	if !ok {
+		st = &hostState{}
+		if s.cfg.HostConcurrency > 0 {
+			st.sem = make(chan struct{}, s.cfg.HostConcurrency)
+		}
+		s.hosts[host] = st
+	}
+	return st
+}
+
+// Acquire 等待可以向 rawURL 的 host 发起请求，返回释放函数
+// 依次等待 host 并发名额、速率间隔和退避结束；ctx 取消时返回错误
+func (s *HostScheduler) Acquire(ctx context.Context, rawURL string) (func(), error) {
+	if s == nil {
+		return func() {}, nil
+	}
+
+	host := schedulerHostKey(rawURL)
+
+	s.mu.Lock()
+	st := s.state(host)
+	s.mu.Unlock()
+
+	release := func() {}
+	if st.sem != nil {
+		select {
+		case st.sem <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		release = func() { <-st.sem }
+	}
+
+	// 预约开始时间：不早于上一个请求的间隔，也不早于退避结束
+	s.mu.Lock()
+	now := time.Now()
+	start := now
+	if st.next.After(start) {
+		start = st.next
+	}
+	if st.blockedUntil.After(start) {
+		start = st.blockedUntil
+	}
+	if s.cfg.HostRPS > 0 {
+		interval := time.Duration(float64(time.Second) / s.cfg.HostRPS)
+		if s.cfg.Jitter > 0 {
+			interval += time.Duration(rand.Float64() * s.cfg.Jitter * float64(interval))
+		}
+		st.next = start.Add(interval)
+	}
+	s.mu.Unlock()
+
+	if wait := time.Until(start); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+
+	return release, nil
+}
+
+// Observe 根据响应调整 host 的退避
+// 429/503 或 WAF 拦截页时指数退避（尊重 Retry-After），正常响应时退避减半
+func (s *HostScheduler) Observe(rawURL string, statusCode int, header http.Header, body []byte) {
+	if s == nil || s.cfg.MaxBackoff <= 0 {
+		return
+	}
+
+	host := schedulerHostKey(rawURL)
+	throttled := isThrottleResponse(statusCode, body)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.state(host)
+	if !throttled {
+		st.backoff /= 2
+		if st.backoff < minBackoff {
+			st.backoff = 0
+		}
+		return
+	}
+
+	if st.backoff < minBackoff {
+		st.backoff = minBackoff
+	} else {
+		st.backoff *= 2
+	}
+	if retryAfter := parseRetryAfter(header); retryAfter > st.backoff {
+		st.backoff = retryAfter
+	}
+	if st.backoff > s.cfg.MaxBackoff {
+		st.backoff = s.cfg.MaxBackoff
+	}
+
+	until := time.Now().Add(st.backoff)
+	if until.After(st.blockedUntil) {
+		st.blockedUntil = until
+	}
+
+	n := s.throttled.Add(1)
+	GetLogger().Debug("%s 触发限流（状态码 %d），退避 %v（累计 %d 次）", host, statusCode, st.backoff, n)
+}
+
+// ThrottleEvents 检测到限流的次数
+func (s *HostScheduler) ThrottleEvents() int {
+	if s == nil {
+		return 0
+	}
+	return int(s.throttled.Load())
+}
+
+// isThrottleResponse 判断响应是否为限流或 WAF 拦截
+func isThrottleResponse(statusCode int, body []byte) bool {
+	if statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable {
+		return true
+	}
+	if len(body) == 0 {
+		return false
+	}
+
+	if len(body) > wafSniffLength {
+		body = body[:wafSniffLength]
+	}
+	lower := bytes.ToLower(body)
+	for _, marker := range wafChallengeMarkers {
+		if bytes.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRetryAfter 解析 Retry-After（秒数或 HTTP 日期）
+func parseRetryAfter(header http.Header) time.Duration {
+	if header == nil {
+		return 0
+	}
+	v := strings.TrimSpace(header.Get("Retry-After"))
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
@@ -0,0 +1,154 @@
+package internal
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewHostSchedulerDisabled(t *testing.T) {
+	if s := NewHostScheduler(SchedulerConfig{}); s != nil {
+		t.Error("所有限制都关闭时应返回 nil")
+	}
+	var s *HostScheduler
+	release, err := s.Acquire(context.Background(), "http://a.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	s.Observe("http://a.com/", 429, nil, nil)
+	if s.ThrottleEvents() != 0 {
+		t.Error("nil 调度器不应记录限流")
+	}
+}
+
+func TestHostSchedulerConcurrency(t *testing.T) {
+	s := NewHostScheduler(SchedulerConfig{HostConcurrency: 1})
+
+	release, err := s.Acquire(context.Background(), "http://a.com:8080/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 同一主机的其他端口共享并发名额
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := s.Acquire(ctx, "http://A.com/y"); err == nil {
+		t.Error("并发名额用完时应等待直到 ctx 超时")
+	}
+
+	// 其他主机不受影响
+	other, err := s.Acquire(context.Background(), "http://b.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other()
+
+	release()
+	again, err := s.Acquire(context.Background(), "http://a.com/")
+	if err != nil {
+		t.Fatalf("释放后应能再次获取: %v", err)
+	}
+	again()
+}
+
+func TestHostSchedulerRPS(t *testing.T) {
+	s := NewHostScheduler(SchedulerConfig{HostRPS: 20})
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		release, err := s.Acquire(context.Background(), "http://a.com/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		release()
+	}
+	// 20 rps 间隔 50ms，第三个请求至少在 100ms 之后开始
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("3 个请求耗时 %v，期望至少 100ms", elapsed)
+	}
+}
+
+func TestHostSchedulerBackoff(t *testing.T) {
+	s := NewHostScheduler(SchedulerConfig{MaxBackoff: 3 * time.Second})
+
+	s.Observe("http://a.com/", 429, nil, nil)
+	if got := s.hosts["a.com"].backoff; got != minBackoff {
+		t.Errorf("第一次限流退避 = %v，期望 %v", got, minBackoff)
+	}
+	s.Observe("http://a.com/", 503, nil, nil)
+	if got := s.hosts["a.com"].backoff; got != 2*time.Second {
+		t.Errorf("第二次限流退避 = %v，期望 2s", got)
+	}
+	s.Observe("http://a.com/", 429, nil, nil)
+	if got := s.hosts["a.com"].backoff; got != 3*time.Second {
+		t.Errorf("退避应不超过 MaxBackoff，实际 %v", got)
+	}
+	if s.ThrottleEvents() != 3 {
+		t.Errorf("ThrottleEvents = %d，期望 3", s.ThrottleEvents())
+	}
+
+	// 被限流期间获取名额要等待退避结束
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := s.Acquire(ctx, "http://a.com/"); err == nil {
+		t.Error("退避期间应等待")
+	}
+
+	// 正常响应后退避减半，低于 1s 时清零
+	s.Observe("http://a.com/", 200, nil, []byte("ok"))
+	if got := s.hosts["a.com"].backoff; got != 1500*time.Millisecond {
+		t.Errorf("正常响应后退避 = %v，期望 1.5s", got)
+	}
+	s.Observe("http://a.com/", 200, nil, nil)
+	if got := s.hosts["a.com"].backoff; got != 0 {
+		t.Errorf("退避低于 1s 应清零，实际 %v", got)
+	}
+}
+
+func TestHostSchedulerRetryAfter(t *testing.T) {
+	s := NewHostScheduler(SchedulerConfig{MaxBackoff: time.Minute})
+	s.Observe("http://a.com/", 429, http.Header{"Retry-After": []string{"7"}}, nil)
+	if got := s.hosts["a.com"].backoff; got != 7*time.Second {
+		t.Errorf("退避 = %v，期望按 Retry-After 为 7s", got)
+	}
+}
+
+func TestIsThrottleResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   bool
+	}{
+		{"429", 429, "", true},
+		{"503", 503, "", true},
+		{"正常页面", 200, "<html>hello</html>", false},
+		{"正常页面引用 cloudflare CDN", 200, `<script src="https://cdnjs.cloudflare.com/x.js"></script>`, false},
+		{"Cloudflare 质询页", 403, `<title>Attention Required! | Cloudflare</title>`, true},
+		{"安全狗拦截页", 200, "<html>网站防火墙 安全狗</html>", true},
+	}
+	for _, tt := range tests {
+		if got := isThrottleResponse(tt.status, []byte(tt.body)); got != tt.want {
+			t.Errorf("%s: isThrottleResponse = %v，期望 %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if got := parseRetryAfter(http.Header{"Retry-After": []string{"120"}}); got != 2*time.Minute {
+		t.Errorf("秒数 Retry-After = %v", got)
+	}
+	future := time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(http.Header{"Retry-After": []string{future}}); got < 25*time.Second || got > 30*time.Second {
+		t.Errorf("日期 Retry-After = %v，期望约 30s", got)
+	}
+	for _, v := range []string{"", "abc", "-5"} {
+		if got := parseRetryAfter(http.Header{"Retry-After": []string{v}}); got != 0 {
+			t.Errorf("Retry-After %q = %v，期望 0", v, got)
+		}
+	}
+	if parseRetryAfter(nil) != 0 {
+		t.Error("nil header 应返回 0")
+	}
+}
@@ -0,0 +1,314 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html/charset"
+)
+
+// URL 来源
+const (
+	URLSourceSeed    = "seed"    // 输入中给出的 URL
+	URLSourceSitemap = "sitemap" // 从 sitemap 发现的 URL
+	URLSourceRobots  = "robots"  // 从 robots.txt 的 Allow/Disallow 路径发现的 URL
+)
+
+// robots.txt 和 sitemap 发现相关常量
+const (
+	maxSitemapFetches = 20               // 每个 origin 最多下载的 sitemap 数（包括 sitemap 索引）
+	maxSitemapDepth   = 3                // sitemap 索引最多嵌套的层数
+	maxSitemapSize    = 50 * 1024 * 1024 // 解压后 sitemap 的最大大小（与 sitemap 协议的上限一致）
+)
+
+// conventionalSitemaps robots.txt 没有声明 sitemap 时尝试的常见位置
+var conventionalSitemaps = []string{"/sitemap.xml", "/sitemap_index.xml"}
+
+// seedOrigin 一个 origin 的发现状态
+type seedOrigin struct {
+	base        *url.URL // origin 根地址（scheme://host[:port]/）
+	robotsPaths []string // robots.txt 中的 Allow/Disallow 路径，sitemap 之后再加入
+	sitemaps    map[string]bool
+	found       []URLItem
+}
+
+// sitemapFetch 待下载的 sitemap
+type sitemapFetch struct {
+	origin *seedOrigin
+	url    string
+	depth  int
+}
+
+// SeedFromSitemaps 对输入涉及的每个 origin 请求 /robots.txt 和 sitemap，把发现的同主机 URL 加入列表
+// sitemap 取 robots.txt 中声明的，没有声明时尝试 /sitemap.xml 和 /sitemap_index.xml；支持 sitemap 索引和 gzip 压缩的 sitemap。
+// 每个 origin 最多加入 perOrigin 个 URL，sitemap 中的 URL 优先，其次 robots.txt 中的路径；
+// 发现的 URL 按 canon 规范化，与已有 URL 相同的不再加入。返回追加后的列表和加入的数量（ID 由调用方重新编号）
+func SeedFromSitemaps(ctx context.Context, fetcher *Fetcher, items []URLItem, perOrigin int, canon CanonicalConfig, parallel int) ([]URLItem, int) {
+	logger := GetLogger()
+	if perOrigin <= 0 {
+		return items, 0
+	}
+
+	known := make(map[string]bool, len(items))
+	for _, item := range items {
+		known[item.NormalizedURL] = true
+	}
+
+	// 收集所有 origin
+	seen := make(map[string]bool)
+	var origins []*seedOrigin
+	var robotsItems []URLItem
+	for _, item := range items {
+		origin := item.targetScope(OriginKey(item.NormalizedURL))
+		if origin == "" || seen[origin] {
+			continue
+		}
+		seen[origin] = true
+
+		u, err := url.Parse(item.NormalizedURL)
+		if err != nil {
+			continue
+		}
+		base := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}
+		origins = append(origins, &seedOrigin{base: base, sitemaps: make(map[string]bool)})
+		robotsURL := base.ResolveReference(&url.URL{Path: "/robots.txt"}).String()
+		robotsItems = append(robotsItems, URLItem{
+			RawURL:        robotsURL,
+			NormalizedURL: robotsURL,
+			TargetIP:      item.TargetIP,
+			VHost:         item.VHost,
+		})
+	}
+	if len(origins) == 0 {
+		return items, 0
+	}
+
+	logger.Info("robots.txt/sitemap 发现：%d 个 origin", len(origins))
+
+	// 第一轮：robots.txt
+	var pending []sitemapFetch
+	for i, fr := range fetcher.FetchBatch(ctx, robotsItems, parallel) {
+		origin := origins[i]
+		var declared []string
+		if fr.Error == "" && (fr.StatusCode >= 200 && fr.StatusCode < 300) && fr.ContentCategory == ContentCategoryText {
+			declared, origin.robotsPaths = parseRobots(fr.RawBody)
+		}
+		if len(declared) == 0 {
+			for _, p := range conventionalSitemaps {
+				declared = append(declared, origin.base.ResolveReference(&url.URL{Path: p}).String())
+			}
+		}
+		for _, loc := range declared {
+			pending = origin.queueSitemap(pending, loc, 0)
+		}
+	}
+
+	// 之后逐层下载 sitemap，sitemap 索引中的子 sitemap 放到下一轮
+	fetched := make(map[*seedOrigin]int)
+	for len(pending) > 0 && ctx.Err() == nil {
+		var batch []sitemapFetch
+		var batchItems []URLItem
+		for _, sf := range pending {
+			if len(sf.origin.found) >= perOrigin || fetched[sf.origin] >= maxSitemapFetches {
+				continue
+			}
+			fetched[sf.origin]++
+			batch = append(batch, sf)
+			// sitemap 按协议上限读取，不受页面的 MaxHTMLSize 限制
+			batchItems = append(batchItems, URLItem{RawURL: sf.url, NormalizedURL: sf.url, maxBodySize: maxSitemapSize})
+		}
+		pending = nil
+
+		for i, fr := range fetcher.FetchBatch(ctx, batchItems, parallel) {
+			sf := batch[i]
+			if fr.Error != "" || !(fr.StatusCode >= 200 && fr.StatusCode < 300) {
+				continue
+			}
+			locs, children, err := parseSitemap(fr)
+			if err != nil {
+				logger.Warn("解析 sitemap 失败 (%s): %v", sf.url, err)
+				continue
+			}
+			for _, loc := range locs {
+				if len(sf.origin.found) >= perOrigin {
+					break
+				}
+				sf.origin.add(loc, URLSourceSitemap, canon, known)
+			}
+			if sf.depth < maxSitemapDepth {
+				for _, child := range children {
+					pending = sf.origin.queueSitemap(pending, child, sf.depth+1)
+				}
+			}
+		}
+	}
+
+	// 最后用 robots.txt 中的路径补足
+	added := 0
+	for _, origin := range origins {
+		for _, p := range origin.robotsPaths {
+			if len(origin.found) >= perOrigin {
+				break
+			}
+			origin.add(p, URLSourceRobots, canon, known)
+		}
+		items = append(items, origin.found...)
+		added += len(origin.found)
+	}
+
+	logger.Info("robots.txt/sitemap 发现：加入 %d 个 URL", added)
+	return items, added
+}
+
+// queueSitemap 把 sitemap 地址加入待下载列表，同一个 origin 内重复的地址只下载一次
+func (o *seedOrigin) queueSitemap(pending []sitemapFetch, loc string, depth int) []sitemapFetch {
+	ref, err := url.Parse(strings.TrimSpace(loc))
+	if err != nil {
+		return pending
+	}
+	u := o.base.ResolveReference(ref)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return pending
+	}
+	u.Fragment = ""
+	key := u.String()
+	if o.sitemaps[key] {
+		return pending
+	}
+	o.sitemaps[key] = true
+	return append(pending, sitemapFetch{origin: o, url: key, depth: depth})
+}
+
+// add 加入发现的 URL：只接受与 origin 主机相同的 http/https 地址，规范化后已存在的跳过
+func (o *seedOrigin) add(loc, source string, canon CanonicalConfig, known map[string]bool) {
+	ref, err := url.Parse(strings.TrimSpace(loc))
+	if err != nil {
+		return
+	}
+	u := o.base.ResolveReference(ref)
+	if (u.Scheme != "http" && u.Scheme != "https") || !strings.EqualFold(u.Hostname(), o.base.Hostname()) {
+		return
+	}
+
+	raw := u.String()
+	normalized, err := normalizeURL(raw, canon)
+	if err != nil || known[normalized] {
+		return
+	}
+	known[normalized] = true
+	o.found = append(o.found, URLItem{
+		RawURL:        raw,
+		NormalizedURL: normalized,
+		Source:        source,
+	})
+}
+
+// parseRobots 解析 robots.txt，返回声明的 sitemap 和 Allow/Disallow 中的路径
+// 路径不区分 user-agent；带通配符 * 的规则和根路径 / 跳过，末尾的 $ 去掉
+func parseRobots(data []byte) (sitemaps, paths []string) {
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "sitemap":
+			sitemaps = append(sitemaps, value)
+		case "allow", "disallow":
+			value = strings.TrimSuffix(value, "$")
+			if !strings.HasPrefix(value, "/") || value == "/" || strings.Contains(value, "*") || seen[value] {
+				continue
+			}
+			seen[value] = true
+			paths = append(paths, value)
+		}
+	}
+	return sitemaps, paths
+}
+
+// sitemapDoc sitemap 中用到的部分，urlset 和 sitemapindex 共用
+type sitemapDoc struct {
+	URLs []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// parseSitemap 解析 sitemap，返回页面地址和子 sitemap 地址（sitemap 索引）
+// 支持 XML 和每行一个 URL 的文本 sitemap，内容为 gzip 时先解压
+func parseSitemap(fr FetchResult) (locs, children []string, err error) {
+	data := fr.RawBody
+	if len(data) == 0 {
+		data = fr.RawHTML
+	}
+	// HTML 和文本已经按页面编码转换为 UTF-8，XML 声明中的编码不能再转换一次
+	decoded := fr.ContentCategory == ContentCategoryHTML || fr.ContentCategory == ContentCategoryText
+
+	if len(data) >= 2 && data[0] == 0x1f && data[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, nil, fmt.Errorf("gzip 解压失败: %w", err)
+		}
+		data, err = io.ReadAll(io.LimitReader(zr, maxSitemapSize))
+		zr.Close()
+		if err != nil && len(data) == 0 {
+			return nil, nil, fmt.Errorf("gzip 解压失败: %w", err)
+		}
+		decoded = false
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if !bytes.HasPrefix(trimmed, []byte("<")) {
+		scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if strings.HasPrefix(line, "http://") || strings.HasPrefix(line, "https://") {
+				locs = append(locs, line)
+			}
+		}
+		return locs, nil, nil
+	}
+
+	var doc sitemapDoc
+	decoder := xml.NewDecoder(bytes.NewReader(trimmed))
+	decoder.Strict = false
+	if decoded {
+		decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) { return input, nil }
+	} else {
+		decoder.CharsetReader = charset.NewReaderLabel
+	}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("解析 XML 失败: %w", err)
+	}
+	for _, u := range doc.URLs {
+		if loc := strings.TrimSpace(u.Loc); loc != "" {
+			locs = append(locs, loc)
+		}
+	}
+	for _, s := range doc.Sitemaps {
+		if loc := strings.TrimSpace(s.Loc); loc != "" {
+			children = append(children, loc)
+		}
+	}
+	return locs, children, nil
+}
@@ -0,0 +1,160 @@
+package internal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRobots(t *testing.T) {
+	data := `User-agent: *
+Disallow: /admin/   # 后台
+Disallow: /admin/
+Allow: /public$
+Disallow: /*.php
+Disallow: /
+Disallow:
+Allow: relative
+sitemap: https://a.com/sitemap.xml
+Sitemap: /sitemap-news.xml
+`
+	sitemaps, paths := parseRobots([]byte(data))
+	if want := []string{"https://a.com/sitemap.xml", "/sitemap-news.xml"}; !reflect.DeepEqual(sitemaps, want) {
+		t.Errorf("sitemaps = %v, want %v", sitemaps, want)
+	}
+	if want := []string{"/admin/", "/public"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+}
+
+func gzipBytes(t *testing.T, data string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+const testURLSet = `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc> https://a.com/a </loc></url>
+  <url><loc>https://a.com/b</loc><lastmod>2024-01-01</lastmod></url>
+  <url><loc></loc></url>
+</urlset>`
+
+const testSitemapIndex = `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <sitemap><loc>https://a.com/sitemap-1.xml.gz</loc></sitemap>
+  <sitemap><loc>https://a.com/sitemap-2.xml</loc></sitemap>
+</sitemapindex>`
+
+func TestParseSitemap(t *testing.T) {
+	tests := []struct {
+		name         string
+		fr           FetchResult
+		wantLocs     []string
+		wantChildren []string
+	}{
+		{"urlset", FetchResult{ContentCategory: ContentCategoryText, RawBody: []byte(testURLSet)},
+			[]string{"https://a.com/a", "https://a.com/b"}, nil},
+		{"sitemap 索引", FetchResult{ContentCategory: ContentCategoryText, RawBody: []byte(testSitemapIndex)},
+			nil, []string{"https://a.com/sitemap-1.xml.gz", "https://a.com/sitemap-2.xml"}},
+		{"gzip", FetchResult{ContentCategory: ContentCategoryBinary, RawBody: gzipBytes(t, testURLSet)},
+			[]string{"https://a.com/a", "https://a.com/b"}, nil},
+		{"gzip 索引", FetchResult{ContentCategory: ContentCategoryBinary, RawBody: gzipBytes(t, testSitemapIndex)},
+			nil, []string{"https://a.com/sitemap-1.xml.gz", "https://a.com/sitemap-2.xml"}},
+		{"文本 sitemap", FetchResult{ContentCategory: ContentCategoryText, RawBody: []byte("https://a.com/a\n\n# x\nftp://a.com/f\n http://a.com/b \n")},
+			[]string{"https://a.com/a", "http://a.com/b"}, nil},
+		{"当成 HTML 返回", FetchResult{ContentCategory: ContentCategoryHTML, RawHTML: []byte(testURLSet)},
+			[]string{"https://a.com/a", "https://a.com/b"}, nil},
+	}
+	for _, tt := range tests {
+		locs, children, err := parseSitemap(tt.fr)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(locs, tt.wantLocs) || !reflect.DeepEqual(children, tt.wantChildren) {
+			t.Errorf("%s: locs = %v, children = %v, want %v, %v", tt.name, locs, children, tt.wantLocs, tt.wantChildren)
+		}
+	}
+}
+
+func TestParseSitemapInvalid(t *testing.T) {
+	tests := map[string][]byte{
+		"gzip 损坏": {0x1f, 0x8b, 0x08, 0x00, 0x01},
+		"XML 损坏":  []byte("<urlset><url><loc>https://a.com/a</loc></url>"),
+	}
+	for name, data := range tests {
+		if _, _, err := parseSitemap(FetchResult{ContentCategory: ContentCategoryText, RawBody: data}); err == nil {
+			t.Errorf("%s: 应该返回错误", name)
+		}
+	}
+}
+
+func TestFetchMaxBodySize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	defer server.Close()
+
+	fetcher := NewFetcher(FetcherConfig{Timeout: 5 * time.Second, MaxRedirects: 5})
+	fr := fetcher.Fetch(context.Background(), URLItem{NormalizedURL: server.URL, maxBodySize: 10})
+	if len(fr.RawBody) != 10 {
+		t.Errorf("指定上限时应只读取 10 字节，实际 %d", len(fr.RawBody))
+	}
+	fr = fetcher.Fetch(context.Background(), URLItem{NormalizedURL: server.URL})
+	if len(fr.RawBody) != 100 {
+		t.Errorf("默认应读取完整响应，实际 %d", len(fr.RawBody))
+	}
+}
+
+func TestSeedFromSitemaps(t *testing.T) {
+	var base string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("User-agent: *\nDisallow: /private/\nSitemap: /index.xml\n"))
+	})
+	mux.HandleFunc("/index.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write([]byte(`<sitemapindex><sitemap><loc>` + base + `/pages.xml.gz</loc></sitemap><sitemap><loc>/broken.xml</loc></sitemap></sitemapindex>`))
+	})
+	mux.HandleFunc("/pages.xml.gz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-gzip")
+		w.Write(gzipBytes(t, `<urlset><url><loc>`+base+`/a</loc></url><url><loc>`+base+`/</loc></url><url><loc>https://other.example/x</loc></url></urlset>`))
+	})
+	mux.HandleFunc("/broken.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write([]byte("<urlset><url>"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	base = server.URL
+
+	items := []URLItem{{ID: 1, RawURL: base, NormalizedURL: base + "/", Source: URLSourceSeed}}
+	fetcher := NewFetcher(FetcherConfig{Timeout: 5 * time.Second, MaxRedirects: 5})
+	got, added := SeedFromSitemaps(context.Background(), fetcher, items, 5, CanonicalConfig{}, 2)
+
+	if added != 2 || len(got) != 3 {
+		t.Fatalf("加入 %d 个 URL: %+v", added, got)
+	}
+	if got[1].NormalizedURL != base+"/a" || got[1].Source != URLSourceSitemap {
+		t.Errorf("sitemap 中的 URL 应先加入: %+v", got[1])
+	}
+	if got[2].NormalizedURL != base+"/private/" || got[2].Source != URLSourceRobots {
+		t.Errorf("robots.txt 的路径应补在后面: %+v", got[2])
+	}
+}
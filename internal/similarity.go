@@ -4,13 +4,17 @@ import (
 	"math"
 )
 
-// 相似度判定阈值常量
+// 相似度判定阈值默认值，实际判定使用 Thresholds（可通过配置文件和命令行覆盖）
+// simContent = 1 - d/16，ContentSimThreshold 0.75 对应 TextSimHash 汉明距离 d <= 4。
+// 按 tokenize 的分词（中日韩文字 bigram + trigram）实测：中文正文改动 1 个字，500 字时 d 的 P99 为 4，
+// 200 字时中位数 2、P95 为 5；不相关正文 d 一般在 9 以上（3000 字时最小 9，500 字时最小 12）
 const (
-	ContentSimThreshold    = 0.97  // 文本相似度阈值，规则1用
-	StructureSimThreshold  = 0.85  // 结构相似度阈值，规则1用
-	VisualSimThreshold     = 0.85  // 视觉相似度阈值，规则1用
-	VisualHighSimThreshold = 0.99  // 视觉极高相似度阈值，规则2兜底用
-	QuickSimHashMaxDist    = 8     // SimHash 预筛选最大汉明距离，超过这个值直接跳过（8 bit 约等于 87.5% 一致）
+	ContentSimThreshold    = 0.75 // 文本相似度阈值，规则1用（d <= 4）
+	StructureSimThreshold  = 0.85 // 结构相似度阈值，规则1用
+	VisualSimThreshold     = 0.85 // 视觉相似度阈值，规则1用
+	VisualHighSimThreshold = 0.99 // 视觉极高相似度阈值，规则2兜底用
+	QuickSimHashMaxDist    = 8    // SimHash 预筛选最大汉明距离，超过这个值直接跳过（8 bit 约等于 87.5% 一致）
+	HeaderSimThreshold     = 0    // 响应头相似度下限，默认不检查（同一后端的不同页面缓存头等也可能不同）
 )
 
 // simContent 计算文本相似度
@@ -104,54 +108,132 @@ func simBehavior(a, b *PageFeatures) float64 {
 	return cosineSimilarity(va, vb)
 }
 
+// simHeaders 计算响应头相似度
+// 参与指纹的头按“名称和值都相同”计数，除以两边出现的头名称总数
+func simHeaders(a, b *PageFeatures) float64 {
+	if len(a.HeaderSet) == 0 || len(b.HeaderSet) == 0 {
+		return 0
+	}
+	if a.HeaderHash != 0 && a.HeaderHash == b.HeaderHash {
+		return 1
+	}
+
+	union := len(a.HeaderSet)
+	same := 0
+	for name, vb := range b.HeaderSet {
+		va, ok := a.HeaderSet[name]
+		if !ok {
+			union++
+			continue
+		}
+		if va == vb {
+			same++
+		}
+	}
+	return float64(same) / float64(union)
+}
+
+// headersAvailable 两个页面是否都有响应头指纹
+func headersAvailable(a, b *PageFeatures) bool {
+	return len(a.HeaderSet) > 0 && len(b.HeaderSet) > 0
+}
+
 // totalSim 计算总相似度（仅用于展示）
-func totalSim(contentSim, structSim, visualSim, behaviorSim float64) float64 {
-	return 0.4*contentSim + 0.25*structSim + 0.25*visualSim + 0.10*behaviorSim
+// 权重之和不为 1 时按总和归一化，保证结果在 [0, 1] 内
+func totalSim(w SimWeights, contentSim, structSim, visualSim, behaviorSim, headerSim float64) float64 {
+	sum := w.Content + w.Structure + w.Visual + w.Behavior + w.Header
+	if sum <= 0 {
+		return 0
+	}
+	return (w.Content*contentSim + w.Structure*structSim + w.Visual*visualSim + w.Behavior*behaviorSim + w.Header*headerSim) / sum
 }
 
+// DuplicateReason 重复判定命中的分支
+type DuplicateReason string
+
+const (
+	DuplicateReasonNone          DuplicateReason = ""
+	DuplicateReasonTextStructure DuplicateReason = "text+structure" // HTML 规则1：文本 + 结构
+	DuplicateReasonTextVisual    DuplicateReason = "text+visual"    // HTML 规则1：文本 + 视觉
+	DuplicateReasonVisual        DuplicateReason = "visual"         // HTML 规则2：视觉兜底
+	DuplicateReasonTextSimHash   DuplicateReason = "text_simhash"   // 文本类 SimHash
+	DuplicateReasonImagePHash    DuplicateReason = "image_phash"    // 图片 pHash
+	DuplicateReasonBinaryExact   DuplicateReason = "binary_exact"   // 二进制完全匹配
+)
+
 // IsDuplicate 判断两个页面是否为重复页面
 // 根据内容类型使用不同的判断策略
-func IsDuplicate(a, b *PageFeatures) bool {
+func IsDuplicate(a, b *PageFeatures, t Thresholds) bool {
+	return MatchDuplicate(a, b, t) != DuplicateReasonNone
+}
+
+// MatchDuplicate 判断两个页面是否为重复页面，返回命中的判定分支
+// 不重复时返回 DuplicateReasonNone
+func MatchDuplicate(a, b *PageFeatures, t Thresholds) DuplicateReason {
 	// 不同类型的内容不能判定为重复
 	if a.Category != b.Category {
-		return false
+		return DuplicateReasonNone
+	}
+
+	// 响应头差异过大（不同的后端）不判定为重复；任意一方没有响应头时不检查
+	if t.HeaderSim > 0 && headersAvailable(a, b) && simHeaders(a, b) < t.HeaderSim {
+		return DuplicateReasonNone
 	}
 
 	// 根据内容类型使用不同策略
 	switch a.Category {
 	case ContentCategoryHTML:
-		return isDuplicateHTML(a, b)
+		return isDuplicateHTML(a, b, t)
 	case ContentCategoryText:
-		return isDuplicateText(a, b)
+		if isDuplicateText(a, b, t) {
+			return DuplicateReasonTextSimHash
+		}
 	case ContentCategoryImage:
-		return isDuplicateImage(a, b)
+		if isDuplicateImage(a, b, t) {
+			return DuplicateReasonImagePHash
+		}
 	case ContentCategoryBinary:
-		return isDuplicateBinary(a, b)
-	default:
-		return false
+		if isDuplicateBinary(a, b) {
+			return DuplicateReasonBinaryExact
+		}
 	}
+	return DuplicateReasonNone
 }
 
-// isDuplicateHTML HTML 页面的重复判断（原有逻辑）
-func isDuplicateHTML(a, b *PageFeatures) bool {
+// isDuplicateHTML HTML 页面的重复判断（原有逻辑），返回命中的分支
+// 任意一方没有视觉维度（HTTP-only 模式）时只用文本 + 结构判定，不走视觉兜底
+func isDuplicateHTML(a, b *PageFeatures, t Thresholds) DuplicateReason {
 	contentSim := simContent(a, b)
 	structureSim := simStructure(a, b)
+
+	if a.VisualUnavailable || b.VisualUnavailable {
+		if contentSim >= t.ContentSim && structureSim >= t.StructureSim {
+			return DuplicateReasonTextStructure
+		}
+		return DuplicateReasonNone
+	}
+
 	visualSim := simVisual(a, b)
 
-	if contentSim >= ContentSimThreshold && (structureSim >= StructureSimThreshold || visualSim >= VisualSimThreshold) {
-		return true
+	if contentSim >= t.ContentSim {
+		if structureSim >= t.StructureSim {
+			return DuplicateReasonTextStructure
+		}
+		if visualSim >= t.VisualSim {
+			return DuplicateReasonTextVisual
+		}
 	}
 
-	if visualSim >= VisualHighSimThreshold {
-		return true
+	if visualSim >= t.VisualHighSim {
+		return DuplicateReasonVisual
 	}
 
-	return false
+	return DuplicateReasonNone
 }
 
 // isDuplicateText 文本类内容的重复判断（JSON/XML/纯文本）
 // 只使用 SimHash 比较，简单高效
-func isDuplicateText(a, b *PageFeatures) bool {
+func isDuplicateText(a, b *PageFeatures, t Thresholds) bool {
 	// 长度差异太大直接跳过
 	if a.TextLength == 0 || b.TextLength == 0 {
 		return false
@@ -166,18 +248,18 @@ func isDuplicateText(a, b *PageFeatures) bool {
 
 	// SimHash 汉明距离
 	dist := hammingDistance64(a.TextSimHash, b.TextSimHash)
-	return dist <= TextSimHashMaxDist
+	return dist <= t.TextSimHashMaxDist
 }
 
 // isDuplicateImage 图片的重复判断
 // 使用 pHash 比较
-func isDuplicateImage(a, b *PageFeatures) bool {
+func isDuplicateImage(a, b *PageFeatures, t Thresholds) bool {
 	if a.PHash == 0 || b.PHash == 0 {
 		return false
 	}
 
 	dist := hammingDistance64(a.PHash, b.PHash)
-	return dist <= ImagePHashMaxDist
+	return dist <= t.ImagePHashMaxDist
 }
 
 // isDuplicateBinary 二进制内容的重复判断
@@ -192,21 +274,35 @@ func isDuplicateBinary(a, b *PageFeatures) bool {
 }
 
 // CalculateSimilarities 计算所有维度的相似度
-// 根据内容类型返回有意义的相似度值
-func CalculateSimilarities(a, b *PageFeatures) (contentSim, structureSim, visualSim, behaviorSim, total float64) {
+// 根据内容类型返回有意义的相似度值，响应头相似度对所有类型都计算，只计入 HTML 的总相似度
+func CalculateSimilarities(a, b *PageFeatures, t Thresholds) (contentSim, structureSim, visualSim, behaviorSim, headerSim, total float64) {
 	// 不同类型的内容，返回 0
 	if a.Category != b.Category {
-		return 0, 0, 0, 0, 0
+		return 0, 0, 0, 0, 0, 0
 	}
 
+	headerSim = simHeaders(a, b)
+
 	switch a.Category {
 	case ContentCategoryHTML:
-		// HTML：完整计算所有维度
+		// HTML：计算所有可用维度，不可用的维度权重置 0
+		w := t.Weights
 		contentSim = simContent(a, b)
 		structureSim = simStructure(a, b)
-		visualSim = simVisual(a, b)
-		behaviorSim = simBehavior(a, b)
-		total = totalSim(contentSim, structureSim, visualSim, behaviorSim)
+		if a.VisualUnavailable || b.VisualUnavailable {
+			w.Visual = 0
+		} else {
+			visualSim = simVisual(a, b)
+		}
+		if a.BehaviorUnavailable || b.BehaviorUnavailable {
+			w.Behavior = 0
+		} else {
+			behaviorSim = simBehavior(a, b)
+		}
+		if !headersAvailable(a, b) {
+			w.Header = 0
+		}
+		total = totalSim(w, contentSim, structureSim, visualSim, behaviorSim, headerSim)
 
 	case ContentCategoryText:
 		// 文本类：只有 contentSim 有意义
@@ -227,7 +323,7 @@ func CalculateSimilarities(a, b *PageFeatures) (contentSim, structureSim, visual
 
 	default:
 		// 未知类型
-		return 0, 0, 0, 0, 0
+		return 0, 0, 0, 0, 0, 0
 	}
 
 	return
@@ -276,4 +372,3 @@ func min(a, b int) int {
 	}
 	return b
 }
-
@@ -0,0 +1,139 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// htmlTestFeatures 构造 HTML 页面特征，DOM 统计和路径相同时结构相似度为 1
+func htmlTestFeatures(simHash, pHash uint64) *PageFeatures {
+	return &PageFeatures{
+		Category:      ContentCategoryHTML,
+		TextSimHash:   simHash,
+		TextLength:    1000,
+		DOMNodeCount:  200,
+		TextNodeCount: 80,
+		TagCount:      map[string]int{"div": 50, "a": 20},
+		PathCount:     map[string]int{"html>body>div": 50},
+		PHash:         pHash,
+	}
+}
+
+func TestMatchDuplicateReasons(t *testing.T) {
+	th := DefaultThresholds()
+
+	differentStructure := htmlTestFeatures(1, 0xF0F0)
+	differentStructure.TagCount = map[string]int{"img": 300}
+	differentStructure.PathCount = map[string]int{"html>body>img": 300}
+	differentStructure.DOMNodeCount = 10
+	differentStructure.TextNodeCount = 1
+
+	noVisual := htmlTestFeatures(1, 0)
+	noVisual.VisualUnavailable = true
+
+	tests := []struct {
+		name string
+		a, b *PageFeatures
+		want DuplicateReason
+	}{
+		{"文本 + 结构", htmlTestFeatures(1, 0xF0F0), htmlTestFeatures(1, 0xF0F0), DuplicateReasonTextStructure},
+		{"文本 + 视觉", htmlTestFeatures(1, 0xF0F0), differentStructure, DuplicateReasonTextVisual},
+		{"视觉兜底", htmlTestFeatures(1, 0xF0F0), htmlTestFeatures(0xFFFF_0000, 0xF0F0), DuplicateReasonVisual},
+		{"文本不同且视觉不同", htmlTestFeatures(1, 0xF0F0), htmlTestFeatures(0xFFFF_0000, 0x0F0F), DuplicateReasonNone},
+		{"HTTP-only 不走视觉兜底", noVisual, htmlTestFeatures(0xFFFF_0000, 0), DuplicateReasonNone},
+		{"HTTP-only 文本 + 结构", noVisual, htmlTestFeatures(1, 0), DuplicateReasonTextStructure},
+		{
+			"文本类 SimHash",
+			&PageFeatures{Category: ContentCategoryText, TextSimHash: 0b1111, TextLength: 100},
+			&PageFeatures{Category: ContentCategoryText, TextSimHash: 0b0111, TextLength: 90},
+			DuplicateReasonTextSimHash,
+		},
+		{
+			"文本类长度差异过大",
+			&PageFeatures{Category: ContentCategoryText, TextSimHash: 1, TextLength: 100},
+			&PageFeatures{Category: ContentCategoryText, TextSimHash: 1, TextLength: 40},
+			DuplicateReasonNone,
+		},
+		{
+			"图片 pHash",
+			&PageFeatures{Category: ContentCategoryImage, PHash: 0xFF00},
+			&PageFeatures{Category: ContentCategoryImage, PHash: 0xFF01},
+			DuplicateReasonImagePHash,
+		},
+		{
+			"二进制完全匹配",
+			&PageFeatures{Category: ContentCategoryBinary, TextSimHash: 9, TextLength: 10},
+			&PageFeatures{Category: ContentCategoryBinary, TextSimHash: 9, TextLength: 10},
+			DuplicateReasonBinaryExact,
+		},
+		{
+			"类型不同",
+			&PageFeatures{Category: ContentCategoryBinary, TextSimHash: 9, TextLength: 10},
+			&PageFeatures{Category: ContentCategoryText, TextSimHash: 9, TextLength: 10},
+			DuplicateReasonNone,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := MatchDuplicate(tt.a, tt.b, th); got != tt.want {
+			t.Errorf("%s: MatchDuplicate = %q, want %q", tt.name, got, tt.want)
+		}
+		if IsDuplicate(tt.a, tt.b, th) != (tt.want != DuplicateReasonNone) {
+			t.Errorf("%s: IsDuplicate 与 MatchDuplicate 不一致", tt.name)
+		}
+	}
+}
+
+func TestHammingDistance64(t *testing.T) {
+	tests := []struct {
+		a, b uint64
+		want int
+	}{
+		{0, 0, 0},
+		{0, 1, 1},
+		{0, ^uint64(0), 64},
+		{0b1010, 0b0101, 4},
+	}
+	for _, tt := range tests {
+		if got := HammingDistance64(tt.a, tt.b); got != tt.want {
+			t.Errorf("HammingDistance64(%x, %x) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestIsDuplicateCJKEdit(t *testing.T) {
+	base := "我们公司成立于二零零八年，总部位于杭州，专注于为企业客户提供网络安全咨询、渗透测试和安全运维服务。" +
+		"经过十多年的发展，公司已经建立了一支由两百多名安全工程师组成的专业团队，服务客户遍布金融、能源、医疗和教育等行业。" +
+		"我们坚持以客户需求为中心，围绕资产梳理、漏洞发现、应急响应和安全培训四个方向持续投入研发，先后获得多项国家专利和行业资质认证。" +
+		"公司在北京、上海、广州、成都和西安设有分支机构，能够在两小时内响应客户现场的紧急安全事件，并提供全年无休的远程技术支持。" +
+		"未来我们将继续加大在云安全、数据安全和工业控制系统安全领域的投入，与合作伙伴一起为客户构建更加可靠的安全防护体系。" +
+		"如需了解产品和服务详情，欢迎通过页面底部的联系方式与我们的销售顾问取得联系，我们会在一个工作日内给您回复。"
+	edited := strings.Replace(base, "二零零八年", "二零零九年", 1)
+	other := "今天天气晴朗，适合出门散步。公园里的花都开了，很多人带着孩子在草地上放风筝，到处都是欢声笑语。" +
+		"湖边的柳树刚刚发出新芽，几只白鹭站在浅水里一动不动，偶尔低头啄一下水面，引得岸边的小朋友一阵欢呼。" +
+		"沿着石板路往山上走，半山腰有一座老茶馆，老板是一位七十多岁的老人，每天早上都会亲手泡一壶龙井招待来往的游客。" +
+		"茶馆的墙上挂满了老照片，记录着这座小镇几十年来的变化，从泥泞的土路到整洁的街道，从低矮的瓦房到明亮的新楼。" +
+		"傍晚时分，夕阳把整个湖面染成了金红色，散步的人们陆续回家，街边的小吃摊却渐渐热闹起来，空气里飘着烤红薯的香味。" +
+		"这样平静而温暖的日子，总让人想起小时候在外婆家度过的那些暑假，简单、缓慢，却充满了说不完的故事。"
+
+	page := func(text string) *PageFeatures {
+		f := htmlTestFeatures(computeSimHash(cleanText(text)), 0)
+		f.TextLength = utf8.RuneCountInString(cleanText(text))
+		f.VisualUnavailable = true
+		return f
+	}
+
+	th := DefaultThresholds()
+	if d := hammingDistance64(page(base).TextSimHash, page(edited).TextSimHash); d == 0 {
+		t.Fatal("改动一个字后 SimHash 不应完全相同，否则测不到阈值")
+	}
+	if !IsDuplicate(page(base), page(edited), th) {
+		t.Errorf("改动一个字的中文页面应判定为重复（距离 %d）",
+			hammingDistance64(page(base).TextSimHash, page(edited).TextSimHash))
+	}
+	if IsDuplicate(page(base), page(other), th) {
+		t.Errorf("不相关的中文页面不应判定为重复（距离 %d）",
+			hammingDistance64(page(base).TextSimHash, page(other).TextSimHash))
+	}
+}
@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// 内容嗅探相关常量
+const (
+	htmlSniffLength = 1024      // HTML 标签启发式查找范围
+	jsonSniffLength = 64 * 1024 // 超过该长度的 JSON 只检查开头结构
+)
+
+// htmlSniffMarkers 出现在响应开头即可认定为 HTML 的标记（小写）
+// DetectContentType 要求标签在开头（只允许空白），这里允许前面有注释、XML 声明或少量文本
+var htmlSniffMarkers = [][]byte{
+	[]byte("<!doctype html"),
+	[]byte("<html"),
+	[]byte("<head"),
+	[]byte("<body"),
+	[]byte("<title"),
+	[]byte("<meta "),
+	[]byte("<script"),
+	[]byte("<iframe"),
+	[]byte("<frameset"),
+}
+
+// SniffContentCategory 根据响应内容推测内容类型
+// 依次使用魔数（http.DetectContentType）和 HTML 标签判断，JSON、XML 和其他文本归为文本类
+func SniffContentCategory(body []byte) ContentCategory {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return ContentCategoryEmpty
+	}
+
+	detected := strings.ToLower(http.DetectContentType(body))
+	switch {
+	case strings.HasPrefix(detected, "text/html"):
+		return ContentCategoryHTML
+	case strings.HasPrefix(detected, "image/"):
+		return ContentCategoryImage
+	}
+
+	if looksLikeHTML(body) {
+		return ContentCategoryHTML
+	}
+
+	// JSON 和 XML 被识别为 text/plain、text/xml
+	if strings.HasPrefix(detected, "text/") {
+		return ContentCategoryText
+	}
+
+	// 其余魔数（PDF、压缩包、音视频、字体等）和无法识别的二进制
+	return ContentCategoryBinary
+}
+
+// looksLikeHTML HTML 标签启发式
+func looksLikeHTML(body []byte) bool {
+	head := body
+	if len(head) > htmlSniffLength {
+		head = head[:htmlSniffLength]
+	}
+	lower := bytes.ToLower(head)
+	for _, marker := range htmlSniffMarkers {
+		if bytes.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasHTMLDocumentStart 响应是否以 HTML 文档开头
+// 跳过 BOM、空白、XML 声明和注释后，必须是 <!doctype html 或 <html 标签
+func hasHTMLDocumentStart(body []byte) bool {
+	head := body
+	if len(head) > htmlSniffLength {
+		head = head[:htmlSniffLength]
+	}
+	head = bytes.ToLower(bytes.TrimPrefix(head, []byte("\xEF\xBB\xBF")))
+
+	for {
+		head = bytes.TrimLeft(head, " \t\r\n\f")
+		var end []byte
+		switch {
+		case bytes.HasPrefix(head, []byte("<!--")):
+			end = []byte("-->")
+		case bytes.HasPrefix(head, []byte("<?xml")):
+			end = []byte("?>")
+		default:
+			return hasTagPrefix(head, []byte("<!doctype html")) || hasTagPrefix(head, []byte("<html"))
+		}
+
+		i := bytes.Index(head, end)
+		if i < 0 {
+			return false
+		}
+		head = head[i+len(end):]
+	}
+}
+
+// hasTagPrefix 以 tag 开头，且 tag 后是空白、> 或 /（避免 <htmlx 这类标签误匹配）
+func hasTagPrefix(b, tag []byte) bool {
+	if !bytes.HasPrefix(b, tag) {
+		return false
+	}
+	if len(b) == len(tag) {
+		return true
+	}
+	switch b[len(tag)] {
+	case ' ', '\t', '\r', '\n', '\f', '>', '/':
+		return true
+	}
+	return false
+}
+
+// looksLikeJSON JSON 结构启发式
+func looksLikeJSON(body []byte) bool {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return false
+	}
+	if len(trimmed) <= jsonSniffLength {
+		return json.Valid(trimmed)
+	}
+	// 太大的响应只检查开头是否像 JSON（可能被 MaxHTMLSize 截断，完整校验没有意义）
+	rest := bytes.TrimSpace(trimmed[1:])
+	return len(rest) > 0 && (rest[0] == '"' || rest[0] == '{' || rest[0] == '[' || rest[0] == '}' || rest[0] == ']')
+}
+
+// reconcileContentCategory 综合声明的类型和嗅探结果，得到实际使用的类型
+// 声明缺失或是笼统的类型（text/plain、application/octet-stream）时以内容为准；
+// 声明的类型和内容明显不符（以 HTML 文档开头的内容当作纯文本/二进制返回、错误页当作图片返回、二进制或 JSON 当作 HTML 返回）时以内容为准；
+// 其他情况信任声明的类型
+func reconcileContentCategory(declared, detected ContentCategory, contentType string, body []byte) ContentCategory {
+	if detected == ContentCategoryEmpty {
+		// 空 body 保留声明的类型（规则聚类仍按声明的类型判断空页面）
+		return declared
+	}
+
+	if declared == ContentCategoryEmpty || isGenericContentType(contentType) {
+		return detected
+	}
+
+	switch declared {
+	case ContentCategoryText, ContentCategoryBinary, ContentCategoryImage:
+		// 声明为其他类型时，只有以 HTML 文档开头的才认定为 HTML：
+		// RSS/Atom、JS、SVG 里同样会出现 <title>、<script>、<meta> 等标签
+		if detected == ContentCategoryHTML {
+			if hasHTMLDocumentStart(body) {
+				return ContentCategoryHTML
+			}
+			if declared == ContentCategoryBinary {
+				return ContentCategoryText
+			}
+			return declared
+		}
+		if declared == ContentCategoryBinary && (detected == ContentCategoryText || detected == ContentCategoryImage) {
+			return detected
+		}
+	case ContentCategoryHTML:
+		if detected == ContentCategoryImage || detected == ContentCategoryBinary {
+			return detected
+		}
+		// 没有任何 HTML 标签的 JSON（接口错误地声明为 text/html）
+		if detected == ContentCategoryText && looksLikeJSON(body) {
+			return ContentCategoryText
+		}
+	}
+
+	return declared
+}
+
+// isGenericContentType 是否为不能说明实际内容的笼统类型
+func isGenericContentType(contentType string) bool {
+	ct := strings.ToLower(strings.TrimSpace(contentType))
+	if i := strings.IndexByte(ct, ';'); i >= 0 {
+		ct = strings.TrimSpace(ct[:i])
+	}
+	switch ct {
+	case "", "text/plain", "application/octet-stream", "binary/octet-stream", "application/unknown", "unknown/unknown":
+		return true
+	}
+	return false
+}
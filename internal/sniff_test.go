@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+)
+
+var sniffTestPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00")
+
+func TestSniffContentCategory(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want ContentCategory
+	}{
+		{"空白", "  \n", ContentCategoryEmpty},
+		{"HTML", "<!DOCTYPE html><html><body>x</body></html>", ContentCategoryHTML},
+		{"前面有文本的 HTML", "warning: deprecated\n<html><head><title>x</title></head></html>", ContentCategoryHTML},
+		{"PNG", string(sniffTestPNG), ContentCategoryImage},
+		{"JSON", `{"code":0,"msg":"ok"}`, ContentCategoryText},
+		{"纯文本", "hello world", ContentCategoryText},
+		{"PDF", "%PDF-1.4\n%\xe2\xe3\xcf\xd3\n", ContentCategoryBinary},
+		{"二进制", "\x00\x01\x02\x03\xff\xfe", ContentCategoryBinary},
+	}
+	for _, tt := range tests {
+		if got := SniffContentCategory([]byte(tt.body)); got != tt.want {
+			t.Errorf("%s: SniffContentCategory = %q，期望 %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHasHTMLDocumentStart(t *testing.T) {
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{"<!DOCTYPE html><html></html>", true},
+		{"\xEF\xBB\xBF  <HTML lang=\"en\">", true},
+		{"<html>", true},
+		{"<!-- generated --><!doctype html>", true},
+		{"<?xml version=\"1.0\"?>\n<!DOCTYPE html PUBLIC \"-//W3C//DTD XHTML 1.0 Strict//EN\">", true},
+		{"<?xml version=\"1.0\"?><rss version=\"2.0\"><channel><title>x</title></channel></rss>", false},
+		{"<svg xmlns=\"http://www.w3.org/2000/svg\"><title>icon</title><script>x()</script></svg>", false},
+		{"var s = '<script>';", false},
+		{"<htmlx>", false},
+		{"<!-- 未闭合的注释 <html>", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := hasHTMLDocumentStart([]byte(tt.body)); got != tt.want {
+			t.Errorf("hasHTMLDocumentStart(%q) = %v，期望 %v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestReconcileContentCategory(t *testing.T) {
+	htmlPage := []byte("<!DOCTYPE html><html><head><title>404</title></head><body>not found</body></html>")
+	rss := []byte(`<?xml version="1.0"?><rss version="2.0"><channel><title>新闻</title><item><title>a</title></item></channel></rss>`)
+	atom := []byte(`<feed xmlns="http://www.w3.org/2005/Atom"><title>x</title><link href="/"/></feed>`)
+	jsBundle := []byte(`!function(){document.head.appendChild(document.createElement("script"));var t="<title>"}();`)
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg"><title>logo</title><script>x()</script></svg>`)
+	jsonBody := []byte(`{"error":"not found"}`)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		want        ContentCategory
+	}{
+		{"没有 Content-Type", "", htmlPage, ContentCategoryHTML},
+		{"text/plain 以内容为准", "text/plain", jsonBody, ContentCategoryText},
+		{"octet-stream 以内容为准", "application/octet-stream", sniffTestPNG, ContentCategoryImage},
+		{"HTML 被声明为 JSON", "application/json", htmlPage, ContentCategoryHTML},
+		{"错误页被声明为图片", "image/png", htmlPage, ContentCategoryHTML},
+		{"RSS 保持文本", "application/rss+xml", rss, ContentCategoryText},
+		{"Atom 保持文本", "application/atom+xml", atom, ContentCategoryText},
+		{"JS 保持文本", "application/javascript", jsBundle, ContentCategoryText},
+		{"SVG 保持图片", "image/svg+xml", svg, ContentCategoryImage},
+		{"JSON 被声明为 HTML", "text/html", jsonBody, ContentCategoryText},
+		{"图片被声明为 HTML", "text/html", sniffTestPNG, ContentCategoryImage},
+		{"空 body 保留声明的类型", "text/html", nil, ContentCategoryHTML},
+	}
+	for _, tt := range tests {
+		declared := categorizeContent(tt.contentType)
+		detected := SniffContentCategory(tt.body)
+		if got := reconcileContentCategory(declared, detected, tt.contentType, tt.body); got != tt.want {
+			t.Errorf("%s: reconcileContentCategory(%q, %q) = %q，期望 %q", tt.name, declared, detected, got, tt.want)
+		}
+	}
+}
+
+func TestCategorizeContent(t *testing.T) {
+	tests := map[string]ContentCategory{
+		"text/html; charset=utf-8": ContentCategoryHTML,
+		"application/xhtml+xml":    ContentCategoryHTML,
+		"image/svg+xml":            ContentCategoryImage,
+		"image/png":                ContentCategoryImage,
+		"application/rss+xml":      ContentCategoryText,
+		"application/problem+json": ContentCategoryText,
+		"application/javascript":   ContentCategoryText,
+		"application/pdf":          ContentCategoryBinary,
+		"":                         ContentCategoryEmpty,
+	}
+	for ct, want := range tests {
+		if got := categorizeContent(ct); got != want {
+			t.Errorf("categorizeContent(%q) = %q，期望 %q", ct, got, want)
+		}
+	}
+}
+
+func TestLooksLikeJSON(t *testing.T) {
+	large := append([]byte(`[{"a":`), bytes.Repeat([]byte("1,"), jsonSniffLength)...)
+	tests := []struct {
+		body []byte
+		want bool
+	}{
+		{[]byte(`{"a":1}`), true},
+		{[]byte(" [1,2] "), true},
+		{[]byte(`{"a":`), false},
+		{[]byte("[::1]:8080"), false},
+		{[]byte("hello"), false},
+		{large, true},
+	}
+	for _, tt := range tests {
+		if got := looksLikeJSON(tt.body); got != tt.want {
+			t.Errorf("looksLikeJSON(%.20q) = %v，期望 %v", tt.body, got, tt.want)
+		}
+	}
+}
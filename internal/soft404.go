@@ -0,0 +1,235 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// 软 404 检测相关常量
+const (
+	MaxSoftNotFoundProbes    = 3   // 每个 origin 最多探测的随机路径数
+	SoftNotFoundLengthRatio  = 0.8 // 与基线文本长度比例下限
+	softNotFoundRandomLength = 12  // 随机路径段的字节数（hex 后 24 个字符）
+)
+
+// SoftNotFoundDetector 软 404 检测器
+// 对每个 origin 请求几个随机的、肯定不存在的路径，把 2xx 响应作为 "不存在页面" 的基线，
+// 输入 URL 的响应和基线一致（HTML 指纹相同或 SimHash 接近）就判定为软 404。
+// 跳转到其他路径（首页、登录页）后才得到 2xx 的、和首页一致的（SPA 对所有路径返回同一个页面）不作为基线，
+// 否则首页或所有 SPA 路由都会被判定为软 404
+type SoftNotFoundDetector struct {
+	baselines  map[string][]softNotFoundBaseline // origin -> 基线
+	maxSimDist int                               // SimHash 最大汉明距离
+}
+
+// softNotFoundBaseline 随机路径响应的指纹
+type softNotFoundBaseline struct {
+	ProbeURL   string
+	HtmlFP     HtmlFingerprint
+	SimHash    uint64
+	TextLength int
+}
+
+// ProbeSoftNotFound 对输入涉及的每个 origin 探测随机路径，建立软 404 基线
+// probes 为每个 origin 的探测次数，maxSimDist 为判定一致的 SimHash 最大汉明距离
+func ProbeSoftNotFound(ctx context.Context, fetcher *Fetcher, items []URLItem, probes, parallel, maxSimDist int) *SoftNotFoundDetector {
+	logger := GetLogger()
+
+	if probes > MaxSoftNotFoundProbes {
+		probes = MaxSoftNotFoundProbes
+	}
+
+	detector := &SoftNotFoundDetector{
+		baselines:  make(map[string][]softNotFoundBaseline),
+		maxSimDist: maxSimDist,
+	}
+	if probes <= 0 {
+		return detector
+	}
+
+	// 收集所有 origin 并生成探测 URL，每个 origin 另外请求一次根路径用于排除首页
+	seen := make(map[string]bool)
+	var probeItems, rootItems []URLItem
+	for _, item := range items {
+		origin := item.targetScope(OriginKey(item.NormalizedURL))
+		if origin == "" || seen[origin] {
+			continue
+		}
+		seen[origin] = true
+
+		u, err := url.Parse(item.NormalizedURL)
+		if err != nil {
+			continue
+		}
+		rootURL := fmt.Sprintf("%s://%s/", u.Scheme, u.Host)
+		rootItems = append(rootItems, URLItem{
+			RawURL:        rootURL,
+			NormalizedURL: rootURL,
+			TargetIP:      item.TargetIP,
+			VHost:         item.VHost,
+		})
+		for i := 0; i < probes; i++ {
+			probeURL := fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, randomNotFoundPath(i))
+			probeItems = append(probeItems, URLItem{
+				RawURL:        probeURL,
+				NormalizedURL: probeURL,
+				TargetIP:      item.TargetIP,
+				VHost:         item.VHost,
+			})
+		}
+	}
+
+	logger.Info("软 404 探测：%d 个 origin，共 %d 个随机路径", len(seen), len(probeItems))
+	results := fetcher.FetchBatch(ctx, append(rootItems, probeItems...), parallel)
+
+	// 各 origin 根路径的响应
+	roots := make(map[string]softNotFoundBaseline)
+	for _, fr := range results[:len(rootItems)] {
+		if root, ok := newSoftNotFoundBaseline(fr); ok {
+			roots[fr.targetScope(OriginKey(fr.NormalizedURL))] = root
+		}
+	}
+
+	dropped := 0
+	for _, fr := range results[len(rootItems):] {
+		candidate, ok := newSoftNotFoundBaseline(fr)
+		if !ok {
+			continue
+		}
+
+		// 跳转到其他路径后才得到 2xx（例如跳到首页、登录页），不是"不存在页面"本身
+		if !sameProbePath(fr.NormalizedURL, fr.FinalURL) {
+			dropped++
+			continue
+		}
+
+		// 和首页一致（SPA 对任意路径返回同一个页面），作为基线会把首页和所有路由都判定为软 404
+		origin := fr.targetScope(OriginKey(fr.NormalizedURL))
+		if root, ok := roots[origin]; ok && candidate.matches(root, detector.maxSimDist) {
+			dropped++
+			continue
+		}
+
+		detector.baselines[origin] = append(detector.baselines[origin], candidate)
+	}
+
+	logger.Info("软 404 探测完成，%d 个 origin 对不存在的路径返回 2xx，%d 个跳转或与首页一致的探测结果不作为基线", len(detector.baselines), dropped)
+	return detector
+}
+
+// newSoftNotFoundBaseline 从 2xx 响应生成指纹，非 2xx 或没有内容时返回 false
+// 只有 2xx 的响应才是软 404；真 404 由状态码规则处理
+func newSoftNotFoundBaseline(fr FetchResult) (softNotFoundBaseline, bool) {
+	if fr.Error != "" || fr.StatusCode < 200 || fr.StatusCode >= 300 {
+		return softNotFoundBaseline{}, false
+	}
+	body := fr.RawHTML
+	if len(body) == 0 {
+		body = fr.RawBody
+	}
+	if len(body) == 0 {
+		return softNotFoundBaseline{}, false
+	}
+
+	simHash, textLength := softNotFoundSimHash(body)
+	return softNotFoundBaseline{
+		ProbeURL:   fr.NormalizedURL,
+		HtmlFP:     FingerprintHTML(body),
+		SimHash:    simHash,
+		TextLength: textLength,
+	}, true
+}
+
+// sameProbePath 判断最终 URL 的 path 是否还是探测的 path（没有跳转到其他路径）
+func sameProbePath(probeURL, finalURL string) bool {
+	if finalURL == "" {
+		return true
+	}
+	p, err1 := url.Parse(probeURL)
+	f, err2 := url.Parse(finalURL)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	return strings.TrimSuffix(p.EscapedPath(), "/") == strings.TrimSuffix(f.EscapedPath(), "/")
+}
+
+// matches 判断两个响应是否一致：HTML 指纹完全相同，或长度接近且 SimHash 距离不超过 maxSimDist
+// （页面里可能回显了请求路径，指纹不会完全相同）
+func (b softNotFoundBaseline) matches(other softNotFoundBaseline, maxSimDist int) bool {
+	if b.HtmlFP.Length > 0 && b.HtmlFP == other.HtmlFP {
+		return true
+	}
+	if b.TextLength == 0 || other.TextLength == 0 {
+		return false
+	}
+	lenA, lenB := b.TextLength, other.TextLength
+	if lenA > lenB {
+		lenA, lenB = lenB, lenA
+	}
+	if float64(lenA)/float64(lenB) < SoftNotFoundLengthRatio {
+		return false
+	}
+	return hammingDistance64(b.SimHash, other.SimHash) <= maxSimDist
+}
+
+// Mark 判断抓取结果是否为软 404，并写入 fr.SoftNotFound
+// 需要在释放原始内容之前调用
+func (d *SoftNotFoundDetector) Mark(fr *FetchResult) bool {
+	if d == nil || len(d.baselines) == 0 {
+		return false
+	}
+
+	baselines := d.baselines[fr.targetScope(OriginKey(fr.NormalizedURL))]
+	if len(baselines) == 0 {
+		return false
+	}
+
+	page, ok := newSoftNotFoundBaseline(*fr)
+	if !ok {
+		return false
+	}
+
+	for _, b := range baselines {
+		if page.matches(b, d.maxSimDist) {
+			fr.SoftNotFound = true
+			return true
+		}
+	}
+
+	return false
+}
+
+// softNotFoundSimHash 计算响应文本的 SimHash 和文本长度
+func softNotFoundSimHash(body []byte) (uint64, int) {
+	cleaned := cleanText(extractSimpleText(body))
+	return computeSimHash(cleaned), utf8.RuneCountInString(cleaned)
+}
+
+// randomNotFoundPath 生成一个肯定不存在的随机路径
+// 不同的探测使用不同的形式，覆盖按扩展名或目录路由的站点
+func randomNotFoundPath(i int) string {
+	token := randomHex(softNotFoundRandomLength)
+	switch i % MaxSoftNotFoundProbes {
+	case 0:
+		return "/" + token
+	case 1:
+		return "/" + token + ".html"
+	default:
+		return "/" + token[:8] + "/" + token[8:] + "/"
+	}
+}
+
+// randomHex 生成 n 字节的随机 hex 字符串
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		// crypto/rand 几乎不会失败，失败时退化为固定的不太可能存在的路径
+		return "websitesimilar-not-found-probe"
+	}
+	return hex.EncodeToString(buf)
+}
@@ -0,0 +1,151 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	soft404TestHome     = `<html><head><title>首页</title></head><body><h1>欢迎光临</h1><p>这是站点首页，介绍公司的产品、服务、新闻和联系方式，内容较长。</p><ul><li>产品</li><li>服务</li><li>新闻</li></ul></body></html>`
+	soft404TestNotFound = `<html><head><title>页面不存在</title></head><body><div class="error"><h2>抱歉，您访问的页面不存在或已被删除</h2><a href="/">返回首页</a></div></body></html>`
+	soft404TestArticle  = `<html><head><title>新闻</title></head><body><article><h1>季度报告发布</h1><p>本季度营收同比增长，新产品线上线，用户数量持续上升，详情见附件。</p><table><tr><td>营收</td></tr></table></article></body></html>`
+)
+
+// soft404TestFetch 抓取测试服务器上的路径
+func soft404TestFetch(t *testing.T, fetcher *Fetcher, base, path string) *FetchResult {
+	t.Helper()
+	u := base + path
+	results := fetcher.FetchBatch(context.Background(), []URLItem{{RawURL: u, NormalizedURL: u}}, 1)
+	return &results[0]
+}
+
+func TestProbeSoftNotFound(t *testing.T) {
+	tests := []struct {
+		name      string
+		handler   http.HandlerFunc
+		baselines int
+		soft404   map[string]bool // 路径 -> 是否应判定为软 404
+	}{
+		{
+			name: "不存在的路径跳转到首页",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/":
+					fmt.Fprint(w, soft404TestHome)
+				case "/news/1":
+					fmt.Fprint(w, soft404TestArticle)
+				default:
+					http.Redirect(w, r, "/", http.StatusFound)
+				}
+			},
+			baselines: 0,
+			soft404:   map[string]bool{"/": false, "/news/1": false},
+		},
+		{
+			name: "不存在的路径跳转到登录页",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/":
+					fmt.Fprint(w, soft404TestHome)
+				case "/login":
+					fmt.Fprint(w, soft404TestNotFound)
+				default:
+					http.Redirect(w, r, "/login", http.StatusFound)
+				}
+			},
+			baselines: 0,
+			soft404:   map[string]bool{"/login": false},
+		},
+		{
+			name: "SPA 对所有路径返回首页",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, soft404TestHome)
+			},
+			baselines: 0,
+			soft404:   map[string]bool{"/": false, "/app/settings": false},
+		},
+		{
+			name: "不存在的路径返回 200 错误页",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/":
+					fmt.Fprint(w, soft404TestHome)
+				case "/news/1":
+					fmt.Fprint(w, soft404TestArticle)
+				default:
+					fmt.Fprint(w, soft404TestNotFound)
+				}
+			},
+			baselines: 2,
+			soft404:   map[string]bool{"/": false, "/news/1": false, "/old/page": true},
+		},
+		{
+			name: "不存在的路径返回真 404",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/" {
+					w.WriteHeader(http.StatusNotFound)
+				}
+				fmt.Fprint(w, soft404TestHome)
+			},
+			baselines: 0,
+			soft404:   map[string]bool{"/": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			fetcher := NewFetcher(FetcherConfig{Timeout: 5 * time.Second, MaxRedirects: 5})
+			items := []URLItem{{RawURL: server.URL + "/", NormalizedURL: server.URL + "/"}}
+			detector := ProbeSoftNotFound(context.Background(), fetcher, items, 2, 2, DefaultThresholds().TextSimHashMaxDist)
+
+			got := 0
+			for _, b := range detector.baselines {
+				got += len(b)
+			}
+			if got != tt.baselines {
+				t.Fatalf("基线数量 = %d，期望 %d", got, tt.baselines)
+			}
+
+			for path, want := range tt.soft404 {
+				fr := soft404TestFetch(t, fetcher, server.URL, path)
+				if got := detector.Mark(fr); got != want || fr.SoftNotFound != want {
+					t.Errorf("Mark(%s) = %v，期望 %v", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSameProbePath(t *testing.T) {
+	tests := []struct {
+		probe, final string
+		want         bool
+	}{
+		{"https://a.com/abc", "", true},
+		{"https://a.com/abc", "https://a.com/abc", true},
+		{"https://a.com/abc", "https://a.com/abc/", true},
+		{"https://a.com/abc", "https://a.com/", false},
+		{"https://a.com/abc", "https://a.com/login?next=/abc", false},
+		{"http://a.com/abc", "https://a.com/abc", true},
+	}
+	for _, tt := range tests {
+		if got := sameProbePath(tt.probe, tt.final); got != tt.want {
+			t.Errorf("sameProbePath(%q, %q) = %v，期望 %v", tt.probe, tt.final, got, tt.want)
+		}
+	}
+}
+
+func TestRandomNotFoundPathUnique(t *testing.T) {
+	a, b := randomNotFoundPath(0), randomNotFoundPath(0)
+	if a == b || !strings.HasPrefix(a, "/") {
+		t.Errorf("randomNotFoundPath 应生成不同的随机路径：%q %q", a, b)
+	}
+}
@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// 静态 DOM 统计相关常量
+const (
+	staticMaxPaths = 5000 // 与 getDOMStatsJS 的 maxPaths 一致
+)
+
+// ExtractStaticHTMLFeatures 不经过 headless Chrome，直接从原始 HTML 提取特征（HTTP-only 模式）
+// 文本 SimHash、标签计数、路径计数和深度分布用 goquery 静态计算，
+// 统计口径与渲染时执行的 getDOMStatsJS 保持一致；视觉和行为维度标记为不可用
+func ExtractStaticHTMLFeatures(rawHTML []byte) (*PageFeatures, error) {
+	if len(rawHTML) == 0 {
+		return nil, fmt.Errorf("HTML 内容为空")
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(rawHTML))
+	if err != nil {
+		return nil, fmt.Errorf("解析 HTML 失败: %w", err)
+	}
+
+	features := &PageFeatures{
+		Category:            ContentCategoryHTML,
+		TagCount:            make(map[string]int),
+		PathCount:           make(map[string]int),
+		VisualUnavailable:   true,
+		BehaviorUnavailable: true,
+	}
+
+	// 文本特征
+	extractTextFeaturesFromDoc(features, doc)
+
+	// DOM 结构特征
+	stats := computeStaticDOMStats(doc)
+	features.DOMNodeCount = stats.DOMNodeCount
+	features.TextNodeCount = stats.TextNodeCount
+	features.TagCount = stats.TagCount
+	features.DepthHist = stats.DepthHist
+	features.PathCount = stats.PathCount
+
+	return features, nil
+}
+
+// computeStaticDOMStats 静态计算 DOM 统计信息
+// 按文档顺序遍历所有元素（等价于 document.getElementsByTagName('*')）
+func computeStaticDOMStats(doc *goquery.Document) DOMStats {
+	stats := DOMStats{
+		TagCount:  make(map[string]int),
+		PathCount: make(map[string]int),
+	}
+
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			tag := strings.ToLower(n.Data)
+			stats.TagCount[tag]++
+
+			d := elementDepth(n)
+			for len(stats.DepthHist) <= d {
+				stats.DepthHist = append(stats.DepthHist, 0)
+			}
+			stats.DepthHist[d]++
+
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.TextNode && strings.TrimSpace(c.Data) != "" {
+					stats.TextNodeCount++
+				}
+			}
+
+			if stats.DOMNodeCount < staticMaxPaths {
+				stats.PathCount[elementPath(n)]++
+			}
+			stats.DOMNodeCount++
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+
+	for _, n := range doc.Nodes {
+		walk(n)
+	}
+
+	return stats
+}
+
+// elementDepth 元素深度（父元素个数，html 为 0）
+func elementDepth(n *html.Node) int {
+	d := 0
+	for p := n.Parent; p != nil && p.Type == html.ElementNode; p = p.Parent {
+		d++
+	}
+	return d
+}
+
+// elementPath 元素路径，与 getDOMStatsJS 中的 getPath 一致
+func elementPath(n *html.Node) string {
+	var parts []string
+	for el := n; el != nil && el.Type == html.ElementNode && strings.ToLower(el.Data) != "html"; el = el.Parent {
+		parts = append(parts, strings.ToLower(el.Data))
+	}
+	parts = append(parts, "body", "html")
+
+	// 反转
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+	return strings.Join(parts, ">")
+}
@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractStaticHTMLFeatures(t *testing.T) {
+	raw := []byte(`<html><head><title>t</title></head><body><div><p>第一段：本季度营收同比增长，新产品线上线。</p><p>第二段：用户数量持续上升，详情见附件。</p></div></body></html>`)
+	f, err := ExtractStaticHTMLFeatures(raw)
+	if err != nil {
+		t.Fatalf("提取失败: %v", err)
+	}
+
+	if f.Category != ContentCategoryHTML || !f.VisualUnavailable || !f.BehaviorUnavailable {
+		t.Errorf("HTTP-only 特征应为 HTML 且视觉、行为维度不可用: %+v", f)
+	}
+
+	// html head title body div p p
+	if f.DOMNodeCount != 7 {
+		t.Errorf("DOMNodeCount = %d，期望 7", f.DOMNodeCount)
+	}
+	if f.TextNodeCount != 3 {
+		t.Errorf("TextNodeCount = %d，期望 3", f.TextNodeCount)
+	}
+	wantTags := map[string]int{"html": 1, "head": 1, "title": 1, "body": 1, "div": 1, "p": 2}
+	if !reflect.DeepEqual(f.TagCount, wantTags) {
+		t.Errorf("TagCount = %v，期望 %v", f.TagCount, wantTags)
+	}
+	if want := []int{1, 2, 2, 2}; !reflect.DeepEqual(f.DepthHist, want) {
+		t.Errorf("DepthHist = %v，期望 %v", f.DepthHist, want)
+	}
+	// 路径口径与 getDOMStatsJS 的 getPath 一致
+	if got := f.PathCount["html>body>body>div>p"]; got != 2 {
+		t.Errorf("PathCount[html>body>body>div>p] = %d，期望 2（全部 %v）", got, f.PathCount)
+	}
+	if f.TextSimHash == 0 || f.TextLength == 0 {
+		t.Errorf("文本特征为空: simhash=%x length=%d", f.TextSimHash, f.TextLength)
+	}
+}
+
+func TestExtractStaticHTMLFeaturesEmpty(t *testing.T) {
+	if _, err := ExtractStaticHTMLFeatures(nil); err == nil {
+		t.Error("空 HTML 应返回错误")
+	}
+}
+
+func TestExtractStaticHTMLFeaturesStable(t *testing.T) {
+	raw := []byte(`<html><body><ul><li>a</li><li>b</li></ul></body></html>`)
+	a, _ := ExtractStaticHTMLFeatures(raw)
+	b, _ := ExtractStaticHTMLFeatures(raw)
+	if !reflect.DeepEqual(a, b) {
+		t.Error("同一 HTML 两次提取的特征应完全相同")
+	}
+}
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"strings"
+	"unicode"
+)
+
+// 分词相关常量
+const (
+	MinWordTokenLength = 2 // 拉丁等以空格分词的文字，少于 2 个字符的词丢弃
+	CJKShingleMin      = 2 // CJK 字符 n-gram 最小长度（bigram）
+	CJKShingleMax      = 3 // CJK 字符 n-gram 最大长度（trigram）
+)
+
+// runeClass 字符所属的分词类别
+type runeClass int
+
+const (
+	runeClassSep  runeClass = iota // 空白、标点等分隔符
+	runeClassWord                  // 拉丁字母、数字等以空格分词的文字
+	runeClassCJK                   // 中日韩文字，没有空格分词
+)
+
+// classifyRune 判断字符的分词类别
+func classifyRune(r rune) runeClass {
+	if isCJKRune(r) {
+		return runeClassCJK
+	}
+	if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) {
+		return runeClassWord
+	}
+	return runeClassSep
+}
+
+// isCJKRune 判断是否为中日韩文字（汉字、平假名、片假名、韩文）
+func isCJKRune(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
+
+// tokenize 把文本切分为 SimHash 使用的 token
+// 按文字类别切成连续片段：
+//   - 拉丁等文字保留整词，过短的词丢弃
+//   - 中日韩文字没有空格，整段当一个 token 时改一个字指纹就全变，
+//     所以按字符切 bigram + trigram shingle；只有一个字的片段直接作为 token
+func tokenize(text string) []string {
+	var tokens []string
+	var run []rune
+	class := runeClassSep
+
+	flush := func() {
+		switch class {
+		case runeClassWord:
+			if len(run) >= MinWordTokenLength {
+				tokens = append(tokens, strings.ToLower(string(run)))
+			}
+		case runeClassCJK:
+			tokens = append(tokens, cjkShingles(run)...)
+		}
+		run = run[:0]
+	}
+
+	for _, r := range text {
+		c := classifyRune(r)
+		if c != class {
+			flush()
+			class = c
+		}
+		if c != runeClassSep {
+			run = append(run, r)
+		}
+	}
+	flush()
+
+	return tokens
+}
+
+// cjkShingles 生成 CJK 片段的字符 n-gram
+func cjkShingles(run []rune) []string {
+	if len(run) == 0 {
+		return nil
+	}
+	if len(run) < CJKShingleMin {
+		return []string{string(run)}
+	}
+
+	var shingles []string
+	for n := CJKShingleMin; n <= CJKShingleMax; n++ {
+		for i := 0; i+n <= len(run); i++ {
+			shingles = append(shingles, string(run[i:i+n]))
+		}
+	}
+	return shingles
+}
+
+// containsCJK 判断文本中是否含有中日韩文字
+func containsCJK(s string) bool {
+	for _, r := range s {
+		if isCJKRune(r) {
+			return true
+		}
+	}
+	return false
+}
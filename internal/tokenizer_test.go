@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"拉丁文按词并转小写", "Hello, World!", []string{"hello", "world"}},
+		{"过短的词丢弃", "a an the", []string{"an", "the"}},
+		{"数字作为词", "page 42", []string{"page", "42"}},
+		{"单个 CJK 字符", "中", []string{"中"}},
+		{"两个 CJK 字符只有 bigram", "中文", []string{"中文"}},
+		{"CJK bigram 和 trigram", "中文分词", []string{"中文", "文分", "分词", "中文分", "文分词"}},
+		{"标点分隔 CJK 片段", "你好，世界", []string{"你好", "世界"}},
+		{"中英混排", "使用Go语言", []string{"使用", "go", "语言"}},
+		{"日文假名", "ひらがな", []string{"ひら", "らが", "がな", "ひらが", "らがな"}},
+		{"韩文", "한국어", []string{"한국", "국어", "한국어"}},
+		{"空文本", "", nil},
+		{"只有分隔符", " ,.!? ", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tokenize(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("tokenize(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCJKShingles(t *testing.T) {
+	tests := []struct {
+		run  string
+		want []string
+	}{
+		{"", nil},
+		{"字", []string{"字"}},
+		{"汉字", []string{"汉字"}},
+		{"简体汉字", []string{"简体", "体汉", "汉字", "简体汉", "体汉字"}},
+	}
+
+	for _, tt := range tests {
+		if got := cjkShingles([]rune(tt.run)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("cjkShingles(%q) = %q, want %q", tt.run, got, tt.want)
+		}
+	}
+}
+
+func TestComputeSimHashCJKEdit(t *testing.T) {
+	base := "我们公司成立于二零零八年，专注于为企业客户提供网络安全咨询、渗透测试和安全运维服务，服务客户遍布全国各地。"
+	edited := "我们公司成立于二零零九年，专注于为企业客户提供网络安全咨询、渗透测试和安全运维服务，服务客户遍布全国各地。"
+	other := "今天天气晴朗，适合出门散步。公园里的花都开了，很多人带着孩子在草地上放风筝，到处都是欢声笑语。"
+
+	if computeSimHash(base) != computeSimHash(base) {
+		t.Fatal("相同文本的 SimHash 应该相同")
+	}
+
+	editDist := hammingDistance64(computeSimHash(base), computeSimHash(edited))
+	otherDist := hammingDistance64(computeSimHash(base), computeSimHash(other))
+	if editDist >= otherDist {
+		t.Errorf("改动一个字的距离 %d 应小于不相关文本的距离 %d", editDist, otherDist)
+	}
+}
@@ -7,13 +7,45 @@ import (
 // Options 配置选项
 type Options struct {
 	URLs           []string
-	Parallel       int // HTTP 抓取并发数
-	RenderParallel int // headless 渲染并发数
+	Input          InputConfig // URL 输入格式
+	Parallel       int         // HTTP 抓取并发数
+	RenderParallel int         // headless 渲染并发数
 	HTTPTimeout    time.Duration
 	PerPageTimeout time.Duration
 	BatchSize      int
 	SimThreshold   float64
-	OutputFormat   string // "json" or "csv"
+	OutputFormat   string     // "json" or "csv"
+	LSHBands       int        // LSH 分段数，<= 0 时按预筛选阈值自动选择
+	CrossHost      bool       // 跨 host 聚类：内容分桶不区分 host，镜像站、多 IP 部署可以归为一类
+	Thresholds     Thresholds // 相似度判定阈值和权重，零值时使用默认值
+	Rules          []*Rule    // 规则聚类使用的规则（已按优先级排序），nil 时使用内置规则
+
+	SoftNotFoundProbes int  // 软 404 检测：每个 origin 请求的随机不存在路径数，0 表示关闭
+	SeedPerOrigin      int  // robots.txt/sitemap 发现：每个 origin 最多加入的 URL 数，0 表示关闭
+	NoRender           bool // HTTP-only 模式：不启动 headless Chrome，直接从原始 HTML 静态提取特征
+
+	ChromeURL   string   // 远程 Chrome 的 DevTools 地址（ws://... 或 http://host:port），为空时启动本地浏览器
+	ChromePath  string   // 本地 Chrome 可执行文件路径
+	ChromeFlags []string // 额外的 Chrome 启动参数（name 或 name=value）
+
+	RecyclePages int // 每个浏览器实例渲染多少页后重启，0 表示不按页数回收
+	MaxChromeRSS int // 本地 Chrome 进程树 RSS 上限（MB），0 表示不检查
+
+	Proxies []string // 上游代理（http/https/socks5，可带认证），多个时按请求轮询
+
+	VHosts []string // 虚拟主机扫描：候选主机名，对每个 IP 输入分别以这些主机名请求
+
+	Request RequestConfig // 自定义请求头、cookie 文件和 User-Agent
+
+	HostConcurrency int           // 每个 host 的最大并发（抓取和渲染合计），0 表示不限制
+	HostRPS         float64       // 每个 host 每秒最多发起的请求数，0 表示不限制
+	HostJitter      float64       // 请求间隔的随机抖动比例（0-1）
+	MaxBackoff      time.Duration // 429/503/WAF 拦截后的最大退避时间，0 表示不退避
+
+	Retry RetryConfig // 抓取和渲染失败的重试策略
+
+	OutputHeaders bool // 报告中输出规范化后的响应头
+	NoFavicon     bool // 不下载 favicon
 }
 
 // URLItem URL 项
@@ -21,6 +53,16 @@ type URLItem struct {
 	ID            int
 	RawURL        string
 	NormalizedURL string
+	TargetIP      string // 虚拟主机扫描：实际连接的 IP，为空时按 URL 的 host 解析
+	VHost         string // 虚拟主机扫描：Host 头和 TLS SNI 使用的主机名，为空表示不是虚拟主机 URL
+
+	Meta map[string]interface{} // 输入中附带的元数据（JSON Lines 的其他字段、CSV 的其他列、nmap 的端口信息）
+
+	Aliases []string // 展开或规范化后与本 URL 相同、已合并的其他原始输入
+	Source  string   // URL 来源：seed（输入）、sitemap、robots
+
+	needsScheme bool  // 输入没有写 scheme，需要探测 http/https
+	maxBodySize int64 // 响应体最多读取的字节数，0 表示 MaxHTMLSize
 }
 
 // ContentCategory 内容类型分类
@@ -34,19 +76,61 @@ const (
 	ContentCategoryEmpty  ContentCategory = "empty"  // 空内容或错误
 )
 
+// 重定向 hop 类型
+const (
+	HopTypeStart         = "start"          // 起点（规范化后的 URL）
+	HopTypeHTTP          = "http"           // HTTP 3xx 重定向
+	HopTypeMetaRefresh   = "meta_refresh"   // <meta http-equiv="refresh">
+	HopTypeHeaderRefresh = "header_refresh" // Refresh 响应头
+	HopTypeJS            = "js"             // JS 跳转（location.href、location.replace 等）
+	HopTypeBrowser       = "browser"        // 浏览器中其他原因的导航
+)
+
+// RedirectHop 重定向链中的一跳
+type RedirectHop struct {
+	URL  string `json:"url"`
+	Type string `json:"type"` // 到达这一跳的方式
+}
+
 // FetchResult HTTP 抓取结果
 type FetchResult struct {
 	URLItem
-	FinalURL        string   // 跟随重定向后的最终 URL
-	RedirectChain   []string // 顺序记录每一个 hop
-	StatusCode      int
-	ContentLength   int64
-	ContentType     string
-	ContentCategory ContentCategory // 内容类型分类
-	Error           string
-	RawHTML         []byte // 最终响应的 HTML（仅 text/html）
-	RawBody         []byte // 非 HTML 内容的原始 body
-	Title           string // 页面标题（从 HTML 中提取）
+	FinalURL         string   // 跟随重定向后的最终 URL
+	RedirectChain    []string // 顺序记录每一个 hop
+	StatusCode       int
+	ContentLength    int64
+	ContentType      string
+	ContentCategory  ContentCategory // 内容类型分类（综合声明的类型和嗅探结果）
+	DeclaredCategory ContentCategory // 按 Content-Type 响应头得到的类型
+	DetectedCategory ContentCategory // 按响应内容嗅探得到的类型
+	Charset          string          // 检测到的字符集（HTML 和文本类），内容已转换为 UTF-8
+	CharsetSource    string          // 字符集来源：bom、header、meta 或 sniff
+	Error            string
+	RawHTML          []byte     // 最终响应的 HTML（仅 HTML 类内容）
+	RawBody          []byte     // 非 HTML 内容的原始 body
+	OriginalBody     []byte     // 转换为 UTF-8 之前的响应体，只在转换改变了内容时保存（favicon 哈希要用原始字节）
+	Title            string     // 页面标题（从 HTML 中提取）
+	SoftNotFound     bool       // 软 404：响应与随机不存在路径的响应一致
+	Attempts         int        // HTTP 抓取尝试次数（含重试）
+	ErrorClass       ErrorClass // 最终失败原因分类（抓取失败，或抓取成功但渲染失败）
+	RenderAttempts   int        // 渲染尝试次数（含重试），未渲染时为 0
+	RenderError      string     // 渲染失败的错误信息
+
+	// 响应头（去掉易变头，Set-Cookie 只保留名称）和指纹哈希，没有可用的头时哈希为 0
+	Headers    map[string]string
+	HeaderHash uint64
+
+	Favicon *FaviconInfo // 页面所属站点的 favicon，没有或下载失败时为 nil
+	Cert    *CertInfo    // 最终响应的 TLS 叶子证书，非 HTTPS 时为 nil
+
+	// 与 RedirectChain 一一对应的 hop 及类型，渲染时浏览器中的 meta refresh、JS 跳转追加在 HTTP 重定向之后，
+	// 此时 FinalURL 更新为浏览器最终停留的 URL
+	RedirectHops []RedirectHop
+
+	// 规则聚类信息（释放原始内容前由 PrepareRuleMatches 计算）
+	HtmlFP        HtmlFingerprint
+	MatchedRules  []string // 命中条件的规则 ID
+	rulesPrepared bool
 }
 
 // PageFeatures 页面特征
@@ -74,6 +158,14 @@ type PageFeatures struct {
 	TTFB             float64 // Time To First Byte (ms)
 	DOMContentLoaded float64 // DOMContentLoaded 时间 (ms)
 	LoadEvent        float64 // Load 事件时间 (ms)
+
+	// 响应头特征（参与指纹的头，见 HeaderFingerprint）
+	HeaderSet  map[string]string
+	HeaderHash uint64
+
+	// 维度可用性（HTTP-only 模式下没有截图和性能数据）
+	VisualUnavailable   bool
+	BehaviorUnavailable bool
 }
 
 // PageWithFeatures 带特征的页面
@@ -84,40 +176,99 @@ type PageWithFeatures struct {
 
 // URLReport URL 报告
 type URLReport struct {
-	ID                    int      `json:"id"`
-	URL                   string   `json:"url"`
-	NormalizedURL         string   `json:"normalized_url"`
-	FinalURL              string   `json:"final_url"`
-	RedirectChain         []string `json:"redirect_chain"`
-	StatusCode            int      `json:"status_code"`
-	ContentLength         int64    `json:"content_length"`
-	ContentType           string   `json:"content_type"`
-	Error                 string   `json:"error"`
-	Title                 string   `json:"title"`
-	ClusterID             string   `json:"cluster_id"`
-	IsCanonical           bool     `json:"is_canonical"`
-	SimilarityToCanonical float64  `json:"similarity_to_canonical"`
-	ContentSim            float64  `json:"content_sim"`
-	StructureSim          float64  `json:"structure_sim"`
-	VisualSim             float64  `json:"visual_sim"`
-	BehaviorSim           float64  `json:"behavior_sim"`
+	ID            int    `json:"id"`
+	URL           string `json:"url"`
+	NormalizedURL string `json:"normalized_url"`
+	FinalURL      string `json:"final_url"`
+	TargetIP      string `json:"target_ip,omitempty"` // 虚拟主机扫描：实际连接的 IP
+	VHost         string `json:"vhost,omitempty"`     // 虚拟主机扫描：请求使用的主机名
+	Source        string `json:"source"`              // URL 来源：seed（输入）、sitemap、robots
+
+	InputMeta             map[string]interface{} `json:"input_meta,omitempty"`    // 输入中附带的元数据
+	InputAliases          []string               `json:"input_aliases,omitempty"` // 展开或规范化后与本 URL 相同、已合并的其他原始输入
+	RedirectChain         []string               `json:"redirect_chain"`
+	StatusCode            int                    `json:"status_code"`
+	ContentLength         int64                  `json:"content_length"`
+	ContentType           string                 `json:"content_type"`
+	ContentCategory       string                 `json:"content_category"`  // 实际使用的内容类型：html、text、image、binary、empty
+	DeclaredCategory      string                 `json:"declared_category"` // 按 Content-Type 响应头得到的类型
+	DetectedCategory      string                 `json:"detected_category"` // 按响应内容嗅探得到的类型
+	Charset               string                 `json:"charset"`           // 检测到的字符集，HTML 和文本类内容有值
+	CharsetSource         string                 `json:"charset_source"`    // 字符集来源：bom、header、meta 或 sniff
+	Error                 string                 `json:"error"`
+	ErrorClass            string                 `json:"error_class"`     // 最终失败原因分类，见 ErrorClass
+	Attempts              int                    `json:"attempts"`        // HTTP 抓取尝试次数（含重试）
+	RenderAttempts        int                    `json:"render_attempts"` // 渲染尝试次数（含重试），未渲染时为 0
+	RenderError           string                 `json:"render_error"`    // 渲染失败的错误信息
+	Title                 string                 `json:"title"`
+	ClusterID             string                 `json:"cluster_id"`
+	ClusterSource         string                 `json:"cluster_source"`             // content（内容聚类）、rule（规则聚类）或空
+	RuleID                string                 `json:"rule_id"`                    // 规则聚类的规则 ID
+	RulePriority          int                    `json:"rule_priority"`              // 规则聚类的规则优先级
+	MatchReason           string                 `json:"match_reason"`               // 内容聚类命中的判定分支
+	RuleAnnotations       []RuleAnnotation       `json:"rule_annotations,omitempty"` // 标注型规则的分组（如同 favicon 的站点），不影响 cluster_id
+	IsCanonical           bool                   `json:"is_canonical"`
+	SimilarityToCanonical float64                `json:"similarity_to_canonical"`
+	ContentSim            float64                `json:"content_sim"`
+	StructureSim          float64                `json:"structure_sim"`
+	VisualSim             float64                `json:"visual_sim"`
+	BehaviorSim           float64                `json:"behavior_sim"`
+	HeaderSim             float64                `json:"header_sim"`
+	HeaderFingerprint     string                 `json:"header_fingerprint"` // 响应头指纹，没有可用的头时为空
+
+	Headers map[string]string `json:"headers,omitempty"` // 规范化后的响应头，-output-headers 开启时输出
+
+	RedirectHops []RedirectHop `json:"redirect_hops"` // 带类型的重定向链（与 redirect_chain 一一对应）
+
+	FaviconURL   string `json:"favicon_url"`   // favicon 地址，内联的为 "data:"
+	FaviconMMH3  string `json:"favicon_mmh3"`  // Shodan 兼容的 mmh3 哈希（http.favicon.hash），没有 favicon 时为空
+	FaviconMD5   string `json:"favicon_md5"`   // favicon 的 MD5
+	FaviconPHash string `json:"favicon_phash"` // favicon 的感知哈希（16 位十六进制）
+
+	CertSHA256    string   `json:"cert_sha256"`     // TLS 叶子证书 SHA-256 指纹，非 HTTPS 时以下证书字段都为空
+	CertSubjectCN string   `json:"cert_subject_cn"` // 证书使用者 CN
+	CertSANs      []string `json:"cert_sans"`       // 证书使用者可选名称（DNS 名称和 IP）
+	CertIssuer    string   `json:"cert_issuer"`     // 证书颁发者 DN
+	CertNotBefore string   `json:"cert_not_before"` // 证书有效期开始（RFC 3339）
+	CertNotAfter  string   `json:"cert_not_after"`  // 证书有效期结束（RFC 3339）
 }
 
+// 聚类来源
+const (
+	ClusterSourceContent = "content" // 内容相似度聚类
+	ClusterSourceRule    = "rule"    // 规则聚类
+)
+
 // ClusterInfo 聚类信息
 type ClusterInfo struct {
-	ClusterID    string `json:"cluster_id"`
-	CanonicalURL string `json:"canonical_url"`
-	MemberIDs    []int  `json:"member_ids"`
+	ClusterID    string   `json:"cluster_id"`
+	CanonicalURL string   `json:"canonical_url"`
+	MemberIDs    []int    `json:"member_ids"`
+	Origins      []string `json:"origins"` // 成员涉及的所有 origin（scheme://host:port）
 }
 
 // MetaInfo 元信息
 type MetaInfo struct {
-	TotalURLs           int     `json:"total_urls"`
-	EligibleHTMLURLs    int     `json:"eligible_html_urls"`
-	EligibleNonHTMLURLs int     `json:"eligible_non_html_urls"`
-	TotalClusters       int     `json:"total_clusters"`
-	SimThreshold        float64 `json:"sim_threshold"`
-	GeneratedAt         string  `json:"generated_at"`
+	TotalURLs           int        `json:"total_urls"`
+	EligibleHTMLURLs    int        `json:"eligible_html_urls"`
+	EligibleNonHTMLURLs int        `json:"eligible_non_html_urls"`
+	TotalClusters       int        `json:"total_clusters"`
+	CandidatePairs      int        `json:"candidate_pairs"` // 实际比较的候选对数量（已在同一集合、跳过比较的不计）
+	SimHashBands        int        `json:"simhash_bands"`
+	PHashBands          int        `json:"phash_bands"`
+	CappedLSHBuckets    int        `json:"capped_lsh_buckets"` // 成员超过上限、只比较了第一个成员的 LSH 桶数，大于 0 时召回保证不成立
+	SimThreshold        float64    `json:"sim_threshold"`
+	CrossHost           bool       `json:"cross_host"`
+	NoRender            bool       `json:"no_render"`           // HTTP-only 模式，视觉和行为维度不可用
+	RendererRestarts    int        `json:"renderer_restarts"`   // 浏览器崩溃/无响应后的重启次数
+	RendererRecycles    int        `json:"renderer_recycles"`   // 按页数/RSS 回收浏览器的次数
+	ThrottleEvents      int        `json:"throttle_events"`     // 检测到 429/503/WAF 拦截并退避的次数
+	ExpandedDuplicates  int        `json:"expanded_duplicates"` // 主机/端口/CIDR 展开后重复、已合并的 URL 数
+	ProbeDropped        int        `json:"probe_dropped"`       // 协议探测时 http 和 https 都无响应的地址数（作为抓取失败写入报告）
+	CollapsedURLs       int        `json:"collapsed_urls"`      // 抓取前规范化后相同、已合并的 URL 数
+	SeededURLs          int        `json:"seeded_urls"`         // 从 robots.txt 和 sitemap 发现并加入的 URL 数
+	Thresholds          Thresholds `json:"thresholds"`          // 实际生效的阈值和权重
+	GeneratedAt         string     `json:"generated_at"`
 }
 
 // FullReport 完整报告
@@ -0,0 +1,226 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// LoadVHostList 从文件加载候选虚拟主机名，每行一个，# 开头为注释
+func LoadVHostList(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开虚拟主机列表失败: %w", err)
+	}
+	defer f.Close()
+
+	var hosts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		hosts = append(hosts, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取虚拟主机列表失败: %w", err)
+	}
+	return hosts, nil
+}
+
+// ExpandVHosts 虚拟主机扫描：对 host 为 IP 的每个 URL，按每个候选主机名各生成一个 URL
+// 原 URL 保留，作为该 IP 的默认站点；新 URL 的 host 换成候选主机名（Host 头和 TLS SNI 都使用它），
+// 连接时仍然连到原 IP。host 不是 IP 的 URL 原样保留。返回的 URL ID 按顺序重新编号
+func ExpandVHosts(items []URLItem, vhosts []string) []URLItem {
+	hosts := normalizeVHosts(vhosts)
+	if len(hosts) == 0 {
+		return items
+	}
+
+	expanded := make([]URLItem, 0, len(items)*(len(hosts)+1))
+	for _, item := range items {
+		expanded = append(expanded, item)
+
+		u, err := url.Parse(item.NormalizedURL)
+		if err != nil || net.ParseIP(u.Hostname()) == nil {
+			continue
+		}
+		ip := u.Hostname()
+		for _, host := range hosts {
+			vu := *u
+			if port := u.Port(); port != "" {
+				vu.Host = net.JoinHostPort(host, port)
+			} else {
+				vu.Host = host
+			}
+			expanded = append(expanded, URLItem{
+				RawURL:        item.RawURL,
+				NormalizedURL: vu.String(),
+				TargetIP:      ip,
+				VHost:         host,
+				Meta:          item.Meta,
+				Source:        item.Source,
+			})
+		}
+	}
+
+	for i := range expanded {
+		expanded[i].ID = i + 1
+	}
+	return expanded
+}
+
+// normalizeVHosts 候选主机名转小写、去掉端口和末尾的点，并去重
+func normalizeVHosts(vhosts []string) []string {
+	seen := make(map[string]bool, len(vhosts))
+	var hosts []string
+	for _, h := range vhosts {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if host, _, err := net.SplitHostPort(h); err == nil {
+			h = host
+		}
+		h = strings.TrimSuffix(h, ".")
+		if h == "" || seen[h] {
+			continue
+		}
+		seen[h] = true
+		hosts = append(hosts, h)
+	}
+	return hosts
+}
+
+// connectURL 实际连接的地址：虚拟主机 URL 的 host 换回目标 IP，用于按主机调度和限流检测
+func (it URLItem) connectURL() string {
+	if it.TargetIP == "" {
+		return it.NormalizedURL
+	}
+	u, err := url.Parse(it.NormalizedURL)
+	if err != nil {
+		return it.NormalizedURL
+	}
+	u.Host = targetHost(u, it.TargetIP)
+	return u.String()
+}
+
+// connectURLOf 访问 rawURL 时实际连接的地址：host 是本 URL 的虚拟主机名时换成目标 IP，
+// 其他 host（例如跳转到的其他站点）原样返回。渲染最终 URL 时与抓取使用同一个调度 key
+func (it URLItem) connectURLOf(rawURL string) string {
+	if it.TargetIP == "" {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || !strings.EqualFold(u.Hostname(), it.VHost) {
+		return rawURL
+	}
+	u.Host = targetHost(u, it.TargetIP)
+	return u.String()
+}
+
+// targetScope 按目标 IP 区分的范围键：同一个虚拟主机在不同 IP 上是不同的站点
+// key 通常是 origin，没有目标 IP 时原样返回
+func (it URLItem) targetScope(key string) string {
+	if it.TargetIP == "" || key == "" {
+		return key
+	}
+	return key + "@" + it.TargetIP
+}
+
+// targetHost 用目标 IP 替换 URL 的 host，保留端口
+func targetHost(u *url.URL, ip string) string {
+	if port := u.Port(); port != "" {
+		return net.JoinHostPort(ip, port)
+	}
+	if strings.Contains(ip, ":") {
+		return "[" + ip + "]"
+	}
+	return ip
+}
+
+// dialTargetKey 请求 context 中记录虚拟主机目标 IP 的 key
+type dialTargetKey struct{}
+
+// withDialTarget 虚拟主机 URL 的请求连接到目标 IP（重定向沿用同一个 context）
+func withDialTarget(ctx context.Context, item URLItem) context.Context {
+	if item.TargetIP == "" || item.VHost == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, dialTargetKey{}, item)
+}
+
+// vhostDialContext 拨号时把虚拟主机名映射到目标 IP
+// 只改写连接地址，Host 头和 TLS SNI 仍然是虚拟主机名；跳转到其他 host 时正常解析
+func vhostDialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if item, ok := ctx.Value(dialTargetKey{}).(URLItem); ok {
+			if host, port, err := net.SplitHostPort(addr); err == nil && strings.EqualFold(host, item.VHost) {
+				addr = net.JoinHostPort(item.TargetIP, port)
+			}
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+}
+
+// hostResolverRules 生成 Chrome 的 --host-resolver-rules，把一组结果中的虚拟主机名映射到目标 IP
+// 同一个主机名只能映射到一个 IP，调用方需要先用 groupByResolverMapping 分组后分别渲染
+func hostResolverRules(results []FetchResult) string {
+	seen := make(map[string]bool)
+	var rules []string
+	for _, fr := range results {
+		if fr.TargetIP == "" || fr.VHost == "" {
+			continue
+		}
+		ip := fr.TargetIP
+		if strings.Contains(ip, ":") {
+			ip = "[" + ip + "]"
+		}
+		rule := fmt.Sprintf("MAP %s %s", fr.VHost, ip)
+		if !seen[rule] {
+			seen[rule] = true
+			rules = append(rules, rule)
+		}
+	}
+	sort.Strings(rules)
+	return strings.Join(rules, ", ")
+}
+
+// groupByResolverMapping 把结果分成若干组渲染，组内同一个主机名只对应一个目标 IP
+// （没有目标 IP 的按 DNS 解析，也算一种取值）。互不冲突的 IP 合并到同一组，
+// 只有同一个主机名要映射到不同 IP 时才另起一组，尽量减少切换映射时的浏览器重启；组内保持原有顺序
+func groupByResolverMapping(results []FetchResult) [][]FetchResult {
+	var groups [][]FetchResult
+	var mappings []map[string]string
+	for _, fr := range results {
+		host := resolverHost(fr)
+		i := 0
+		for ; i < len(groups); i++ {
+			if ip, ok := mappings[i][host]; !ok || ip == fr.TargetIP {
+				break
+			}
+		}
+		if i == len(groups) {
+			groups = append(groups, nil)
+			mappings = append(mappings, make(map[string]string))
+		}
+		mappings[i][host] = fr.TargetIP
+		groups[i] = append(groups[i], fr)
+	}
+	return groups
+}
+
+// resolverHost 渲染时浏览器要解析的主机名
+func resolverHost(fr FetchResult) string {
+	if fr.VHost != "" {
+		return fr.VHost
+	}
+	u, err := url.Parse(fr.NormalizedURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
@@ -0,0 +1,145 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeVHosts(t *testing.T) {
+	got := normalizeVHosts([]string{" Admin.Example.com ", "admin.example.com:8443", "www.example.com.", "", "WWW.example.com"})
+	want := []string{"admin.example.com", "www.example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeVHosts = %v, want %v", got, want)
+	}
+}
+
+func TestExpandVHosts(t *testing.T) {
+	items := []URLItem{
+		{ID: 1, NormalizedURL: "https://10.0.0.1:8443/app", Source: "list"},
+		{ID: 2, NormalizedURL: "https://example.org/"},
+		{ID: 3, NormalizedURL: "http://[2001:db8::1]/"},
+	}
+	got := ExpandVHosts(items, []string{"a.example.com", "B.example.com"})
+
+	want := []struct {
+		url, ip, vhost string
+	}{
+		{"https://10.0.0.1:8443/app", "", ""},
+		{"https://a.example.com:8443/app", "10.0.0.1", "a.example.com"},
+		{"https://b.example.com:8443/app", "10.0.0.1", "b.example.com"},
+		{"https://example.org/", "", ""},
+		{"http://[2001:db8::1]/", "", ""},
+		{"http://a.example.com/", "2001:db8::1", "a.example.com"},
+		{"http://b.example.com/", "2001:db8::1", "b.example.com"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("展开后 %d 个 URL，期望 %d 个: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		it := got[i]
+		if it.ID != i+1 || it.NormalizedURL != w.url || it.TargetIP != w.ip || it.VHost != w.vhost {
+			t.Errorf("第 %d 个 = {%d %s %s %s}，期望 {%d %s %s %s}", i, it.ID, it.NormalizedURL, it.TargetIP, it.VHost, i+1, w.url, w.ip, w.vhost)
+		}
+	}
+	if got[1].Source != "list" {
+		t.Errorf("展开的 URL 应保留来源: %+v", got[1])
+	}
+
+	if out := ExpandVHosts(items, []string{" "}); len(out) != len(items) {
+		t.Errorf("没有有效的候选主机名时不应展开: %+v", out)
+	}
+}
+
+func TestConnectURL(t *testing.T) {
+	tests := []struct {
+		item URLItem
+		want string
+	}{
+		{URLItem{NormalizedURL: "https://a.example.com:8443/x"}, "https://a.example.com:8443/x"},
+		{URLItem{NormalizedURL: "https://a.example.com:8443/x", TargetIP: "10.0.0.1"}, "https://10.0.0.1:8443/x"},
+		{URLItem{NormalizedURL: "http://a.example.com/x", TargetIP: "2001:db8::1"}, "http://[2001:db8::1]/x"},
+	}
+	for _, tt := range tests {
+		if got := tt.item.connectURL(); got != tt.want {
+			t.Errorf("connectURL(%s, %s) = %s, want %s", tt.item.NormalizedURL, tt.item.TargetIP, got, tt.want)
+		}
+	}
+}
+
+func TestConnectURLOf(t *testing.T) {
+	item := URLItem{NormalizedURL: "https://a.example.com:8443/", TargetIP: "10.0.0.1", VHost: "a.example.com"}
+	tests := []struct {
+		in, want string
+	}{
+		// 渲染最终 URL 时与抓取使用同一个调度 key（目标 IP）
+		{"https://A.example.com:8443/login", "https://10.0.0.1:8443/login"},
+		{"https://other.example.com/", "https://other.example.com/"},
+	}
+	for _, tt := range tests {
+		if got := item.connectURLOf(tt.in); got != tt.want {
+			t.Errorf("connectURLOf(%s) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+	if got, want := item.connectURLOf(item.NormalizedURL), item.connectURL(); got != want {
+		t.Errorf("connectURLOf(NormalizedURL) = %s，应与 connectURL() %s 一致", got, want)
+	}
+
+	plain := URLItem{NormalizedURL: "https://a.com/"}
+	if got := plain.connectURLOf("https://a.com/x"); got != "https://a.com/x" {
+		t.Errorf("非虚拟主机 URL 应原样返回: %s", got)
+	}
+}
+
+func TestHostResolverRules(t *testing.T) {
+	results := []FetchResult{
+		{URLItem: URLItem{NormalizedURL: "https://10.0.0.1/"}},
+		{URLItem: URLItem{NormalizedURL: "https://b.example.com/", TargetIP: "10.0.0.1", VHost: "b.example.com"}},
+		{URLItem: URLItem{NormalizedURL: "https://a.example.com/", TargetIP: "2001:db8::1", VHost: "a.example.com"}},
+		{URLItem: URLItem{NormalizedURL: "https://b.example.com/x", TargetIP: "10.0.0.1", VHost: "b.example.com"}},
+	}
+	want := "MAP a.example.com [2001:db8::1], MAP b.example.com 10.0.0.1"
+	if got := hostResolverRules(results); got != want {
+		t.Errorf("hostResolverRules = %q, want %q", got, want)
+	}
+	if got := hostResolverRules(results[:1]); got != "" {
+		t.Errorf("没有虚拟主机时应为空: %q", got)
+	}
+}
+
+func TestGroupByResolverMapping(t *testing.T) {
+	fr := func(id int, u, ip, vhost string) FetchResult {
+		return FetchResult{URLItem: URLItem{ID: id, NormalizedURL: u, TargetIP: ip, VHost: vhost}}
+	}
+	results := []FetchResult{
+		fr(1, "https://10.0.0.1/", "", ""),
+		fr(2, "https://a.example.com/", "10.0.0.1", "a.example.com"),
+		fr(3, "https://10.0.0.2/", "", ""),
+		fr(4, "https://a.example.com/", "10.0.0.2", "a.example.com"),
+		fr(5, "https://b.example.com/", "10.0.0.2", "b.example.com"),
+		fr(6, "https://a.example.com/login", "", ""),
+		fr(7, "https://c.example.com/", "10.0.0.3", "c.example.com"),
+	}
+
+	groups := groupByResolverMapping(results)
+
+	var ids [][]int
+	for _, g := range groups {
+		var gi []int
+		for _, r := range g {
+			gi = append(gi, r.ID)
+		}
+		ids = append(ids, gi)
+	}
+	// a.example.com 要分别映射到 10.0.0.1、10.0.0.2 和 DNS，只能分三组；其他不冲突的都合并到前面的组
+	want := [][]int{{1, 2, 3, 5, 7}, {4}, {6}}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("分组 = %v, want %v", ids, want)
+	}
+
+	if rules := hostResolverRules(groups[0]); rules != "MAP a.example.com 10.0.0.1, MAP b.example.com 10.0.0.2, MAP c.example.com 10.0.0.3" {
+		t.Errorf("第一组映射 = %q", rules)
+	}
+	if rules := hostResolverRules(groups[2]); rules != "" {
+		t.Errorf("没有目标 IP 的组映射应为空: %q", rules)
+	}
+}